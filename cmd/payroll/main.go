@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"gorm.io/driver/postgres"
+	"log"
+	"os"
+
+	"gorm.io/gorm"
+
+	"github.com/jautyw/isa-investment-funds/config"
+	"github.com/jautyw/isa-investment-funds/internal/clock"
+	"github.com/jautyw/isa-investment-funds/internal/service"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+)
+
+// payroll invests the contributions in an employer's monthly payroll file and prints a report of any rows it flagged,
+// e.g.
+//
+//	go run ./cmd/payroll -employer 1 -file contributions.csv
+func main() {
+	employer := flag.Int("employer", 0, "ID of the employer the file is from")
+	file := flag.String("file", "", "CSV of employee reference, amount and pay date (YYYY-MM-DD)")
+	flag.Parse()
+
+	if *employer <= 0 || *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("error loading config %e", err)
+	}
+
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", cfg.Host, cfg.User, cfg.Password, cfg.Database, cfg.Port, cfg.SSLMode)
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("error opening postgres %v", err)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("error opening payroll file %v", err)
+	}
+	defer f.Close()
+
+	// Contributions are placed as buy orders through the service, so they are checked exactly as the API would
+	c := clock.NewClock()
+	s := service.NewService(storage.NewStore(db, storage.WithClock(c)), service.WithClock(c), service.WithMultipleProducts(cfg.AllowMultipleProducts))
+	report, err := s.ImportPayroll(context.Background(), *employer, f)
+	if err != nil {
+		// The rows before the one that failed were still invested, so the report is printed before exiting
+		if report != nil {
+			_ = report.Write(os.Stdout)
+		}
+		log.Fatalf("error importing payroll %v", err)
+	}
+
+	if err := report.Write(os.Stdout); err != nil {
+		log.Fatalf("error writing report %v", err)
+	}
+}
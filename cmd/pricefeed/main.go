@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"gorm.io/driver/postgres"
+	"log"
+	"os"
+
+	"gorm.io/gorm"
+
+	"github.com/jautyw/isa-investment-funds/config"
+	"github.com/jautyw/isa-investment-funds/internal/logger"
+	"github.com/jautyw/isa-investment-funds/internal/pricefeed"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+)
+
+// pricefeed loads a daily NAV file into the fund price history and prints a report of any rows it rejected, e.g.
+//
+//	go run ./cmd/pricefeed -file prices.csv
+func main() {
+	file := flag.String("file", "", "CSV of fund code, date (YYYY-MM-DD) and price")
+	maxMove := flag.Float64("max-move", pricefeed.DefaultMaxMove, "largest day-on-day price move accepted, as a fraction of the previous price")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("error loading config %e", err)
+	}
+
+	l := logger.NewLogger()
+
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", cfg.Host, cfg.User, cfg.Password, cfg.Database, cfg.Port, cfg.SSLMode)
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("error opening postgres %v", err)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("error opening price file %v", err)
+	}
+	defer f.Close()
+
+	importer := pricefeed.NewImporter(storage.NewStore(db), l, pricefeed.WithMaxMove(*maxMove))
+	report, err := importer.Import(context.Background(), f)
+	if err != nil {
+		log.Fatalf("error importing prices %v", err)
+	}
+
+	if err := report.Write(os.Stdout); err != nil {
+		log.Fatalf("error writing report %v", err)
+	}
+}
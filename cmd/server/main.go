@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"gorm.io/driver/postgres"
 	"log"
@@ -10,11 +11,13 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/jautyw/isa-investment-funds/config"
+	"github.com/jautyw/isa-investment-funds/internal/clock"
 	"github.com/jautyw/isa-investment-funds/internal/logger"
 	"github.com/jautyw/isa-investment-funds/internal/schema"
 	"github.com/jautyw/isa-investment-funds/internal/service"
 	"github.com/jautyw/isa-investment-funds/internal/storage"
 	"github.com/jautyw/isa-investment-funds/internal/transport"
+	"github.com/jautyw/isa-investment-funds/internal/worker"
 )
 
 func main() {
@@ -32,75 +35,212 @@ func main() {
 	}
 
 	// Run migrations to ensure the tables are created or updated
-	err = db.AutoMigrate(&schema.Funds{}, &schema.Orders{})
+	err = db.AutoMigrate(&schema.Customers{}, &schema.Employers{}, &schema.EmployerFunds{}, &schema.Funds{}, &schema.FundPrices{}, &schema.Orders{}, &schema.LifetimeISAAccounts{}, &schema.LifetimeISALedger{}, &schema.Transfers{}, &schema.Benchmarks{}, &schema.BenchmarkLevels{})
 	if err != nil {
 		log.Fatalf("error running migrations: %v", err)
 	}
 
+	if err := migrateFundPrices(db); err != nil {
+		log.Fatalf("error running migrations: %v", err)
+	}
+
+	// Lifetime ISAs used to keep a copy of the customer's date of birth, which is now only read from their record
+	if db.Migrator().HasColumn(&schema.LifetimeISAAccounts{}, "date_of_birth") {
+		if err := db.Migrator().DropColumn(&schema.LifetimeISAAccounts{}, "date_of_birth"); err != nil {
+			log.Fatalf("error running migrations: %v", err)
+		}
+	}
+
 	// Add some mock data
 	if err := SeedDatabase(db); err != nil {
 		log.Fatalf("failed to seed db: %v", err)
 	}
 
 	// Instantiate and inject each layer of the service
-	st := storage.NewStore(db)
-	s := service.NewService(st)
-	t := transport.NewHandler(s, l)
+	c := clock.NewClock()
+	st := storage.NewStore(db, storage.WithClock(c))
+	s := service.NewService(st, service.WithClock(c), service.WithMultipleProducts(cfg.AllowMultipleProducts))
+	t := transport.NewHandler(s, l, transport.WithAdminToken(cfg.AdminToken))
+
+	// Settle queued orders in the background, it is safe to run this on every replica
+	interval, err := time.ParseDuration(cfg.OrderExecutionInterval)
+	if err != nil {
+		log.Fatalf("error parsing order execution interval %v", err)
+	}
+	go worker.NewWorker(s, l, interval).Run(context.Background())
 
 	r := mux.NewRouter().StrictSlash(true)
 	t.HandleRequests(r)
 }
 
+// migrateFundPrices moves the single price funds used to hold, which was overwritten by each update, into fund_prices
+// before dropping the old columns. The price is kept as a valuation point at the time it was last updated, so that
+// pending orders can still be priced before the next feed is loaded. Orders placed before then are linked to their
+// fund by code, preferring the fund offered to the customer's type, and executed ones are taken to have executed when
+// they were placed, so that they are still valued and pooled in order. Each step only touches rows it hasn't migrated.
+func migrateFundPrices(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE orders SET fund_id = (
+				SELECT funds.id FROM funds
+				LEFT JOIN customers ON customers.customer_id = orders.customer_id
+				WHERE funds.code = orders.code
+				ORDER BY funds.customer_type = customers.customer_type DESC NULLS LAST, funds.id
+				LIMIT 1
+			)
+			WHERE COALESCE(fund_id, 0) = 0 AND EXISTS (SELECT 1 FROM funds WHERE funds.code = orders.code)`).Error
+		if err != nil {
+			return fmt.Errorf("failed to backfill order funds: %w", err)
+		}
+
+		err = tx.Exec("UPDATE orders SET execution_time = order_time WHERE status = ? AND execution_time IS NULL", schema.Executed).Error
+		if err != nil {
+			return fmt.Errorf("failed to backfill order execution times: %w", err)
+		}
+
+		if tx.Migrator().HasColumn(&schema.Funds{}, "amount_gbp") {
+			valuationPoint := "now()"
+			if tx.Migrator().HasColumn(&schema.Funds{}, "last_updated") {
+				valuationPoint = "COALESCE(last_updated, now())"
+			}
+
+			err := tx.Exec(`INSERT INTO fund_prices (fund_id, valuation_point, price_gbp)
+				SELECT id, ` + valuationPoint + `, amount_gbp FROM funds WHERE amount_gbp > 0
+				ON CONFLICT DO NOTHING`).Error
+			if err != nil {
+				return fmt.Errorf("failed to copy fund prices: %w", err)
+			}
+		}
+
+		for _, column := range []string{"amount_gbp", "last_updated"} {
+			if tx.Migrator().HasColumn(&schema.Funds{}, column) {
+				if err := tx.Migrator().DropColumn(&schema.Funds{}, column); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
 // SeedDatabase exists purely for initial and subsequent local testing.
 func SeedDatabase(db *gorm.DB) error {
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "customers")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "customers", err)
+	}
+
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "employers")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "employers", err)
+	}
+
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "employer_funds")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "employer_funds", err)
+	}
+
 	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "funds")).Error; err != nil {
 		return fmt.Errorf("failed to clear table %s: %w", "funds", err)
 	}
 
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "fund_prices")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "fund_prices", err)
+	}
+
 	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "orders")).Error; err != nil {
 		return fmt.Errorf("failed to clear table %s: %w", "orders", err)
 	}
 
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "lifetime_isa_accounts")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "lifetime_isa_accounts", err)
+	}
+
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "lifetime_isa_ledgers")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "lifetime_isa_ledgers", err)
+	}
+
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "transfers")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "transfers", err)
+	}
+
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "benchmarks")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "benchmarks", err)
+	}
+
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "benchmark_levels")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "benchmark_levels", err)
+	}
+
+	now := time.Now()
+	price := 4.92
+
+	// Workplace customers are offered the funds approved by their employer
+	employer := schema.Employers{ID: 1, Name: "Acme Ltd"}
+	if err := db.Create(&employer).Error; err != nil {
+		return fmt.Errorf("failed to insert employer data: %w", err)
+	}
+
+	employeeReference, defaultFundCode := "EMP001", "V3AM"
+
+	// Customer 1 holds the seeded orders, 10000 is the customer used in the postman collection and 2 is a workplace
+	// customer employed by Acme, whose payroll contributions go into V3AM
+	customers := []schema.Customers{
+		{CustomerID: 1, CustomerType: schema.Retail, DateOfBirth: time.Date(1990, 6, 15, 0, 0, 0, 0, time.UTC), Residency: schema.UKResident, Status: schema.CustomerActive, CreatedAt: now},
+		{CustomerID: 2, CustomerType: schema.Workplace, EmployerID: &employer.ID, EmployeeReference: &employeeReference, DefaultFundCode: &defaultFundCode, DateOfBirth: time.Date(1985, 2, 1, 0, 0, 0, 0, time.UTC), Residency: schema.UKResident, Status: schema.CustomerActive, CreatedAt: now},
+		{CustomerID: 10000, CustomerType: schema.Retail, DateOfBirth: time.Date(1995, 6, 1, 0, 0, 0, 0, time.UTC), Residency: schema.UKResident, Status: schema.CustomerActive, CreatedAt: now},
+	}
+	if err := db.Create(&customers).Error; err != nil {
+		return fmt.Errorf("failed to insert customer data: %w", err)
+	}
+
+	// The funds track a global all cap index, which is measured against alongside them
+	benchmark := schema.Benchmarks{ID: 1, Code: "FTGAC", Name: "FTSE Global All Cap Index"}
+	if err := db.Create(&benchmark).Error; err != nil {
+		return fmt.Errorf("failed to insert benchmark data: %w", err)
+	}
+
+	levels := []schema.BenchmarkLevels{
+		{BenchmarkID: benchmark.ID, Date: now.AddDate(0, 0, -1), Level: 1000},
+		{BenchmarkID: benchmark.ID, Date: now, Level: 1012},
+	}
+	if err := db.Create(&levels).Error; err != nil {
+		return fmt.Errorf("failed to insert benchmark level data: %w", err)
+	}
+
 	funds := []schema.Funds{
 		{
 			ID:           1,
 			Name:         "ESG Global All Cap UCITS ETF",
 			Description:  "Some fund",
 			Code:         "V3AM",
-			AmountGBP:    4.92,
 			CustomerType: schema.Retail,
 			RiskScore:    schema.Medium,
-			LastUpdated:  time.Now(),
+			BenchmarkID:  &benchmark.ID,
 		},
 		{
 			ID:           2,
 			Name:         "ESG Global All Cap UCITS ETF - (USD) Accumulating",
 			Description:  "Some fund",
 			Code:         "V3AB",
-			AmountGBP:    4.92,
 			CustomerType: schema.Retail,
 			RiskScore:    schema.Medium,
-			LastUpdated:  time.Now(),
+			BenchmarkID:  &benchmark.ID,
 		},
 		{
 			ID:           3,
 			Name:         "ESG Global All Cap UCITS ETF",
 			Description:  "Some fund",
 			Code:         "V3AM",
-			AmountGBP:    4.92,
 			CustomerType: schema.Workplace,
 			RiskScore:    schema.Medium,
-			LastUpdated:  time.Now(),
+			BenchmarkID:  &benchmark.ID,
 		},
 		{
 			ID:           4,
 			Name:         "ESG Global All Cap UCITS ETF - (USD) Accumulating",
 			Description:  "Some fund",
 			Code:         "V3AB",
-			AmountGBP:    4.92,
 			CustomerType: schema.Workplace,
 			RiskScore:    schema.Medium,
-			LastUpdated:  time.Now(),
+			BenchmarkID:  &benchmark.ID,
 		},
 	}
 
@@ -108,6 +248,21 @@ func SeedDatabase(db *gorm.DB) error {
 		if err := db.Create(&fund).Error; err != nil {
 			return fmt.Errorf("failed to insert fund data: %w", err)
 		}
+
+		// Each fund is priced a day ago and again now
+		prices := []schema.FundPrices{
+			{FundID: fund.ID, ValuationPoint: now.AddDate(0, 0, -1), PriceGBP: 4.85},
+			{FundID: fund.ID, ValuationPoint: now, PriceGBP: price},
+		}
+		if err := db.Create(&prices).Error; err != nil {
+			return fmt.Errorf("failed to insert fund price data: %w", err)
+		}
+	}
+
+	// Acme has only approved the first of the workplace funds
+	employerFunds := []schema.EmployerFunds{{EmployerID: employer.ID, FundID: 3, AnnualChargePercent: 0.15}}
+	if err := db.Create(&employerFunds).Error; err != nil {
+		return fmt.Errorf("failed to insert employer fund data: %w", err)
 	}
 
 	orders := []schema.Orders{
@@ -115,45 +270,61 @@ func SeedDatabase(db *gorm.DB) error {
 			OrderID:           1,
 			OrderType:         schema.Buy,
 			CustomerID:        1,
+			FundID:            1,
 			Name:              "ESG Global All Cap UCITS ETF",
 			Description:       "Some fund",
 			Code:              "V3AM",
 			Shares:            100.0,
 			PurchasedValueGBP: 492.0,
-			OrderTime:         time.Now(),
+			OrderTime:         now,
+			Status:            schema.Executed,
+			ExecutionTime:     &now,
+			ExecutionPriceGBP: &price,
 		},
 		{
 			OrderID:           2,
 			OrderType:         schema.Sell,
 			CustomerID:        1,
+			FundID:            1,
 			Name:              "ESG Global All Cap UCITS ETF",
 			Description:       "Some fund",
 			Code:              "V3AM",
 			Shares:            50.0,
 			PurchasedValueGBP: 246.0,
-			OrderTime:         time.Now(),
+			OrderTime:         now,
+			Status:            schema.Executed,
+			ExecutionTime:     &now,
+			ExecutionPriceGBP: &price,
 		},
 		{
 			OrderID:           3,
 			OrderType:         schema.Buy,
 			CustomerID:        1,
+			FundID:            2,
 			Name:              "ESG Global All Cap UCITS ETF - (USD) Accumulating",
 			Description:       "Some fund",
 			Code:              "V3AB",
 			Shares:            100.0,
 			PurchasedValueGBP: 492.0,
-			OrderTime:         time.Now(),
+			OrderTime:         now,
+			Status:            schema.Executed,
+			ExecutionTime:     &now,
+			ExecutionPriceGBP: &price,
 		},
 		{
 			OrderID:           4,
 			OrderType:         schema.Sell,
 			CustomerID:        1,
+			FundID:            2,
 			Name:              "ESG Global All Cap UCITS ETF - (USD) Accumulating",
 			Description:       "Some fund",
 			Code:              "V3AB",
 			Shares:            50.0,
 			PurchasedValueGBP: 246.0,
-			OrderTime:         time.Now(),
+			OrderTime:         now,
+			Status:            schema.Executed,
+			ExecutionTime:     &now,
+			ExecutionPriceGBP: &price,
 		},
 	}
 
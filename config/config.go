@@ -39,12 +39,15 @@ func LoadConfig() (*Config, error) {
 
 // Config represents the configuration fields required for the application.
 type Config struct {
-	Host           string `yaml:"Host"`
-	User           string `yaml:"User"`
-	Password       string `yaml:"Password"`
-	Database       string `yaml:"Database"`
-	FundTableName  string `yaml:"FundTableName"`
-	OrderTableName string `yaml:"OrderTableName"`
-	Port           string `yaml:"Port"`
-	SSLMode        string `yaml:"SSLMode"`
+	Host                   string `yaml:"Host"`
+	User                   string `yaml:"User"`
+	Password               string `yaml:"Password"`
+	Database               string `yaml:"Database"`
+	FundTableName          string `yaml:"FundTableName"`
+	OrderTableName         string `yaml:"OrderTableName"`
+	Port                   string `yaml:"Port"`
+	SSLMode                string `yaml:"SSLMode"`
+	OrderExecutionInterval string `yaml:"OrderExecutionInterval"`
+	AllowMultipleProducts  bool   `yaml:"AllowMultipleProducts"`
+	AdminToken             string `yaml:"AdminToken"`
 }
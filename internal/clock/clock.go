@@ -0,0 +1,32 @@
+package clock
+
+import "time"
+
+// Clock tells the current time. It is injected wherever "now" matters so that tests can pin the date.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+// NewClock returns a Clock backed by the system time
+func NewClock() Clock {
+	return systemClock{}
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+type fixedClock struct {
+	t time.Time
+}
+
+// Fixed returns a Clock that always reports t
+func Fixed(t time.Time) Clock {
+	return fixedClock{t: t}
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.t
+}
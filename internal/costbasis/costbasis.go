@@ -0,0 +1,41 @@
+package costbasis
+
+// Pool is a holding's book cost under pooled average costing. Every share in the pool has the same cost, so a sale
+// takes out its share of the pool's cost however the shares were bought.
+type Pool struct {
+	Shares  float64
+	CostGBP float64
+}
+
+// Buy adds shares bought for cost to the pool
+func (p *Pool) Buy(shares float64, costGBP float64) {
+	p.Shares += shares
+	p.CostGBP += costGBP
+}
+
+// Sell removes shares sold for proceeds from the pool, returning the book cost taken out with them and the gain
+// realised over it. Selling the whole pool, or more than it holds, empties it.
+func (p *Pool) Sell(shares float64, proceedsGBP float64) (costGBP float64, gainGBP float64) {
+	if p.Shares <= 0 {
+		return 0, proceedsGBP
+	}
+
+	if shares >= p.Shares {
+		costGBP = p.CostGBP
+		p.Shares, p.CostGBP = 0, 0
+	} else {
+		costGBP = p.CostGBP * shares / p.Shares
+		p.Shares -= shares
+		p.CostGBP -= costGBP
+	}
+
+	return costGBP, proceedsGBP - costGBP
+}
+
+// AverageCost is the book cost of a single share in the pool, zero when it is empty
+func (p *Pool) AverageCost() float64 {
+	if p.Shares <= 0 {
+		return 0
+	}
+	return p.CostGBP / p.Shares
+}
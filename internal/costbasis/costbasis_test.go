@@ -0,0 +1,45 @@
+package costbasis_test
+
+import (
+	"github.com/jautyw/isa-investment-funds/internal/costbasis"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	var p costbasis.Pool
+
+	p.Buy(100, 400)
+	p.Buy(100, 600)
+	assert.Equal(t, 5.0, p.AverageCost())
+
+	// Half the pool is sold, taking half of its cost whichever purchase the shares came from
+	cost, gain := p.Sell(100, 700)
+	assert.Equal(t, 500.0, cost)
+	assert.Equal(t, 200.0, gain)
+	assert.Equal(t, costbasis.Pool{Shares: 100, CostGBP: 500}, p)
+
+	// Later purchases are averaged into what is left
+	p.Buy(100, 300)
+	assert.Equal(t, 4.0, p.AverageCost())
+
+	cost, gain = p.Sell(50, 150)
+	assert.Equal(t, 200.0, cost)
+	assert.Equal(t, -50.0, gain)
+}
+
+func TestPool_SellAll(t *testing.T) {
+	var p costbasis.Pool
+
+	p.Buy(3, 10)
+	cost, gain := p.Sell(3, 12)
+	assert.Equal(t, 10.0, cost)
+	assert.Equal(t, 2.0, gain)
+	assert.Equal(t, costbasis.Pool{}, p)
+	assert.Equal(t, 0.0, p.AverageCost())
+
+	// Nothing is left to take cost from
+	cost, gain = p.Sell(1, 5)
+	assert.Equal(t, 0.0, cost)
+	assert.Equal(t, 5.0, gain)
+}
@@ -0,0 +1,8 @@
+package money
+
+import "math"
+
+// RoundPence rounds an amount to the nearest penny, percentages are rounded to two decimal places the same way
+func RoundPence(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
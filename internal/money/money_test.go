@@ -0,0 +1,14 @@
+package money_test
+
+import (
+	"github.com/jautyw/isa-investment-funds/internal/money"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRoundPence(t *testing.T) {
+	assert.Equal(t, 4.92, money.RoundPence(4.9249))
+	assert.Equal(t, 4.93, money.RoundPence(4.925))
+	assert.Equal(t, -1.23, money.RoundPence(-1.2345))
+	assert.Equal(t, float64(0), money.RoundPence(0.004))
+}
@@ -0,0 +1,199 @@
+package payroll
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ErrReadingFile = "error reading payroll file"
+
+	// dateLayout is the format of the pay date column
+	dateLayout = "2006-01-02"
+
+	ReasonMalformedRow      = "row must have an employee reference, amount and pay date"
+	ReasonInvalidAmount     = "amount is not a number"
+	ReasonNonPositive       = "amount must be greater than zero"
+	ReasonInvalidDate       = "pay date must be YYYY-MM-DD"
+	ReasonDuplicate         = "employee has more than one contribution on this pay date"
+	ReasonUnknownEmployee   = "no customer matches the employee reference"
+	ReasonNoDefaultFund     = "employee has no default fund"
+	ReasonFundNotOffered    = "employee's default fund is not in the employer's catalogue"
+	ReasonAllowanceExceeded = "contribution would breach the employee's ISA allowance"
+	ReasonAlreadyImported   = "a different contribution has already been imported for this pay date"
+	ReasonOutsideTaxYear    = "pay date is outside the current tax year"
+	ReasonNotEligible       = "employee is not eligible to subscribe to an ISA"
+	ReasonFundUnsuitable    = "employee's default fund is riskier than their risk profile"
+	ReasonMultipleProducts  = "employee already holds another fund, only a single product is allowed"
+)
+
+// Contribution is a payroll deduction to be invested for an employee. Rows are numbered by their line in the file.
+type Contribution struct {
+	Line              int
+	EmployeeReference string
+	AmountGBP         float64
+	PayDate           time.Time
+}
+
+// Report summarises an import. Ordered counts the contributions a buy order was placed for, and Replayed those that
+// had already been ordered by an earlier import of the same file. StoppedAtLine is only set when the import failed
+// partway through, and is the line it failed on, rows from there on weren't invested.
+type Report struct {
+	RowsRead      int
+	Ordered       int
+	Replayed      int
+	Flagged       []Flag
+	StoppedAtLine int
+}
+
+// Flag is a row of the file that wasn't invested and why
+type Flag struct {
+	Line              int
+	EmployeeReference string
+	Amount            string
+	PayDate           string
+	Reason            string
+}
+
+// Parse reads a CSV of employee reference, amount and pay date, with an optional header. Rows that can't be read, and
+// employees with more than one contribution on a pay date, are flagged in the report rather than failing the file, an
+// error is only returned if it isn't a CSV.
+func Parse(r io.Reader) ([]Contribution, *Report, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, ErrReadingFile)
+	}
+
+	report := &Report{}
+	var contributions []Contribution
+	for n, record := range records {
+		if n == 0 && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "employee_reference") {
+			continue
+		}
+		report.RowsRead++
+
+		c, reason := parseRow(n+1, record)
+		if reason != "" {
+			report.Flag(flagged(n+1, record), reason)
+			continue
+		}
+
+		contributions = append(contributions, c)
+	}
+
+	// Two deductions for the same pay date are more likely a mistake in the file than two payments, so neither is
+	// invested.
+	seen := map[string]int{}
+	for _, c := range contributions {
+		seen[c.key()]++
+	}
+
+	var unique []Contribution
+	for _, c := range contributions {
+		if seen[c.key()] > 1 {
+			report.Flag(c.Flag(), ReasonDuplicate)
+			continue
+		}
+		unique = append(unique, c)
+	}
+
+	return unique, report, nil
+}
+
+// Flag records that the row wasn't invested, keeping the flags in file order.
+func (r *Report) Flag(f Flag, reason string) {
+	f.Reason = reason
+	i := sort.Search(len(r.Flagged), func(i int) bool { return r.Flagged[i].Line > f.Line })
+	r.Flagged = slices.Insert(r.Flagged, i, f)
+}
+
+// Flag returns the contribution as it appeared in the file, ready to be flagged.
+func (c Contribution) Flag() Flag {
+	return Flag{
+		Line:              c.Line,
+		EmployeeReference: c.EmployeeReference,
+		Amount:            strconv.FormatFloat(c.AmountGBP, 'f', 2, 64),
+		PayDate:           c.PayDate.Format(dateLayout),
+	}
+}
+
+func (c Contribution) key() string {
+	return c.EmployeeReference + " " + c.PayDate.Format(dateLayout)
+}
+
+// parseRow reads a record of the file, returning why it can't be invested if it can't.
+func parseRow(line int, record []string) (Contribution, string) {
+	c := Contribution{Line: line}
+	for n, field := range record {
+		record[n] = strings.TrimSpace(field)
+	}
+
+	if len(record) != 3 || record[0] == "" {
+		return c, ReasonMalformedRow
+	}
+	c.EmployeeReference = record[0]
+
+	amount, err := strconv.ParseFloat(record[1], 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return c, ReasonInvalidAmount
+	}
+	if amount <= 0 {
+		return c, ReasonNonPositive
+	}
+	c.AmountGBP = amount
+
+	c.PayDate, err = time.Parse(dateLayout, record[2])
+	if err != nil {
+		return c, ReasonInvalidDate
+	}
+
+	return c, ""
+}
+
+// flagged returns the record as it appeared in the file.
+func flagged(line int, record []string) Flag {
+	f := Flag{Line: line}
+	if len(record) > 0 {
+		f.EmployeeReference = record[0]
+	}
+	if len(record) > 1 {
+		f.Amount = record[1]
+	}
+	if len(record) > 2 {
+		f.PayDate = record[2]
+	}
+	return f
+}
+
+// Write prints the report, listing each flagged row under a summary of the import.
+func (r *Report) Write(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "read %d rows, ordered %d, already ordered %d, flagged %d\n", r.RowsRead, r.Ordered, r.Replayed, len(r.Flagged)); err != nil {
+		return err
+	}
+
+	for _, f := range r.Flagged {
+		if _, err := fmt.Fprintf(w, "line %d: %s,%s,%s: %s\n", f.Line, f.EmployeeReference, f.Amount, f.PayDate, f.Reason); err != nil {
+			return err
+		}
+	}
+
+	if r.StoppedAtLine > 0 {
+		if _, err := fmt.Fprintf(w, "stopped at line %d, it and any rows after it weren't invested\n", r.StoppedAtLine); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package payroll_test
+
+import (
+	"bytes"
+	"github.com/jautyw/isa-investment-funds/internal/payroll"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	file := `employee_reference,amount,pay_date
+EMP001,150.50,2025-03-28
+ EMP002 , 75 , 2025-03-28
+`
+
+	contributions, report, err := payroll.Parse(strings.NewReader(file))
+	assert.NoError(t, err)
+	assert.Equal(t, []payroll.Contribution{
+		{Line: 2, EmployeeReference: "EMP001", AmountGBP: 150.5, PayDate: time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC)},
+		{Line: 3, EmployeeReference: "EMP002", AmountGBP: 75, PayDate: time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC)},
+	}, contributions)
+	assert.Equal(t, &payroll.Report{RowsRead: 2}, report)
+}
+
+func TestParseFlags(t *testing.T) {
+	file := `EMP001,100,2025-03-28
+EMP002,-5,2025-03-28
+EMP003,abc,2025-03-28
+EMP004,100,28/03/2025
+EMP005,100
+EMP006,100,2025-03-28
+EMP006,100,2025-03-28
+EMP006,100,2025-04-28
+`
+
+	contributions, report, err := payroll.Parse(strings.NewReader(file))
+	assert.NoError(t, err)
+	assert.Equal(t, 8, report.RowsRead)
+
+	// The same employee can be paid on different dates, but not twice on one
+	assert.Len(t, contributions, 2)
+	assert.Equal(t, 1, contributions[0].Line)
+	assert.Equal(t, 8, contributions[1].Line)
+
+	reasons := map[int]string{}
+	for _, f := range report.Flagged {
+		reasons[f.Line] = f.Reason
+	}
+	assert.Equal(t, map[int]string{
+		2: payroll.ReasonNonPositive,
+		3: payroll.ReasonInvalidAmount,
+		4: payroll.ReasonInvalidDate,
+		5: payroll.ReasonMalformedRow,
+		6: payroll.ReasonDuplicate,
+		7: payroll.ReasonDuplicate,
+	}, reasons)
+
+	// Flags added later are kept in file order
+	report.Flag(contributions[0].Flag(), payroll.ReasonAllowanceExceeded)
+	assert.Equal(t, 1, report.Flagged[0].Line)
+
+	var out bytes.Buffer
+	err = report.Write(&out)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "read 8 rows, ordered 0, already ordered 0, flagged 7")
+	assert.Contains(t, out.String(), "line 1: EMP001,100.00,2025-03-28: "+payroll.ReasonAllowanceExceeded)
+	assert.Contains(t, out.String(), "line 3: EMP003,abc,2025-03-28: "+payroll.ReasonInvalidAmount)
+	assert.NotContains(t, out.String(), "stopped at line")
+
+	// An import that failed partway through says where it stopped
+	report.StoppedAtLine = 8
+	out.Reset()
+	err = report.Write(&out)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "stopped at line 8")
+}
+
+func TestParseNotCSV(t *testing.T) {
+	_, _, err := payroll.Parse(strings.NewReader("EMP001,\"100,2025-03-28\n"))
+	assert.ErrorContains(t, err, payroll.ErrReadingFile)
+}
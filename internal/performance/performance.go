@@ -0,0 +1,209 @@
+package performance
+
+import (
+	"github.com/jautyw/isa-investment-funds/internal/taxyear"
+	"github.com/pkg/errors"
+	"math"
+	"time"
+)
+
+// Period is how far back performance is measured from today
+type Period string
+
+const (
+	OneMonth       Period = "1M"
+	ThreeMonths    Period = "3M"
+	OneYear        Period = "1Y"
+	SinceInception Period = "since_inception"
+
+	// year is the length of time the money-weighted return is annualised over
+	year = 365 * 24 * time.Hour
+	// xirrIterations is enough halvings to pin the rate well below a hundredth of a percent
+	xirrIterations = 200
+)
+
+// ErrNoSolution is returned when the cash flows have no internal rate of return, such as when money has only gone in
+var ErrNoSolution = errors.New("cash flows have no internal rate of return")
+
+// Valid reports whether p is a period performance can be measured over
+func (p Period) Valid() bool {
+	switch p {
+	case OneMonth, ThreeMonths, OneYear, SinceInception:
+		return true
+	}
+	return false
+}
+
+// Opening returns the day the period is measured from, its returns start from the value at the end of that day. Since
+// inception has no opening day and returns the zero time.
+func (p Period) Opening(today time.Time) time.Time {
+	switch p {
+	case OneMonth:
+		return monthsBefore(today, 1)
+	case ThreeMonths:
+		return monthsBefore(today, 3)
+	case OneYear:
+		return monthsBefore(today, 12)
+	default:
+		return time.Time{}
+	}
+}
+
+// monthsBefore steps back whole calendar months from day, landing on the last day of the month when it is shorter
+// rather than running over into the next one.
+func monthsBefore(day time.Time, months int) time.Time {
+	firstOfMonth := time.Date(day.Year(), day.Month()-time.Month(months), 1, 0, 0, 0, 0, day.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	return firstOfMonth.AddDate(0, 0, min(day.Day(), lastDay)-1)
+}
+
+// Day returns midnight UK time at the start of the day t falls in
+func Day(t time.Time) time.Time {
+	local := t.In(taxyear.London)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, taxyear.London)
+}
+
+// Point is the value of a portfolio at the end of a day, along with the money invested in it and withdrawn from it
+// during the day.
+type Point struct {
+	Date         time.Time
+	ValueGBP     float64
+	InvestedGBP  float64
+	WithdrawnGBP float64
+}
+
+// CashFlow is money paid by the customer, which is negative, or paid back to them, which is positive
+type CashFlow struct {
+	Date      time.Time
+	AmountGBP float64
+}
+
+// Level is a price or index level at a point in time
+type Level struct {
+	At    time.Time
+	Value float64
+}
+
+// PeriodReturn is how much a price or index moved over the days after opening up to the end of closing. It is measured
+// from its level at the end of the opening day, or its first level after that if it had none by then, to its last level
+// by the end of the closing day. Levels must be in time order, false is returned when there are none in the period.
+func PeriodReturn(levels []Level, opening time.Time, closing time.Time) (float64, bool) {
+	openingEnd, closingEnd := opening.AddDate(0, 0, 1), closing.AddDate(0, 0, 1)
+
+	var from, to float64
+	for _, l := range levels {
+		if !l.At.Before(closingEnd) {
+			break
+		}
+		if l.At.Before(openingEnd) || from == 0 {
+			from = l.Value
+		}
+		to = l.Value
+	}
+
+	if from <= 0 {
+		return 0, false
+	}
+	return to/from - 1, true
+}
+
+// TimeWeightedReturn chains the return of each day, so the size and timing of money going in and out doesn't affect
+// the result. Orders execute at the day's price, so money invested on a day is taken to have been there from the start
+// of it and money withdrawn to have been there until the end. Days with nothing invested are skipped.
+func TimeWeightedReturn(openingValueGBP float64, points []Point) float64 {
+	growth, previous := 1.0, openingValueGBP
+	for _, p := range points {
+		if start := previous + p.InvestedGBP; start > 0 {
+			growth *= (p.ValueGBP + p.WithdrawnGBP) / start
+		}
+		previous = p.ValueGBP
+	}
+
+	return growth - 1
+}
+
+// CashFlows are the flows of a portfolio over the points from the customer's side. The opening value is paid in on the
+// opening day and the closing value paid back on the last day, as if the portfolio was bought and then sold.
+func CashFlows(opening time.Time, openingValueGBP float64, points []Point) []CashFlow {
+	var flows []CashFlow
+	if openingValueGBP > 0 {
+		flows = append(flows, CashFlow{Date: opening, AmountGBP: -openingValueGBP})
+	}
+
+	for _, p := range points {
+		if p.InvestedGBP != 0 || p.WithdrawnGBP != 0 {
+			flows = append(flows, CashFlow{Date: p.Date, AmountGBP: p.WithdrawnGBP - p.InvestedGBP})
+		}
+	}
+
+	if len(points) > 0 {
+		last := points[len(points)-1]
+		flows = append(flows, CashFlow{Date: last.Date, AmountGBP: last.ValueGBP})
+	}
+
+	return flows
+}
+
+// MoneyWeightedReturn is the internal rate of return of the flows, which must be in date order. As is usual it is only
+// annualised when the flows span at least a year, over a shorter span it is the return over the whole of it so that a
+// few days' gain isn't compounded into an enormous yearly figure.
+func MoneyWeightedReturn(flows []CashFlow) (float64, error) {
+	if len(flows) < 2 {
+		return 0, ErrNoSolution
+	}
+
+	span := flows[len(flows)-1].Date.Sub(flows[0].Date)
+	if span <= 0 {
+		return 0, ErrNoSolution
+	}
+	if span >= year {
+		return XIRR(flows)
+	}
+
+	return irr(flows, span)
+}
+
+// XIRR returns the annualised rate at which the flows' net present value is zero. Flows must be in date order.
+func XIRR(flows []CashFlow) (float64, error) {
+	return irr(flows, year)
+}
+
+// irr finds the rate per period at which the flows' net present value is zero by bisection between losing almost
+// everything and gaining many times over, there is no solution when the net present value doesn't change sign across
+// that range.
+func irr(flows []CashFlow, period time.Duration) (float64, error) {
+	if len(flows) < 2 {
+		return 0, ErrNoSolution
+	}
+
+	first := flows[0].Date
+	npv := func(rate float64) float64 {
+		var total float64
+		for _, f := range flows {
+			periods := float64(f.Date.Sub(first)) / float64(period)
+			total += f.AmountGBP / math.Pow(1+rate, periods)
+		}
+		return total
+	}
+
+	low, high := -0.999999, 1.0
+	for npv(high) > 0 && high < 1e9 {
+		high *= 10
+	}
+
+	lowNPV, highNPV := npv(low), npv(high)
+	if math.IsNaN(lowNPV) || math.IsNaN(highNPV) || lowNPV*highNPV >= 0 {
+		return 0, ErrNoSolution
+	}
+
+	for i := 0; i < xirrIterations; i++ {
+		mid := (low + high) / 2
+		if midNPV := npv(mid); (midNPV > 0) == (lowNPV > 0) {
+			low, lowNPV = mid, midNPV
+		} else {
+			high = mid
+		}
+	}
+
+	return (low + high) / 2, nil
+}
@@ -0,0 +1,167 @@
+package performance_test
+
+import (
+	"github.com/jautyw/isa-investment-funds/internal/performance"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func day(date string) time.Time {
+	d, _ := time.Parse("2006-01-02", date)
+	return performance.Day(d.Add(12 * time.Hour))
+}
+
+func TestTimeWeightedReturn(t *testing.T) {
+	points := []performance.Point{
+		// £1000 invested, which rises 10%
+		{Date: day("2025-03-03"), ValueGBP: 1000, InvestedGBP: 1000},
+		{Date: day("2025-03-04"), ValueGBP: 1100},
+		// A further £900 goes in and the whole portfolio falls 10%
+		{Date: day("2025-03-05"), ValueGBP: 1800, InvestedGBP: 900},
+		// £800 is taken out, the rest is unchanged
+		{Date: day("2025-03-06"), ValueGBP: 1000, WithdrawnGBP: 800},
+	}
+
+	// The extra money doesn't change the return, which is 1.1 * 0.9 - 1
+	assert.InDelta(t, -0.01, performance.TimeWeightedReturn(0, points), 1e-9)
+
+	// Measured from an opening value the first day's investment is added to it
+	assert.InDelta(t, 0.09, performance.TimeWeightedReturn(1000, []performance.Point{
+		{Date: day("2025-03-03"), ValueGBP: 2180, InvestedGBP: 1000},
+	}), 1e-9)
+}
+
+func TestTimeWeightedReturn_SellAll(t *testing.T) {
+	points := []performance.Point{
+		{Date: day("2025-03-03"), ValueGBP: 1000, InvestedGBP: 1000},
+		// Everything is sold at a 5% gain, after which nothing is invested
+		{Date: day("2025-03-04"), ValueGBP: 0, WithdrawnGBP: 1050},
+		{Date: day("2025-03-05"), ValueGBP: 0},
+	}
+
+	assert.InDelta(t, 0.05, performance.TimeWeightedReturn(0, points), 1e-9)
+}
+
+func TestCashFlows(t *testing.T) {
+	points := []performance.Point{
+		{Date: day("2025-03-04"), ValueGBP: 1100},
+		{Date: day("2025-03-05"), ValueGBP: 1800, InvestedGBP: 900},
+		{Date: day("2025-03-06"), ValueGBP: 1000, WithdrawnGBP: 800},
+	}
+
+	assert.Equal(t, []performance.CashFlow{
+		{Date: day("2025-03-03"), AmountGBP: -1000},
+		{Date: day("2025-03-05"), AmountGBP: -900},
+		{Date: day("2025-03-06"), AmountGBP: 800},
+		{Date: day("2025-03-06"), AmountGBP: 1000},
+	}, performance.CashFlows(day("2025-03-03"), 1000, points))
+}
+
+func TestXIRR(t *testing.T) {
+	// £1000 that grows to £1100 over a year returns 10%
+	rate, err := performance.XIRR([]performance.CashFlow{
+		{Date: day("2024-01-01"), AmountGBP: -1000},
+		{Date: day("2024-12-31"), AmountGBP: 1100},
+	})
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.1, rate, 1e-6)
+
+	// Half the money goes in halfway through the year, so the £100 gain was made on less money on average
+	rate, err = performance.XIRR([]performance.CashFlow{
+		{Date: day("2024-01-01"), AmountGBP: -500},
+		{Date: day("2024-07-01"), AmountGBP: -500},
+		{Date: day("2024-12-31"), AmountGBP: 1100},
+	})
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.1349, rate, 1e-3)
+
+	// Losses are negative
+	rate, err = performance.XIRR([]performance.CashFlow{
+		{Date: day("2024-01-01"), AmountGBP: -1000},
+		{Date: day("2024-12-31"), AmountGBP: 800},
+	})
+	assert.NoError(t, err)
+	assert.InDelta(t, -0.2, rate, 1e-6)
+}
+
+func TestXIRR_NoSolution(t *testing.T) {
+	_, err := performance.XIRR([]performance.CashFlow{
+		{Date: day("2024-01-01"), AmountGBP: -1000},
+		{Date: day("2024-12-31"), AmountGBP: -100},
+	})
+	assert.ErrorIs(t, err, performance.ErrNoSolution)
+
+	// Money can't have grown if it was paid back the day it went in
+	_, err = performance.XIRR([]performance.CashFlow{
+		{Date: day("2024-01-01"), AmountGBP: -1000},
+		{Date: day("2024-01-01"), AmountGBP: 1100},
+	})
+	assert.ErrorIs(t, err, performance.ErrNoSolution)
+
+	_, err = performance.XIRR(nil)
+	assert.ErrorIs(t, err, performance.ErrNoSolution)
+}
+
+func TestPeriod(t *testing.T) {
+	today := day("2025-03-31")
+
+	// Months that are shorter than today's date open on their last day
+	assert.Equal(t, day("2025-02-28"), performance.OneMonth.Opening(today))
+	assert.Equal(t, day("2024-12-31"), performance.ThreeMonths.Opening(today))
+	assert.Equal(t, day("2024-03-31"), performance.OneYear.Opening(today))
+	assert.True(t, performance.SinceInception.Opening(today).IsZero())
+
+	assert.True(t, performance.OneYear.Valid())
+	assert.False(t, performance.Period("5Y").Valid())
+}
+
+func TestMoneyWeightedReturn(t *testing.T) {
+	// Under a year the return is over the period rather than annualised
+	rate, err := performance.MoneyWeightedReturn([]performance.CashFlow{
+		{Date: day("2025-03-03"), AmountGBP: -1000},
+		{Date: day("2025-03-10"), AmountGBP: 1050},
+	})
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.05, rate, 1e-6)
+
+	// From a year it is annualised
+	rate, err = performance.MoneyWeightedReturn([]performance.CashFlow{
+		{Date: day("2023-01-01"), AmountGBP: -1000},
+		{Date: day("2025-01-01"), AmountGBP: 1210},
+	})
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.1, rate, 1e-3)
+
+	_, err = performance.MoneyWeightedReturn([]performance.CashFlow{
+		{Date: day("2025-03-03"), AmountGBP: -1000},
+		{Date: day("2025-03-03"), AmountGBP: 1050},
+	})
+	assert.ErrorIs(t, err, performance.ErrNoSolution)
+}
+
+func TestPeriodReturn(t *testing.T) {
+	levels := []performance.Level{
+		{At: day("2025-03-03").Add(12 * time.Hour), Value: 100},
+		{At: day("2025-03-04").Add(12 * time.Hour), Value: 110},
+		{At: day("2025-03-06").Add(12 * time.Hour), Value: 99},
+	}
+
+	// Measured from the end of the opening day
+	r, ok := performance.PeriodReturn(levels, day("2025-03-04"), day("2025-03-06"))
+	assert.True(t, ok)
+	assert.InDelta(t, -0.1, r, 1e-9)
+
+	// The closing day is the last level by its end
+	r, ok = performance.PeriodReturn(levels, day("2025-03-02"), day("2025-03-05"))
+	assert.True(t, ok)
+	assert.InDelta(t, 0.1, r, 1e-9)
+
+	// Nothing has moved since the last level
+	r, ok = performance.PeriodReturn(levels, day("2025-03-07"), day("2025-03-08"))
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, r)
+
+	_, ok = performance.PeriodReturn(levels, day("2025-03-01"), day("2025-03-02"))
+	assert.False(t, ok)
+}
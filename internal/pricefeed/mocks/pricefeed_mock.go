@@ -0,0 +1,82 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/jautyw/isa-investment-funds/internal/pricefeed (interfaces: Store)
+
+// Package pricefeed is a generated GoMock package.
+package pricefeed
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	schema "github.com/jautyw/isa-investment-funds/internal/schema"
+	storage "github.com/jautyw/isa-investment-funds/internal/storage"
+)
+
+// MockStore is a mock of Store interface.
+type MockStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockStoreMockRecorder
+}
+
+// MockStoreMockRecorder is the mock recorder for MockStore.
+type MockStoreMockRecorder struct {
+	mock *MockStore
+}
+
+// NewMockStore creates a new mock instance.
+func NewMockStore(ctrl *gomock.Controller) *MockStore {
+	mock := &MockStore{ctrl: ctrl}
+	mock.recorder = &MockStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStore) EXPECT() *MockStoreMockRecorder {
+	return m.recorder
+}
+
+// GetFundIDsByCode mocks base method.
+func (m *MockStore) GetFundIDsByCode(arg0 context.Context) (map[string][]uint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFundIDsByCode", arg0)
+	ret0, _ := ret[0].(map[string][]uint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFundIDsByCode indicates an expected call of GetFundIDsByCode.
+func (mr *MockStoreMockRecorder) GetFundIDsByCode(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFundIDsByCode", reflect.TypeOf((*MockStore)(nil).GetFundIDsByCode), arg0)
+}
+
+// GetFundPriceAsOf mocks base method.
+func (m *MockStore) GetFundPriceAsOf(arg0 context.Context, arg1 uint, arg2 time.Time) (*storage.FundPrice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFundPriceAsOf", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*storage.FundPrice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFundPriceAsOf indicates an expected call of GetFundPriceAsOf.
+func (mr *MockStoreMockRecorder) GetFundPriceAsOf(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFundPriceAsOf", reflect.TypeOf((*MockStore)(nil).GetFundPriceAsOf), arg0, arg1, arg2)
+}
+
+// UpsertFundPrices mocks base method.
+func (m *MockStore) UpsertFundPrices(arg0 context.Context, arg1 []schema.FundPrices) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertFundPrices", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertFundPrices indicates an expected call of UpsertFundPrices.
+func (mr *MockStoreMockRecorder) UpsertFundPrices(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertFundPrices", reflect.TypeOf((*MockStore)(nil).UpsertFundPrices), arg0, arg1)
+}
@@ -0,0 +1,263 @@
+//go:generate mockgen -destination=./mocks/pricefeed_mock.go -package pricefeed github.com/jautyw/isa-investment-funds/internal/pricefeed Store
+package pricefeed
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+	"github.com/jautyw/isa-investment-funds/internal/taxyear"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Importer loads the daily NAV files dropped by the pricing team into the fund price history
+type Importer struct {
+	Store   Store
+	Logger  *zap.Logger
+	maxMove float64
+}
+
+// Option configures optional behaviour of the Importer
+type Option func(*Importer)
+
+// WithMaxMove sets the largest day-on-day move in a fund's price, as a fraction of the previous price, that is
+// accepted without being rejected as a likely error.
+func WithMaxMove(maxMove float64) Option {
+	return func(i *Importer) {
+		i.maxMove = maxMove
+	}
+}
+
+// NewImporter will instantiate a new instance of the Importer
+func NewImporter(s Store, l *zap.Logger, opts ...Option) *Importer {
+	i := &Importer{
+		Store:   s,
+		Logger:  l,
+		maxMove: DefaultMaxMove,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Store represents the price history the Importer reads and writes
+type Store interface {
+	GetFundIDsByCode(ctx context.Context) (map[string][]uint, error)
+	GetFundPriceAsOf(ctx context.Context, fundID uint, at time.Time) (*storage.FundPrice, error)
+	UpsertFundPrices(ctx context.Context, prices []schema.FundPrices) error
+}
+
+const (
+	ErrReadingFile     = "error reading price file"
+	ErrImportingPrices = "error importing prices"
+
+	// dateLayout is the format of the date column
+	dateLayout = "2006-01-02"
+	// valuationHour is when funds are valued each day in UK time, a NAV dated that day is the price at this point
+	valuationHour = 12
+	// DefaultMaxMove rejects prices that have moved by more than a fifth since the previous valuation point
+	DefaultMaxMove = 0.2
+
+	ReasonMalformedRow  = "row must have a code, date and price"
+	ReasonInvalidDate   = "date must be YYYY-MM-DD"
+	ReasonInvalidPrice  = "price is not a number"
+	ReasonUnknownCode   = "unknown fund code"
+	ReasonNonPositive   = "price must be greater than zero"
+	ReasonDuplicateDate = "fund is priced more than once on this date"
+	ReasonLargeMove     = "price moved more than allowed since the previous valuation point"
+)
+
+// Report summarises an import. Rows are numbered by their line in the file.
+type Report struct {
+	RowsRead   int
+	Accepted   int
+	Rejections []Rejection
+}
+
+// Rejection is a row of the file that wasn't loaded and why
+type Rejection struct {
+	Line   int
+	Code   string
+	Date   string
+	Price  string
+	Reason string
+}
+
+// row is a line of the file that has been parsed
+type row struct {
+	line     int
+	code     string
+	date     string
+	price    string
+	valuedAt time.Time
+	priceGBP float64
+}
+
+// Import reads a CSV of fund code, date and price, with an optional header, and upserts the rows that pass validation
+// into the fund price history. Rows that fail are left out and listed in the report rather than failing the import, an
+// error is only returned if the file can't be read or the store fails.
+func (i *Importer) Import(ctx context.Context, r io.Reader) (*Report, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, ErrReadingFile)
+	}
+
+	fundIDs, err := i.Store.GetFundIDsByCode(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrImportingPrices)
+	}
+
+	report := &Report{}
+	reject := func(r row, reason string) {
+		report.Rejections = append(report.Rejections, Rejection{Line: r.line, Code: r.code, Date: r.date, Price: r.price, Reason: reason})
+	}
+
+	var rows []row
+	for n, record := range records {
+		if n == 0 && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "code") {
+			continue
+		}
+		report.RowsRead++
+
+		r, reason := parseRow(n+1, record)
+		if reason == "" {
+			if _, ok := fundIDs[r.code]; !ok {
+				reason = ReasonUnknownCode
+			} else if r.priceGBP <= 0 {
+				reason = ReasonNonPositive
+			}
+		}
+		if reason != "" {
+			reject(r, reason)
+			continue
+		}
+
+		rows = append(rows, r)
+	}
+
+	// A fund priced twice for the same date is ambiguous, so neither price is trusted.
+	seen := map[string]int{}
+	for _, r := range rows {
+		seen[r.code+" "+r.date]++
+	}
+
+	var unique []row
+	for _, r := range rows {
+		if seen[r.code+" "+r.date] > 1 {
+			reject(r, ReasonDuplicateDate)
+			continue
+		}
+		unique = append(unique, r)
+	}
+
+	// Each price is compared with the one before it, from earlier in the file or else already held, so rows are worked
+	// through in date order for each fund.
+	sort.SliceStable(unique, func(a, b int) bool {
+		if unique[a].code != unique[b].code {
+			return unique[a].code < unique[b].code
+		}
+		return unique[a].valuedAt.Before(unique[b].valuedAt)
+	})
+
+	var prices []schema.FundPrices
+	previous := map[string]float64{}
+	for _, r := range unique {
+		ids := fundIDs[r.code]
+
+		prev, ok := previous[r.code]
+		if !ok {
+			price, err := i.Store.GetFundPriceAsOf(ctx, ids[0], r.valuedAt.Add(-time.Nanosecond))
+			if err != nil && !errors.Is(err, storage.ErrFundPriceNotFound) {
+				return nil, errors.Wrap(err, ErrImportingPrices)
+			}
+			if price != nil {
+				prev = price.PriceGBP
+			}
+		}
+
+		if prev > 0 && math.Abs(r.priceGBP/prev-1) > i.maxMove {
+			reject(r, fmt.Sprintf("%s (%.4f to %.4f)", ReasonLargeMove, prev, r.priceGBP))
+			continue
+		}
+
+		previous[r.code] = r.priceGBP
+		for _, id := range ids {
+			prices = append(prices, schema.FundPrices{FundID: id, ValuationPoint: r.valuedAt, PriceGBP: r.priceGBP})
+		}
+		report.Accepted++
+	}
+
+	if err := i.Store.UpsertFundPrices(ctx, prices); err != nil {
+		return nil, errors.Wrap(err, ErrImportingPrices)
+	}
+
+	sort.SliceStable(report.Rejections, func(a, b int) bool {
+		return report.Rejections[a].Line < report.Rejections[b].Line
+	})
+
+	i.Logger.Info(fmt.Sprintf("imported %d of %d prices, %d rejected", report.Accepted, report.RowsRead, len(report.Rejections)))
+
+	return report, nil
+}
+
+// parseRow reads a record of the file, returning why it is malformed if it is.
+func parseRow(line int, record []string) (row, string) {
+	r := row{line: line}
+	for n, field := range record {
+		record[n] = strings.TrimSpace(field)
+	}
+	if len(record) > 0 {
+		r.code = record[0]
+	}
+	if len(record) > 1 {
+		r.date = record[1]
+	}
+	if len(record) > 2 {
+		r.price = record[2]
+	}
+
+	if len(record) != 3 || r.code == "" {
+		return r, ReasonMalformedRow
+	}
+
+	date, err := time.ParseInLocation(dateLayout, r.date, taxyear.London)
+	if err != nil {
+		return r, ReasonInvalidDate
+	}
+	r.valuedAt = time.Date(date.Year(), date.Month(), date.Day(), valuationHour, 0, 0, 0, taxyear.London)
+
+	r.priceGBP, err = strconv.ParseFloat(r.price, 64)
+	if err != nil || math.IsNaN(r.priceGBP) || math.IsInf(r.priceGBP, 0) {
+		return r, ReasonInvalidPrice
+	}
+
+	return r, ""
+}
+
+// Write prints the report, listing each rejected row under a summary of the import.
+func (r *Report) Write(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "read %d rows, accepted %d, rejected %d\n", r.RowsRead, r.Accepted, len(r.Rejections)); err != nil {
+		return err
+	}
+
+	for _, rej := range r.Rejections {
+		if _, err := fmt.Fprintf(w, "line %d: %s,%s,%s: %s\n", rej.Line, rej.Code, rej.Date, rej.Price, rej.Reason); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package pricefeed_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/jautyw/isa-investment-funds/internal/pricefeed"
+	mocks "github.com/jautyw/isa-investment-funds/internal/pricefeed/mocks"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"strings"
+	"testing"
+	"time"
+)
+
+// valuationPoint is midday UK time on the date, when funds are valued
+func valuationPoint(date string) time.Time {
+	london, _ := time.LoadLocation("Europe/London")
+	d, _ := time.ParseInLocation("2006-01-02", date, london)
+	return d.Add(12 * time.Hour)
+}
+
+func TestImporter_NewImporter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	i := pricefeed.NewImporter(ms, zap.NewNop())
+	assert.NotNil(t, i)
+}
+
+func TestImporter_Import(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	i := pricefeed.NewImporter(ms, zap.NewNop())
+
+	ctx := context.Background()
+	file := `code,date,price
+V3AM,2025-03-04,4.95
+V3AM,2025-03-03,4.90
+V3AB,2025-03-03,5.10
+`
+
+	ms.EXPECT().GetFundIDsByCode(ctx).Return(map[string][]uint{"V3AM": {1, 3}, "V3AB": {2}}, nil).Times(1)
+	// Only the first price of each fund is compared with the history, the rest with the row before them
+	ms.EXPECT().GetFundPriceAsOf(ctx, uint(1), valuationPoint("2025-03-03").Add(-time.Nanosecond)).Return(&storage.FundPrice{PriceGBP: 4.88}, nil).Times(1)
+	ms.EXPECT().GetFundPriceAsOf(ctx, uint(2), valuationPoint("2025-03-03").Add(-time.Nanosecond)).Return(nil, storage.ErrFundPriceNotFound).Times(1)
+	ms.EXPECT().UpsertFundPrices(ctx, []schema.FundPrices{
+		{FundID: 2, ValuationPoint: valuationPoint("2025-03-03"), PriceGBP: 5.10},
+		{FundID: 1, ValuationPoint: valuationPoint("2025-03-03"), PriceGBP: 4.90},
+		{FundID: 3, ValuationPoint: valuationPoint("2025-03-03"), PriceGBP: 4.90},
+		{FundID: 1, ValuationPoint: valuationPoint("2025-03-04"), PriceGBP: 4.95},
+		{FundID: 3, ValuationPoint: valuationPoint("2025-03-04"), PriceGBP: 4.95},
+	}).Return(nil).Times(1)
+
+	report, err := i.Import(ctx, strings.NewReader(file))
+	assert.NoError(t, err)
+	assert.Equal(t, &pricefeed.Report{RowsRead: 3, Accepted: 3}, report)
+}
+
+func TestImporter_ImportRejections(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	i := pricefeed.NewImporter(ms, zap.NewNop(), pricefeed.WithMaxMove(0.1))
+
+	ctx := context.Background()
+	file := `V3AM,2025-03-03,4.90
+XXXX,2025-03-03,1.00
+V3AM,2025-03-04,-4.95
+V3AB,2025-03-03,5.10
+V3AB,2025-03-03,5.12
+V3AM,03/03/2025,4.90
+V3AM,2025-03-05,abc
+V3AM,2025-03-06
+V3AM,2025-03-07,6.00
+`
+
+	ms.EXPECT().GetFundIDsByCode(ctx).Return(map[string][]uint{"V3AM": {1}, "V3AB": {2}}, nil).Times(1)
+	ms.EXPECT().GetFundPriceAsOf(ctx, uint(1), gomock.Any()).Return(nil, storage.ErrFundPriceNotFound).Times(1)
+	ms.EXPECT().UpsertFundPrices(ctx, []schema.FundPrices{
+		{FundID: 1, ValuationPoint: valuationPoint("2025-03-03"), PriceGBP: 4.90},
+	}).Return(nil).Times(1)
+
+	report, err := i.Import(ctx, strings.NewReader(file))
+	assert.NoError(t, err)
+	assert.Equal(t, 9, report.RowsRead)
+	assert.Equal(t, 1, report.Accepted)
+
+	reasons := map[int]string{}
+	for _, r := range report.Rejections {
+		reasons[r.Line] = r.Reason
+	}
+	assert.Equal(t, map[int]string{
+		2: pricefeed.ReasonUnknownCode,
+		3: pricefeed.ReasonNonPositive,
+		4: pricefeed.ReasonDuplicateDate,
+		5: pricefeed.ReasonDuplicateDate,
+		6: pricefeed.ReasonInvalidDate,
+		7: pricefeed.ReasonInvalidPrice,
+		8: pricefeed.ReasonMalformedRow,
+		9: pricefeed.ReasonLargeMove + " (4.9000 to 6.0000)",
+	}, reasons)
+
+	var out bytes.Buffer
+	err = report.Write(&out)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "read 9 rows, accepted 1, rejected 8")
+	assert.Contains(t, out.String(), "line 2: XXXX,2025-03-03,1.00: unknown fund code")
+}
+
+func TestImporter_ImportStoreError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	i := pricefeed.NewImporter(ms, zap.NewNop())
+
+	ctx := context.Background()
+
+	ms.EXPECT().GetFundIDsByCode(ctx).Return(map[string][]uint{"V3AM": {1}}, nil).Times(1)
+	ms.EXPECT().GetFundPriceAsOf(ctx, uint(1), gomock.Any()).Return(nil, storage.ErrFundPriceNotFound).Times(1)
+	ms.EXPECT().UpsertFundPrices(ctx, gomock.Any()).Return(errors.New("db unavailable")).Times(1)
+
+	_, err := i.Import(ctx, strings.NewReader("V3AM,2025-03-03,4.90\n"))
+	assert.ErrorContains(t, err, pricefeed.ErrImportingPrices)
+}
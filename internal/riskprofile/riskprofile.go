@@ -0,0 +1,107 @@
+package riskprofile
+
+import (
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidAnswers is returned when a question is left unanswered, or answered with an option it doesn't have
+var ErrInvalidAnswers = errors.New("invalid questionnaire answers")
+
+const (
+	// Totals up to lowMaxScore profile the customer as low risk, and up to mediumMaxScore as medium risk, anything
+	// above is high risk.
+	lowMaxScore    = 5
+	mediumMaxScore = 10
+)
+
+// Question is asked of every customer, whose answer is the number of the option they chose, counting from 1. Options
+// run from the most cautious to the most adventurous.
+type Question struct {
+	ID      string
+	Text    string
+	Options []string
+}
+
+// Questions is the questionnaire, each answer scoring from 0 for the first option up to 3 for the last.
+var Questions = []Question{
+	{
+		ID:   "horizon",
+		Text: "How long do you plan to keep this money invested?",
+		Options: []string{
+			"Less than 2 years",
+			"2 to 5 years",
+			"5 to 10 years",
+			"More than 10 years",
+		},
+	},
+	{
+		ID:   "fall",
+		Text: "If your investments fell by 20% in a few months, what would you do?",
+		Options: []string{
+			"Sell everything",
+			"Sell some",
+			"Do nothing",
+			"Invest more",
+		},
+	},
+	{
+		ID:   "experience",
+		Text: "How much experience do you have of investing in funds or shares?",
+		Options: []string{
+			"None",
+			"A little",
+			"Some",
+			"A lot",
+		},
+	},
+	{
+		ID:   "reliance",
+		Text: "How much would a loss on this money affect your day-to-day finances?",
+		Options: []string{
+			"I rely on it",
+			"It would affect me a lot",
+			"It would affect me a little",
+			"It wouldn't affect me",
+		},
+	},
+	{
+		ID:   "goal",
+		Text: "Which best describes what you want from this investment?",
+		Options: []string{
+			"Protect what I have",
+			"Steady growth with small ups and downs",
+			"Growth with some large ups and downs",
+			"The most growth, whatever the ups and downs",
+		},
+	},
+}
+
+// Score totals the answers to the questionnaire and bands the total into the customer's risk profile. Every question
+// has to be answered, and nothing else.
+func Score(answers map[string]int) (schema.RiskScore, error) {
+	if len(answers) != len(Questions) {
+		return "", errors.Wrapf(ErrInvalidAnswers, "all %d questions must be answered", len(Questions))
+	}
+
+	var total int
+	for _, q := range Questions {
+		answer, ok := answers[q.ID]
+		if !ok {
+			return "", errors.Wrapf(ErrInvalidAnswers, "%s is not answered", q.ID)
+		}
+		if answer < 1 || answer > len(q.Options) {
+			return "", errors.Wrapf(ErrInvalidAnswers, "%s must be answered from 1 to %d", q.ID, len(q.Options))
+		}
+		total += answer - 1
+	}
+
+	switch {
+	case total <= lowMaxScore:
+		return schema.Low, nil
+	case total <= mediumMaxScore:
+		return schema.Medium, nil
+	default:
+		return schema.High, nil
+	}
+}
@@ -0,0 +1,43 @@
+package riskprofile_test
+
+import (
+	"github.com/jautyw/isa-investment-funds/internal/riskprofile"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	tests := map[string]struct {
+		answers map[string]int
+		profile schema.RiskScore
+	}{
+		"most cautious":    {answers: map[string]int{"horizon": 1, "fall": 1, "experience": 1, "reliance": 1, "goal": 1}, profile: schema.Low},
+		"top of low":       {answers: map[string]int{"horizon": 2, "fall": 2, "experience": 2, "reliance": 2, "goal": 2}, profile: schema.Low},
+		"medium":           {answers: map[string]int{"horizon": 2, "fall": 2, "experience": 2, "reliance": 2, "goal": 3}, profile: schema.Medium},
+		"top of medium":    {answers: map[string]int{"horizon": 3, "fall": 3, "experience": 3, "reliance": 3, "goal": 3}, profile: schema.Medium},
+		"high":             {answers: map[string]int{"horizon": 4, "fall": 3, "experience": 3, "reliance": 3, "goal": 3}, profile: schema.High},
+		"most adventurous": {answers: map[string]int{"horizon": 4, "fall": 4, "experience": 4, "reliance": 4, "goal": 4}, profile: schema.High},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			profile, err := riskprofile.Score(tt.answers)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.profile, profile)
+		})
+	}
+}
+
+func TestScoreInvalidAnswers(t *testing.T) {
+	_, err := riskprofile.Score(map[string]int{"horizon": 1, "fall": 1, "experience": 1, "reliance": 1})
+	assert.ErrorIs(t, err, riskprofile.ErrInvalidAnswers)
+
+	_, err = riskprofile.Score(map[string]int{"horizon": 1, "fall": 1, "experience": 1, "reliance": 1, "colour": 1})
+	assert.ErrorIs(t, err, riskprofile.ErrInvalidAnswers)
+	assert.ErrorContains(t, err, "goal is not answered")
+
+	_, err = riskprofile.Score(map[string]int{"horizon": 5, "fall": 1, "experience": 1, "reliance": 1, "goal": 1})
+	assert.ErrorIs(t, err, riskprofile.ErrInvalidAnswers)
+	assert.ErrorContains(t, err, "horizon must be answered from 1 to 4")
+}
@@ -5,6 +5,15 @@ import "time"
 type RiskScore string
 type OrderType string
 type CustomerType string
+type OrderStatus string
+type ISAType string
+type WithdrawalReason string
+type LedgerEntryType string
+type TransferDirection string
+type TransferMethod string
+type TransferStatus string
+type CustomerStatus string
+type Residency string
 
 const (
 	Low    RiskScore = "low"
@@ -16,29 +25,258 @@ const (
 
 	Retail    CustomerType = "retail"
 	Workplace CustomerType = "workplace"
+
+	Pending   OrderStatus = "pending"
+	Executed  OrderStatus = "executed"
+	Cancelled OrderStatus = "cancelled"
+	Rejected  OrderStatus = "rejected"
+
+	StocksAndShares ISAType = "stocks_and_shares"
+	Cash            ISAType = "cash"
+	Lifetime        ISAType = "lifetime"
+	Junior          ISAType = "junior"
+
+	FirstHome       WithdrawalReason = "first_home"
+	TerminalIllness WithdrawalReason = "terminal_illness"
+	AgedSixty       WithdrawalReason = "aged_60"
+	Unauthorised    WithdrawalReason = "unauthorised"
+
+	GovernmentBonus  LedgerEntryType = "government_bonus"
+	WithdrawalCharge LedgerEntryType = "withdrawal_charge"
+
+	TransferIn  TransferDirection = "in"
+	TransferOut TransferDirection = "out"
+
+	CashTransfer     TransferMethod = "cash"
+	InSpecieTransfer TransferMethod = "in_specie"
+
+	TransferRequested  TransferStatus = "requested"
+	TransferInProgress TransferStatus = "in_progress"
+	TransferCompleted  TransferStatus = "completed"
+	TransferRejected   TransferStatus = "rejected"
+	TransferCancelled  TransferStatus = "cancelled"
+
+	CustomerActive    CustomerStatus = "active"
+	CustomerSuspended CustomerStatus = "suspended"
+	CustomerClosed    CustomerStatus = "closed"
+
+	UKResident    Residency = "uk"
+	NonUKResident Residency = "non_uk"
 )
 
-// Funds refers to the schema to be used for the funds table in postgres
+// orderStatusTransitions lists the statuses an order is allowed to move to from its current status. Executed,
+// cancelled and rejected are terminal.
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	Pending: {Executed, Cancelled, Rejected},
+}
+
+// CanTransitionTo reports whether an order in this status may be moved to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// transferStatusTransitions lists the statuses a transfer is allowed to move to from its current status. Completed,
+// rejected and cancelled are terminal.
+var transferStatusTransitions = map[TransferStatus][]TransferStatus{
+	TransferRequested:  {TransferInProgress, TransferRejected, TransferCancelled},
+	TransferInProgress: {TransferCompleted, TransferRejected},
+}
+
+// CanTransitionTo reports whether a transfer in this status may be moved to next.
+func (s TransferStatus) CanTransitionTo(next TransferStatus) bool {
+	for _, allowed := range transferStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Valid reports whether s is one of the statuses a transfer can be in.
+func (s TransferStatus) Valid() bool {
+	switch s {
+	case TransferRequested, TransferInProgress, TransferCompleted, TransferRejected, TransferCancelled:
+		return true
+	}
+	return false
+}
+
+// Valid reports whether t is one of the ISA wrappers we offer.
+func (t ISAType) Valid() bool {
+	switch t {
+	case StocksAndShares, Cash, Lifetime, Junior:
+		return true
+	}
+	return false
+}
+
+// Authorised reports whether a lifetime ISA can be withdrawn from for this reason without a charge.
+func (r WithdrawalReason) Authorised() bool {
+	switch r {
+	case FirstHome, TerminalIllness, AgedSixty:
+		return true
+	}
+	return false
+}
+
+// riskRank orders the risk scores from the most cautious
+var riskRank = map[RiskScore]int{Low: 1, Medium: 2, High: 3}
+
+// Valid reports whether r is a risk score funds and customers are given.
+func (r RiskScore) Valid() bool {
+	_, ok := riskRank[r]
+	return ok
+}
+
+// Exceeds reports whether a fund with this risk score is riskier than a customer with the profile should hold.
+func (r RiskScore) Exceeds(profile RiskScore) bool {
+	return riskRank[r] > riskRank[profile]
+}
+
+// Customers refers to the schema to be used for the customers table in postgres
+type Customers struct {
+	// CustomerID is not generated as accounts are opened elsewhere
+	CustomerID uint `gorm:"primaryKey;autoIncrement:false"`
+	// CustomerType decides which funds the customer is offered
+	CustomerType CustomerType `gorm:"column:customer_type;not null;type:varchar(50)"`
+	// EmployerID links a workplace customer to the employer whose approved funds they are offered
+	EmployerID *uint `gorm:"column:employer_id;uniqueIndex:idx_customers_employee_reference,priority:1"`
+	// EmployeeReference identifies a workplace customer in their employer's payroll files
+	EmployeeReference *string `gorm:"column:employee_reference;uniqueIndex:idx_customers_employee_reference,priority:2"`
+	// DefaultFundCode is the fund a workplace customer's payroll contributions are invested in
+	DefaultFundCode *string `gorm:"column:default_fund_code"`
+	// RiskProfile is scored from the customer's risk questionnaire, and is unset until they have completed it
+	RiskProfile    *RiskScore `gorm:"column:risk_profile;type:varchar(50)"`
+	RiskProfiledAt *time.Time `gorm:"column:risk_profiled_at"`
+	DateOfBirth    time.Time  `gorm:"column:date_of_birth;not null;type:date"`
+	Residency      Residency  `gorm:"column:residency;not null;type:varchar(50)"`
+	// Status decides whether the customer can trade, only active customers can
+	Status    CustomerStatus `gorm:"column:status;not null;type:varchar(50)"`
+	CreatedAt time.Time      `gorm:"column:created_at;not null"`
+}
+
+// Employers refers to the schema to be used for the employers table in postgres. Employers offer a workplace ISA to
+// their employees, who are linked to them as workplace customers.
+type Employers struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"column:name;not null"`
+}
+
+// EmployerFunds refers to the schema to be used for the employer_funds table in postgres. It lists the workplace funds
+// each employer has approved for its employees, along with the annual charge it has negotiated on each.
+type EmployerFunds struct {
+	EmployerID          uint    `gorm:"primaryKey;autoIncrement:false"`
+	FundID              uint    `gorm:"primaryKey;autoIncrement:false"`
+	AnnualChargePercent float64 `gorm:"column:annual_charge_percent;not null"`
+}
+
+// Funds refers to the schema to be used for the funds table in postgres. Prices are kept in fund_prices. BenchmarkID
+// is the index the fund's performance is measured against, if it has one.
 type Funds struct {
 	ID           uint         `gorm:"primaryKey"`
 	Name         string       `gorm:"column:name;not null"`
 	Description  string       `gorm:"column:description"`
 	Code         string       `gorm:"column:code;not null"`
-	AmountGBP    float64      `gorm:"column:amount_gbp;not null"`
 	CustomerType CustomerType `gorm:"column:customer_type;not null"`
 	RiskScore    RiskScore    `gorm:"column:risk_score;not null;type:varchar(50)"`
-	LastUpdated  time.Time    `gorm:"column:last_updated"`
+	BenchmarkID  *uint        `gorm:"column:benchmark_id"`
 }
 
-// Funds Orders to the schema to be used for the orders table in postgres
+// Benchmarks refers to the schema to be used for the benchmarks table in postgres. A benchmark is an index funds are
+// measured against, identified by its code, and its levels are kept in benchmark_levels.
+type Benchmarks struct {
+	ID   uint   `gorm:"primaryKey"`
+	Code string `gorm:"column:code;not null;uniqueIndex"`
+	Name string `gorm:"column:name;not null"`
+}
+
+// BenchmarkLevels refers to the schema to be used for the benchmark_levels table in postgres. A benchmark has a single
+// closing level on each date.
+type BenchmarkLevels struct {
+	BenchmarkID uint      `gorm:"primaryKey;autoIncrement:false"`
+	Date        time.Time `gorm:"primaryKey;column:date;type:date"`
+	Level       float64   `gorm:"column:level;not null"`
+}
+
+// FundPrices refers to the schema to be used for the fund_prices table in postgres. A fund is priced once at each
+// valuation point, and the latest of these is its current price.
+type FundPrices struct {
+	FundID         uint      `gorm:"primaryKey;autoIncrement:false"`
+	ValuationPoint time.Time `gorm:"primaryKey;column:valuation_point"`
+	PriceGBP       float64   `gorm:"column:price_gbp;not null"`
+}
+
+// Orders refers to the schema to be used for the orders table in postgres
 type Orders struct {
 	OrderID           uint      `gorm:"primaryKey"`
 	OrderType         OrderType `gorm:"column:order_type;not null;type:varchar(50)"`
-	CustomerID        uint      `gorm:"column:customer_id;not null"`
+	CustomerID        uint      `gorm:"column:customer_id;not null;uniqueIndex:idx_orders_customer_idempotency_key,priority:1"`
+	FundID            uint      `gorm:"column:fund_id"`
 	Name              string    `gorm:"column:name;not null"`
 	Description       string    `gorm:"column:description"`
 	Code              string    `gorm:"column:code;not null"`
 	Shares            float64   `gorm:"column:total_shares;not null"`
 	PurchasedValueGBP float64   `gorm:"column:purchased_value_gbp;not null"`
 	OrderTime         time.Time `gorm:"column:order_time;not null"`
+	// Status defaults to executed so that rows created before orders were queued are still treated as filled
+	Status            OrderStatus `gorm:"column:status;not null;type:varchar(50);default:'executed'"`
+	ExecutionTime     *time.Time  `gorm:"column:execution_time"`
+	ExecutionPriceGBP *float64    `gorm:"column:execution_price_gbp"`
+	// IdempotencyKey is unique per customer
+	IdempotencyKey *string `gorm:"column:idempotency_key;uniqueIndex:idx_orders_customer_idempotency_key,priority:2"`
+	// RequestHash identifies the request that first used the IdempotencyKey
+	RequestHash string `gorm:"column:request_hash"`
+	// ISAType is the wrapper the order was placed in, rows created before other wrappers were offered are stocks and
+	// shares
+	ISAType ISAType `gorm:"column:isa_type;not null;type:varchar(50);default:'stocks_and_shares'"`
+	// WithdrawalReason is only set on lifetime ISA sells
+	WithdrawalReason *WithdrawalReason `gorm:"column:withdrawal_reason;type:varchar(50)"`
+	// BookCostGBP and RealisedGainGBP are set when a sell executes, from the average cost of the holding at the time
+	BookCostGBP     *float64 `gorm:"column:book_cost_gbp"`
+	RealisedGainGBP *float64 `gorm:"column:realised_gain_gbp"`
+	// RiskOverride is set on a buy of a fund riskier than the customer's RiskProfile at the time, which the customer
+	// acknowledged before it was placed
+	RiskOverride bool       `gorm:"column:risk_override;not null;default:false"`
+	RiskProfile  *RiskScore `gorm:"column:risk_profile;type:varchar(50)"`
+}
+
+// LifetimeISAAccounts refers to the schema to be used for the lifetime_isa_accounts table in postgres. A customer can
+// only hold a single lifetime ISA.
+type LifetimeISAAccounts struct {
+	CustomerID uint      `gorm:"primaryKey;autoIncrement:false"`
+	OpenedAt   time.Time `gorm:"column:opened_at;not null"`
+}
+
+// LifetimeISALedger refers to the schema to be used for the lifetime_isa_ledger table in postgres. Government bonuses
+// and withdrawal charges are recorded against the order they arose from, at most one of each type per order.
+type LifetimeISALedger struct {
+	EntryID    uint            `gorm:"primaryKey"`
+	CustomerID uint            `gorm:"column:customer_id;not null;index"`
+	OrderID    uint            `gorm:"column:order_id;not null;uniqueIndex:idx_lifetime_isa_ledger_order_entry_type,priority:1"`
+	EntryType  LedgerEntryType `gorm:"column:entry_type;not null;type:varchar(50);uniqueIndex:idx_lifetime_isa_ledger_order_entry_type,priority:2"`
+	AmountGBP  float64         `gorm:"column:amount_gbp;not null"`
+	CreatedAt  time.Time       `gorm:"column:created_at;not null"`
+}
+
+// Transfers refers to the schema to be used for the transfers table in postgres. A transfer moves an ISA to or from
+// another provider, splitting the amount between subscriptions made in the tax year it was requested and those made in
+// earlier years.
+type Transfers struct {
+	TransferID             uint              `gorm:"primaryKey"`
+	CustomerID             uint              `gorm:"column:customer_id;not null;index"`
+	Direction              TransferDirection `gorm:"column:direction;not null;type:varchar(50)"`
+	ISAType                ISAType           `gorm:"column:isa_type;not null;type:varchar(50)"`
+	Method                 TransferMethod    `gorm:"column:method;not null;type:varchar(50)"`
+	Provider               string            `gorm:"column:provider;not null"`
+	CurrentYearAmountGBP   float64           `gorm:"column:current_year_amount_gbp;not null"`
+	PreviousYearsAmountGBP float64           `gorm:"column:previous_years_amount_gbp;not null"`
+	Status                 TransferStatus    `gorm:"column:status;not null;type:varchar(50)"`
+	RequestedAt            time.Time         `gorm:"column:requested_at;not null"`
+	UpdatedAt              time.Time         `gorm:"column:updated_at;not null"`
+	CompletedAt            *time.Time        `gorm:"column:completed_at"`
 }
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+	"github.com/jautyw/isa-investment-funds/internal/taxyear"
+	"github.com/pkg/errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// isaTypes lists the ISA wrappers in the order they are reported
+var isaTypes = []schema.ISAType{schema.StocksAndShares, schema.Cash, schema.Lifetime, schema.Junior}
+
+// usage is how a customer has used the allowance of a single ISA wrapper in a tax year.
+type usage struct {
+	subscribed float64
+	withdrawn  float64
+	// replaced is the part of subscribed that replaced earlier withdrawals, so didn't use any allowance
+	replaced float64
+	// replaceable is what has been withdrawn and not yet replaced
+	replaceable float64
+	// transferredIn is the current year subscriptions moved over from other providers
+	transferredIn float64
+}
+
+// used is how much of the allowance the subscriptions have taken up
+func (u *usage) used() float64 {
+	return u.subscribed - u.replaced + u.transferredIn
+}
+
+// allowances holds how the customer has used the allowance of each ISA wrapper in a tax year.
+type allowances map[schema.ISAType]*usage
+
+// isaAllowances works through a tax year's orders in the order they took effect. Withdrawals from a flexible ISA can
+// be paid back in without using any allowance, so a subscription replaces as much of the earlier withdrawals from the
+// same wrapper as it can before the rest of it counts towards the allowance. Subscriptions transferred in from another
+// provider keep using the allowance they took up there.
+func isaAllowances(orders []storage.Order, transfers []storage.Transfer) allowances {
+	a := allowances{}
+	for _, isaType := range isaTypes {
+		a[isaType] = &usage{}
+	}
+
+	ordered := make([]storage.Order, len(orders))
+	copy(ordered, orders)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return allowanceTime(ordered[i]).Before(allowanceTime(ordered[j]))
+	})
+
+	for _, o := range ordered {
+		u, ok := a[o.ISAType]
+		if !ok {
+			continue
+		}
+
+		switch o.OrderType {
+		case schema.Buy:
+			replaced := math.Min(o.AmountGBP, u.replaceable)
+			u.subscribed += o.AmountGBP
+			u.replaced += replaced
+			u.replaceable -= replaced
+		case schema.Sell:
+			u.withdrawn += o.AmountGBP
+			if flexibleISA(o.ISAType) {
+				u.replaceable += o.AmountGBP
+			}
+		}
+	}
+
+	for _, t := range transfers {
+		if u, ok := a[t.ISAType]; ok {
+			u.transferredIn += t.CurrentYearAmountGBP
+		}
+	}
+
+	return a
+}
+
+// currentTaxYearAllowances fetches what the customer has subscribed, withdrawn and transferred in during the current
+// tax year.
+func (s Service) currentTaxYearAllowances(ctx context.Context, customerID int) (allowances, error) {
+	orders, err := s.store.GetCurrentTaxYearOrders(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	storeTransfers, err := s.store.GetTransfers(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := taxyear.For(s.clock.Now())
+	transfers := []storage.Transfer{}
+	for _, t := range storeTransfers {
+		if transferCountsTowardsAllowance(t) && current.Contains(t.RequestedAt) {
+			transfers = append(transfers, t)
+		}
+	}
+
+	return isaAllowances(orders, transfers), nil
+}
+
+// allowanceTime is when an order affects the allowance. Buys subscribe when they are placed whereas a sell is only
+// withdrawn once it has executed.
+func allowanceTime(o storage.Order) time.Time {
+	if o.OrderType == schema.Sell && o.ExecutionTime != nil {
+		return *o.ExecutionTime
+	}
+	return o.PurchaseTime
+}
+
+// overallRemaining is what is left of the allowance shared by the stocks and shares, cash and lifetime ISAs.
+func (a allowances) overallRemaining() float64 {
+	var used float64
+	for isaType, u := range a {
+		if adultISA(isaType) {
+			used += u.used()
+		}
+	}
+	return headroom(isaAnnualGovernmentAllowance, used)
+}
+
+// remaining is how much more can be subscribed to the wrapper this tax year. A lifetime ISA is limited both by its
+// own allowance and by what is left of the overall one, while withdrawals from a flexible ISA can be replaced on top
+// of the overall allowance.
+func (a allowances) remaining(isaType schema.ISAType) float64 {
+	switch isaType {
+	case schema.Junior:
+		return headroom(juniorISAAnnualAllowance, a[schema.Junior].used())
+	case schema.Lifetime:
+		return math.Min(a.overallRemaining(), headroom(lifetimeISAAnnualAllowance, a[schema.Lifetime].used()))
+	default:
+		return a.overallRemaining() + a[isaType].replaceable
+	}
+}
+
+func (a allowances) report() []ISAAllowance {
+	report := make([]ISAAllowance, len(isaTypes))
+	for i, isaType := range isaTypes {
+		u := a[isaType]
+		report[i] = ISAAllowance{
+			ISAType:          isaType,
+			SubscribedGBP:    u.subscribed,
+			WithdrawnGBP:     u.withdrawn,
+			ReplacedGBP:      u.replaced,
+			TransferredInGBP: u.transferredIn,
+			RemainingGBP:     a.remaining(isaType),
+		}
+	}
+	return report
+}
+
+// adultISA reports whether subscriptions to the wrapper count towards the overall allowance. Junior ISAs are held for
+// a child and have an allowance of their own.
+func adultISA(isaType schema.ISAType) bool {
+	return isaType != schema.Junior
+}
+
+// flexibleISA reports whether withdrawals from the wrapper can be replaced in the same tax year. Our stocks and shares
+// and cash ISAs are flexible, lifetime and junior ISAs can't be.
+func flexibleISA(isaType schema.ISAType) bool {
+	return isaType == schema.StocksAndShares || isaType == schema.Cash
+}
+
+// headroom is what is left of limit once used is taken off, never less than zero
+func headroom(limit float64, used float64) float64 {
+	if used >= limit {
+		return 0
+	}
+	return limit - used
+}
+
+// orderISAType validates the wrapper an order is placed into, orders without one go into the stocks and shares ISA.
+func orderISAType(isaType schema.ISAType) (schema.ISAType, error) {
+	if isaType == "" {
+		return schema.StocksAndShares, nil
+	}
+	if !isaType.Valid() {
+		return "", errors.Wrap(ErrInvalidISAType, string(isaType))
+	}
+	return isaType, nil
+}
+
+// GetAllowanceHistory breaks the customer's orders and transfers in down by UK tax year, from the year of their first
+// order up to the current one, newest first. Only the adult ISAs are included as they share the overall allowance.
+func (s Service) GetAllowanceHistory(ctx context.Context, customerID int) (*AllowanceHistory, error) {
+	if _, err := s.customer(ctx, customerID); err != nil {
+		return nil, errors.Wrap(err, ErrGettingHistory)
+	}
+
+	orders, err := s.store.GetOrders(ctx, customerID)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingHistory)
+	}
+
+	transfers, err := s.store.GetTransfers(ctx, customerID)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingHistory)
+	}
+
+	current := taxyear.For(s.clock.Now())
+	byYear := map[string][]storage.Order{}
+	transfersByYear := map[string][]storage.Transfer{}
+
+	// Every year between the first order and today is reported, even those without any activity.
+	first := current
+	if len(orders) > 0 {
+		first = taxyear.For(orders[0].PurchaseTime)
+	}
+	years := []taxyear.Year{}
+	for y := first; !y.Start.After(current.Start); y = y.Next() {
+		years = append(years, y)
+	}
+
+	for _, o := range orders {
+		if !countsTowardsAllowance(o) {
+			continue
+		}
+
+		year := taxyear.For(allowanceTime(o)).String()
+		byYear[year] = append(byYear[year], o)
+	}
+
+	for _, t := range transfers {
+		if !transferCountsTowardsAllowance(t) {
+			continue
+		}
+
+		year := taxyear.For(t.RequestedAt).String()
+		transfersByYear[year] = append(transfersByYear[year], t)
+	}
+
+	history := &AllowanceHistory{TaxYears: make([]TaxYearAllowance, 0, len(years))}
+	for i := len(years) - 1; i >= 0; i-- {
+		y := years[i]
+		a := isaAllowances(byYear[y.String()], transfersByYear[y.String()])
+
+		year := TaxYearAllowance{
+			TaxYear:               y.String(),
+			Start:                 y.Start,
+			End:                   y.End,
+			RemainingAllowanceGBP: a.overallRemaining(),
+		}
+		for isaType, u := range a {
+			if adultISA(isaType) {
+				year.SubscriptionsGBP += u.subscribed
+				year.WithdrawalsGBP += u.withdrawn
+				year.ReplacedGBP += u.replaced
+				year.TransferredInGBP += u.transferredIn
+			}
+		}
+
+		history.TaxYears = append(history.TaxYears, year)
+	}
+
+	return history, nil
+}
+
+// countsTowardsAllowance reports whether an order affects the allowance. Pending buys already commit the customer's
+// money whereas sells only count once they have executed, cancelled and rejected orders never do.
+func countsTowardsAllowance(o storage.Order) bool {
+	if o.OrderType == schema.Sell {
+		return o.Status == schema.Executed
+	}
+	return o.Status == schema.Pending || o.Status == schema.Executed
+}
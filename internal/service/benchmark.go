@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// RegisterBenchmark registers an index that funds can be measured against along with its levels. Registering a code
+// again renames the benchmark and adds to its levels, correcting any already held for the same dates.
+func (s Service) RegisterBenchmark(ctx context.Context, req RegisterBenchmarkRequest) (*Benchmark, error) {
+	code, name := strings.TrimSpace(req.Code), strings.TrimSpace(req.Name)
+	if code == "" || name == "" {
+		return nil, errors.Wrap(errors.Wrap(ErrInvalidBenchmark, "code and name are required"), ErrRegisteringBenchmark)
+	}
+
+	levels := make([]schema.BenchmarkLevels, len(req.Levels))
+	seen := map[string]bool{}
+	for i, l := range req.Levels {
+		date := l.Date.Format("2006-01-02")
+		if l.Level <= 0 {
+			return nil, errors.Wrap(errors.Wrapf(ErrInvalidBenchmark, "level on %s must be greater than zero", date), ErrRegisteringBenchmark)
+		}
+		if seen[date] {
+			return nil, errors.Wrap(errors.Wrapf(ErrInvalidBenchmark, "more than one level on %s", date), ErrRegisteringBenchmark)
+		}
+		seen[date] = true
+		levels[i] = schema.BenchmarkLevels{Date: l.Date, Level: l.Level}
+	}
+
+	benchmark, err := s.store.RegisterBenchmark(ctx, &schema.Benchmarks{Code: code, Name: name}, levels)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrRegisteringBenchmark)
+	}
+
+	return &Benchmark{Code: benchmark.Code, Name: benchmark.Name}, nil
+}
+
+// SetFundBenchmark measures the fund against a registered benchmark, for every customer type it is offered to.
+func (s Service) SetFundBenchmark(ctx context.Context, fundCode string, benchmarkCode string) error {
+	err := s.store.SetFundBenchmark(ctx, fundCode, benchmarkCode)
+	if errors.Is(err, storage.ErrFundNotFound) {
+		return errors.Wrap(ErrFundNotFound, ErrSettingFundBenchmark)
+	}
+	if errors.Is(err, storage.ErrBenchmarkNotFound) {
+		return errors.Wrap(ErrBenchmarkNotFound, ErrSettingFundBenchmark)
+	}
+	if err != nil {
+		return errors.Wrap(err, ErrSettingFundBenchmark)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// customer returns the customer's record, so that requests for a customer we don't know are refused rather than
+// answered with an empty account.
+func (s Service) customer(ctx context.Context, customerID int) (*storage.Customer, error) {
+	customer, err := s.store.GetCustomer(ctx, customerID)
+	if errors.Is(err, storage.ErrCustomerNotFound) {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return customer, nil
+}
+
+// customerEmployer returns the employer a workplace customer is linked to, whose catalogue they are offered.
+func customerEmployer(customer *storage.Customer) (uint, error) {
+	if customer.EmployerID == nil {
+		return 0, errors.Wrap(ErrCustomerNotEligible, "workplace customer is not linked to an employer")
+	}
+
+	return *customer.EmployerID, nil
+}
+
+// offeredFund returns the fund with the code if it is offered to the customer. Funds an employer no longer approves
+// can't be bought, but can still be sold by looking them up by customer type.
+func (s Service) offeredFund(ctx context.Context, customer *storage.Customer, code string) (*storage.Fund, error) {
+	if customer.CustomerType != schema.Workplace {
+		return s.store.GetFund(ctx, code, string(customer.CustomerType))
+	}
+
+	employerID, err := customerEmployer(customer)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetEmployerFund(ctx, code, employerID)
+}
+
+// tradingCustomer returns the customer's record if they can place orders, which only active customers can do. Only UK
+// residents can subscribe to an ISA, so buying and opening a lifetime ISA also checks residency.
+func (s Service) tradingCustomer(ctx context.Context, customerID int, subscribing bool) (*storage.Customer, error) {
+	customer, err := s.customer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if customer.Status != schema.CustomerActive {
+		return nil, errors.Wrapf(ErrCustomerNotEligible, "customer is %s", customer.Status)
+	}
+	if subscribing && customer.Residency != schema.UKResident {
+		return nil, errors.Wrap(ErrCustomerNotEligible, "only UK residents can subscribe to an ISA")
+	}
+
+	return customer, nil
+}
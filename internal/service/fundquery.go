@@ -0,0 +1,96 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+const (
+	// defaultFundPageSize is how many funds are listed when a page size isn't asked for, and maxFundPageSize the most
+	// that can be asked for
+	defaultFundPageSize = 20
+	maxFundPageSize     = 100
+)
+
+// fundCursor is where a listing continues from, along with the sort it was listed in so that a cursor isn't used to
+// seek through a listing sorted by something else
+type fundCursor struct {
+	SortBy     FundSort           `json:"sortBy,omitempty"`
+	Descending bool               `json:"descending,omitempty"`
+	After      storage.FundCursor `json:"after"`
+}
+
+// toStoreFundQuery checks the query can be listed and turns it into the store's query, decoding the cursor.
+func toStoreFundQuery(query FundQuery) (storage.FundQuery, error) {
+	storeQuery := storage.FundQuery{
+		RiskScore:   query.RiskScore,
+		Search:      strings.TrimSpace(query.Search),
+		MinPriceGBP: query.MinPriceGBP,
+		MaxPriceGBP: query.MaxPriceGBP,
+		SortBy:      storage.FundSort(query.SortBy),
+		Descending:  query.Descending,
+		Limit:       query.Limit,
+	}
+
+	if query.RiskScore != "" && !query.RiskScore.Valid() {
+		return storage.FundQuery{}, errors.Wrapf(ErrInvalidFundQuery, "%s is not a risk score", query.RiskScore)
+	}
+	if (query.MinPriceGBP != nil && *query.MinPriceGBP < 0) || (query.MaxPriceGBP != nil && *query.MaxPriceGBP < 0) {
+		return storage.FundQuery{}, errors.Wrap(ErrInvalidFundQuery, "prices can't be negative")
+	}
+	if query.MinPriceGBP != nil && query.MaxPriceGBP != nil && *query.MinPriceGBP > *query.MaxPriceGBP {
+		return storage.FundQuery{}, errors.Wrap(ErrInvalidFundQuery, "minimum price is above the maximum price")
+	}
+
+	switch query.SortBy {
+	case "", SortFundsByName, SortFundsByCode, SortFundsByPrice:
+	default:
+		return storage.FundQuery{}, errors.Wrapf(ErrInvalidFundQuery, "funds can't be sorted by %s", query.SortBy)
+	}
+
+	switch {
+	case query.Limit == 0:
+		storeQuery.Limit = defaultFundPageSize
+	case query.Limit < 0 || query.Limit > maxFundPageSize:
+		return storage.FundQuery{}, errors.Wrapf(ErrInvalidFundQuery, "limit must be from 1 to %d", maxFundPageSize)
+	}
+
+	if query.Cursor != "" {
+		after, err := decodeFundCursor(query)
+		if err != nil {
+			return storage.FundQuery{}, err
+		}
+		storeQuery.After = after
+	}
+
+	return storeQuery, nil
+}
+
+// encodeFundCursor returns the cursor the listing continues from after the fund, opaque to the client.
+func encodeFundCursor(query FundQuery, after *storage.FundCursor) string {
+	// Marshalling a struct of strings and numbers can't fail
+	b, _ := json.Marshal(fundCursor{SortBy: query.SortBy, Descending: query.Descending, After: *after})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeFundCursor returns where the query's cursor continues the listing from, if it was given out for a listing
+// sorted the same way.
+func decodeFundCursor(query FundQuery) (*storage.FundCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidFundQuery, "cursor is invalid")
+	}
+
+	var cursor fundCursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return nil, errors.Wrap(ErrInvalidFundQuery, "cursor is invalid")
+	}
+	if cursor.SortBy != query.SortBy || cursor.Descending != query.Descending {
+		return nil, errors.Wrap(ErrInvalidFundQuery, "cursor is from a listing sorted differently")
+	}
+
+	return &cursor.After, nil
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+	"github.com/jautyw/isa-investment-funds/internal/taxyear"
+	"github.com/pkg/errors"
+	"time"
+)
+
+const (
+	// A lifetime ISA can be opened from the customer's 18th birthday up until their 40th
+	lifetimeISAMinOpeningAge = 18
+	lifetimeISAMaxOpeningAge = 39
+	// lifetimeISAMaxContributionAge is the last age at which customers can pay into a lifetime ISA
+	lifetimeISAMaxContributionAge = 49
+	// lifetimeISAWithdrawalAge is the age from which any lifetime ISA withdrawal is free of charge
+	lifetimeISAWithdrawalAge = 60
+)
+
+// OpenLifetimeISA opens a lifetime ISA for a customer, who must be aged between 18 and 39 on the day it is opened. Their
+// age is taken from the date of birth on their record, as it is for the contribution and withdrawal rules.
+func (s Service) OpenLifetimeISA(ctx context.Context, customerID int) (*LifetimeISA, error) {
+	customer, err := s.tradingCustomer(ctx, customerID, true)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrOpeningLifetimeISA)
+	}
+
+	now := s.clock.Now()
+	age := ageOn(customer.DateOfBirth, now)
+	if age < lifetimeISAMinOpeningAge || age > lifetimeISAMaxOpeningAge {
+		return nil, errors.Wrap(errors.Wrapf(ErrLifetimeISAAgeIneligible, "customer is %d", age), ErrOpeningLifetimeISA)
+	}
+
+	account, err := s.store.CreateLifetimeISA(ctx, &schema.LifetimeISAAccounts{
+		CustomerID: uint(customerID),
+		OpenedAt:   now,
+	})
+	if errors.Is(err, storage.ErrLifetimeISAAlreadyOpen) {
+		return nil, errors.Wrap(ErrLifetimeISAAlreadyOpen, ErrOpeningLifetimeISA)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrOpeningLifetimeISA)
+	}
+
+	return &LifetimeISA{
+		CustomerID:  account.CustomerID,
+		DateOfBirth: customer.DateOfBirth,
+		OpenedAt:    account.OpenedAt,
+	}, nil
+}
+
+// lifetimeISA returns the customer's lifetime ISA, which has to be opened before it can be traded in.
+func (s Service) lifetimeISA(ctx context.Context, customerID int) (*storage.LifetimeISA, error) {
+	account, err := s.store.GetLifetimeISA(ctx, customerID)
+	if errors.Is(err, storage.ErrLifetimeISANotFound) {
+		return nil, ErrLifetimeISANotOpen
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// checkLifetimeISAContribution refuses payments into a lifetime ISA once the customer has turned 50.
+func (s Service) checkLifetimeISAContribution(ctx context.Context, customer *storage.Customer) error {
+	if _, err := s.lifetimeISA(ctx, int(customer.CustomerID)); err != nil {
+		return err
+	}
+
+	if age := ageOn(customer.DateOfBirth, s.clock.Now()); age > lifetimeISAMaxContributionAge {
+		return errors.Wrapf(ErrLifetimeISAAgeIneligible, "customer is %d", age)
+	}
+
+	return nil
+}
+
+// lifetimeISAWithdrawalReason works out the reason recorded against a lifetime ISA withdrawal. Withdrawals are always
+// authorised once the customer is 60, before then they are charged unless one of the other authorised reasons is
+// given.
+func (s Service) lifetimeISAWithdrawalReason(ctx context.Context, customer *storage.Customer, requested schema.WithdrawalReason) (schema.WithdrawalReason, error) {
+	if _, err := s.lifetimeISA(ctx, int(customer.CustomerID)); err != nil {
+		return "", err
+	}
+
+	if ageOn(customer.DateOfBirth, s.clock.Now()) >= lifetimeISAWithdrawalAge {
+		return schema.AgedSixty, nil
+	}
+
+	switch requested {
+	case "", schema.Unauthorised:
+		return schema.Unauthorised, nil
+	case schema.FirstHome, schema.TerminalIllness:
+		return requested, nil
+	default:
+		return "", errors.Wrap(ErrInvalidWithdrawalReason, string(requested))
+	}
+}
+
+// lifetimeISASummary totals the bonuses and charges on the customer's lifetime ISA, or returns nil if they don't have
+// one. Bonuses are only added to the ledger once the subscription they are paid on has executed.
+func (s Service) lifetimeISASummary(ctx context.Context, customerID int) (*LifetimeISASummary, error) {
+	account, err := s.store.GetLifetimeISA(ctx, customerID)
+	if errors.Is(err, storage.ErrLifetimeISANotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.store.GetLifetimeISALedger(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &LifetimeISASummary{
+		OpenedAt: account.OpenedAt,
+		Ledger:   make([]LedgerEntry, len(entries)),
+	}
+	for i, e := range entries {
+		switch e.EntryType {
+		case schema.GovernmentBonus:
+			summary.BonusGBP += e.AmountGBP
+		case schema.WithdrawalCharge:
+			summary.WithdrawalChargesGBP -= e.AmountGBP
+		}
+
+		summary.Ledger[i] = LedgerEntry{
+			OrderID:   e.OrderID,
+			EntryType: e.EntryType,
+			AmountGBP: e.AmountGBP,
+			CreatedAt: e.CreatedAt,
+		}
+	}
+
+	return summary, nil
+}
+
+// ageOn returns how old someone born on dateOfBirth is on the day of t in the UK. Dates of birth are read back from
+// the database at midnight UTC, so only their calendar date is used.
+func ageOn(dateOfBirth time.Time, t time.Time) int {
+	t = t.In(taxyear.London)
+
+	age := t.Year() - dateOfBirth.Year()
+	if t.Month() < dateOfBirth.Month() || (t.Month() == dateOfBirth.Month() && t.Day() < dateOfBirth.Day()) {
+		age--
+	}
+	return age
+}
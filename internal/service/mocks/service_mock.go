@@ -7,8 +7,10 @@ package service
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+	schema "github.com/jautyw/isa-investment-funds/internal/schema"
 	storage "github.com/jautyw/isa-investment-funds/internal/storage"
 )
 
@@ -35,34 +37,244 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
-// GetAmountSpentCurrentTaxYear mocks base method.
-func (m *MockStore) GetAmountSpentCurrentTaxYear(arg0 context.Context, arg1 int) (float64, error) {
+// CreateLifetimeISA mocks base method.
+func (m *MockStore) CreateLifetimeISA(arg0 context.Context, arg1 *schema.LifetimeISAAccounts) (*storage.LifetimeISA, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAmountSpentCurrentTaxYear", arg0, arg1)
+	ret := m.ctrl.Call(m, "CreateLifetimeISA", arg0, arg1)
+	ret0, _ := ret[0].(*storage.LifetimeISA)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateLifetimeISA indicates an expected call of CreateLifetimeISA.
+func (mr *MockStoreMockRecorder) CreateLifetimeISA(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLifetimeISA", reflect.TypeOf((*MockStore)(nil).CreateLifetimeISA), arg0, arg1)
+}
+
+// CreateOrder mocks base method.
+func (m *MockStore) CreateOrder(arg0 context.Context, arg1 *schema.Orders) (*storage.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOrder", arg0, arg1)
+	ret0, _ := ret[0].(*storage.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOrder indicates an expected call of CreateOrder.
+func (mr *MockStoreMockRecorder) CreateOrder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockStore)(nil).CreateOrder), arg0, arg1)
+}
+
+// CreateTransfer mocks base method.
+func (m *MockStore) CreateTransfer(arg0 context.Context, arg1 *schema.Transfers) (*storage.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTransfer", arg0, arg1)
+	ret0, _ := ret[0].(*storage.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTransfer indicates an expected call of CreateTransfer.
+func (mr *MockStoreMockRecorder) CreateTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransfer", reflect.TypeOf((*MockStore)(nil).CreateTransfer), arg0, arg1)
+}
+
+// ExecutePendingOrders mocks base method.
+func (m *MockStore) ExecutePendingOrders(arg0 context.Context, arg1 int, arg2 time.Time) ([]storage.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExecutePendingOrders", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]storage.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecutePendingOrders indicates an expected call of ExecutePendingOrders.
+func (mr *MockStoreMockRecorder) ExecutePendingOrders(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecutePendingOrders", reflect.TypeOf((*MockStore)(nil).ExecutePendingOrders), arg0, arg1, arg2)
+}
+
+// GetAvailableShares mocks base method.
+func (m *MockStore) GetAvailableShares(arg0 context.Context, arg1 int, arg2 string, arg3 schema.ISAType) (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAvailableShares", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(float64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAmountSpentCurrentTaxYear indicates an expected call of GetAmountSpentCurrentTaxYear.
-func (mr *MockStoreMockRecorder) GetAmountSpentCurrentTaxYear(arg0, arg1 interface{}) *gomock.Call {
+// GetAvailableShares indicates an expected call of GetAvailableShares.
+func (mr *MockStoreMockRecorder) GetAvailableShares(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAmountSpentCurrentTaxYear", reflect.TypeOf((*MockStore)(nil).GetAmountSpentCurrentTaxYear), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAvailableShares", reflect.TypeOf((*MockStore)(nil).GetAvailableShares), arg0, arg1, arg2, arg3)
+}
+
+// GetBenchmarkHistory mocks base method.
+func (m *MockStore) GetBenchmarkHistory(arg0 context.Context, arg1 int) ([]storage.BenchmarkLevel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBenchmarkHistory", arg0, arg1)
+	ret0, _ := ret[0].([]storage.BenchmarkLevel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBenchmarkHistory indicates an expected call of GetBenchmarkHistory.
+func (mr *MockStoreMockRecorder) GetBenchmarkHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBenchmarkHistory", reflect.TypeOf((*MockStore)(nil).GetBenchmarkHistory), arg0, arg1)
+}
+
+// GetCurrentTaxYearOrders mocks base method.
+func (m *MockStore) GetCurrentTaxYearOrders(arg0 context.Context, arg1 int) ([]storage.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCurrentTaxYearOrders", arg0, arg1)
+	ret0, _ := ret[0].([]storage.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCurrentTaxYearOrders indicates an expected call of GetCurrentTaxYearOrders.
+func (mr *MockStoreMockRecorder) GetCurrentTaxYearOrders(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCurrentTaxYearOrders", reflect.TypeOf((*MockStore)(nil).GetCurrentTaxYearOrders), arg0, arg1)
+}
+
+// GetCustomer mocks base method.
+func (m *MockStore) GetCustomer(arg0 context.Context, arg1 int) (*storage.Customer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCustomer", arg0, arg1)
+	ret0, _ := ret[0].(*storage.Customer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCustomer indicates an expected call of GetCustomer.
+func (mr *MockStoreMockRecorder) GetCustomer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomer", reflect.TypeOf((*MockStore)(nil).GetCustomer), arg0, arg1)
+}
+
+// GetEmployees mocks base method.
+func (m *MockStore) GetEmployees(arg0 context.Context, arg1 uint) ([]storage.Customer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmployees", arg0, arg1)
+	ret0, _ := ret[0].([]storage.Customer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmployees indicates an expected call of GetEmployees.
+func (mr *MockStoreMockRecorder) GetEmployees(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmployees", reflect.TypeOf((*MockStore)(nil).GetEmployees), arg0, arg1)
+}
+
+// GetEmployer mocks base method.
+func (m *MockStore) GetEmployer(arg0 context.Context, arg1 uint) (*storage.Employer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmployer", arg0, arg1)
+	ret0, _ := ret[0].(*storage.Employer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmployer indicates an expected call of GetEmployer.
+func (mr *MockStoreMockRecorder) GetEmployer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmployer", reflect.TypeOf((*MockStore)(nil).GetEmployer), arg0, arg1)
+}
+
+// GetEmployerFund mocks base method.
+func (m *MockStore) GetEmployerFund(arg0 context.Context, arg1 string, arg2 uint) (*storage.Fund, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmployerFund", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*storage.Fund)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmployerFund indicates an expected call of GetEmployerFund.
+func (mr *MockStoreMockRecorder) GetEmployerFund(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmployerFund", reflect.TypeOf((*MockStore)(nil).GetEmployerFund), arg0, arg1, arg2)
+}
+
+// GetEmployerFunds mocks base method.
+func (m *MockStore) GetEmployerFunds(arg0 context.Context, arg1 uint, arg2 storage.FundQuery) (*storage.Funds, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmployerFunds", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*storage.Funds)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmployerFunds indicates an expected call of GetEmployerFunds.
+func (mr *MockStoreMockRecorder) GetEmployerFunds(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmployerFunds", reflect.TypeOf((*MockStore)(nil).GetEmployerFunds), arg0, arg1, arg2)
+}
+
+// GetFund mocks base method.
+func (m *MockStore) GetFund(arg0 context.Context, arg1, arg2 string) (*storage.Fund, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFund", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*storage.Fund)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFund indicates an expected call of GetFund.
+func (mr *MockStoreMockRecorder) GetFund(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFund", reflect.TypeOf((*MockStore)(nil).GetFund), arg0, arg1, arg2)
+}
+
+// GetFundPriceHistory mocks base method.
+func (m *MockStore) GetFundPriceHistory(arg0 context.Context, arg1 int) ([]storage.FundPrice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFundPriceHistory", arg0, arg1)
+	ret0, _ := ret[0].([]storage.FundPrice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFundPriceHistory indicates an expected call of GetFundPriceHistory.
+func (mr *MockStoreMockRecorder) GetFundPriceHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFundPriceHistory", reflect.TypeOf((*MockStore)(nil).GetFundPriceHistory), arg0, arg1)
 }
 
 // GetFunds mocks base method.
-func (m *MockStore) GetFunds(arg0 context.Context, arg1 string) (*storage.Funds, error) {
+func (m *MockStore) GetFunds(arg0 context.Context, arg1 string, arg2 storage.FundQuery) (*storage.Funds, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetFunds", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetFunds", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*storage.Funds)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetFunds indicates an expected call of GetFunds.
-func (mr *MockStoreMockRecorder) GetFunds(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockStoreMockRecorder) GetFunds(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFunds", reflect.TypeOf((*MockStore)(nil).GetFunds), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFunds", reflect.TypeOf((*MockStore)(nil).GetFunds), arg0, arg1, arg2)
+}
+
+// GetHeldFundCodes mocks base method.
+func (m *MockStore) GetHeldFundCodes(arg0 context.Context, arg1 int) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHeldFundCodes", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHeldFundCodes indicates an expected call of GetHeldFundCodes.
+func (mr *MockStoreMockRecorder) GetHeldFundCodes(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHeldFundCodes", reflect.TypeOf((*MockStore)(nil).GetHeldFundCodes), arg0, arg1)
 }
 
 // GetInvestmentOverview mocks base method.
@@ -79,3 +291,166 @@ func (mr *MockStoreMockRecorder) GetInvestmentOverview(arg0, arg1 interface{}) *
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInvestmentOverview", reflect.TypeOf((*MockStore)(nil).GetInvestmentOverview), arg0, arg1)
 }
+
+// GetLifetimeISA mocks base method.
+func (m *MockStore) GetLifetimeISA(arg0 context.Context, arg1 int) (*storage.LifetimeISA, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLifetimeISA", arg0, arg1)
+	ret0, _ := ret[0].(*storage.LifetimeISA)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLifetimeISA indicates an expected call of GetLifetimeISA.
+func (mr *MockStoreMockRecorder) GetLifetimeISA(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLifetimeISA", reflect.TypeOf((*MockStore)(nil).GetLifetimeISA), arg0, arg1)
+}
+
+// GetLifetimeISALedger mocks base method.
+func (m *MockStore) GetLifetimeISALedger(arg0 context.Context, arg1 int) ([]storage.LedgerEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLifetimeISALedger", arg0, arg1)
+	ret0, _ := ret[0].([]storage.LedgerEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLifetimeISALedger indicates an expected call of GetLifetimeISALedger.
+func (mr *MockStoreMockRecorder) GetLifetimeISALedger(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLifetimeISALedger", reflect.TypeOf((*MockStore)(nil).GetLifetimeISALedger), arg0, arg1)
+}
+
+// GetOrderByIdempotencyKey mocks base method.
+func (m *MockStore) GetOrderByIdempotencyKey(arg0 context.Context, arg1 int, arg2 string) (*storage.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderByIdempotencyKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*storage.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderByIdempotencyKey indicates an expected call of GetOrderByIdempotencyKey.
+func (mr *MockStoreMockRecorder) GetOrderByIdempotencyKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByIdempotencyKey", reflect.TypeOf((*MockStore)(nil).GetOrderByIdempotencyKey), arg0, arg1, arg2)
+}
+
+// GetOrders mocks base method.
+func (m *MockStore) GetOrders(arg0 context.Context, arg1 int) ([]storage.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrders", arg0, arg1)
+	ret0, _ := ret[0].([]storage.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrders indicates an expected call of GetOrders.
+func (mr *MockStoreMockRecorder) GetOrders(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockStore)(nil).GetOrders), arg0, arg1)
+}
+
+// GetTransfers mocks base method.
+func (m *MockStore) GetTransfers(arg0 context.Context, arg1 int) ([]storage.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfers", arg0, arg1)
+	ret0, _ := ret[0].([]storage.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfers indicates an expected call of GetTransfers.
+func (mr *MockStoreMockRecorder) GetTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfers", reflect.TypeOf((*MockStore)(nil).GetTransfers), arg0, arg1)
+}
+
+// RegisterBenchmark mocks base method.
+func (m *MockStore) RegisterBenchmark(arg0 context.Context, arg1 *schema.Benchmarks, arg2 []schema.BenchmarkLevels) (*storage.Benchmark, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterBenchmark", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*storage.Benchmark)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegisterBenchmark indicates an expected call of RegisterBenchmark.
+func (mr *MockStoreMockRecorder) RegisterBenchmark(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterBenchmark", reflect.TypeOf((*MockStore)(nil).RegisterBenchmark), arg0, arg1, arg2)
+}
+
+// SetFundBenchmark mocks base method.
+func (m *MockStore) SetFundBenchmark(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetFundBenchmark", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetFundBenchmark indicates an expected call of SetFundBenchmark.
+func (mr *MockStoreMockRecorder) SetFundBenchmark(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetFundBenchmark", reflect.TypeOf((*MockStore)(nil).SetFundBenchmark), arg0, arg1, arg2)
+}
+
+// SetRiskProfile mocks base method.
+func (m *MockStore) SetRiskProfile(arg0 context.Context, arg1 int, arg2 schema.RiskScore, arg3 time.Time) (*storage.Customer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRiskProfile", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*storage.Customer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetRiskProfile indicates an expected call of SetRiskProfile.
+func (mr *MockStoreMockRecorder) SetRiskProfile(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRiskProfile", reflect.TypeOf((*MockStore)(nil).SetRiskProfile), arg0, arg1, arg2, arg3)
+}
+
+// UpdateOrderStatus mocks base method.
+func (m *MockStore) UpdateOrderStatus(arg0 context.Context, arg1 int, arg2 uint, arg3 schema.OrderStatus) (*storage.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrderStatus", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*storage.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateOrderStatus indicates an expected call of UpdateOrderStatus.
+func (mr *MockStoreMockRecorder) UpdateOrderStatus(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderStatus", reflect.TypeOf((*MockStore)(nil).UpdateOrderStatus), arg0, arg1, arg2, arg3)
+}
+
+// UpdateTransferStatus mocks base method.
+func (m *MockStore) UpdateTransferStatus(arg0 context.Context, arg1 int, arg2 uint, arg3 schema.TransferStatus, arg4 time.Time) (*storage.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTransferStatus", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*storage.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTransferStatus indicates an expected call of UpdateTransferStatus.
+func (mr *MockStoreMockRecorder) UpdateTransferStatus(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTransferStatus", reflect.TypeOf((*MockStore)(nil).UpdateTransferStatus), arg0, arg1, arg2, arg3, arg4)
+}
+
+// WithCustomerLock mocks base method.
+func (m *MockStore) WithCustomerLock(arg0 context.Context, arg1 int, arg2 func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithCustomerLock", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithCustomerLock indicates an expected call of WithCustomerLock.
+func (mr *MockStoreMockRecorder) WithCustomerLock(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithCustomerLock", reflect.TypeOf((*MockStore)(nil).WithCustomerLock), arg0, arg1, arg2)
+}
@@ -1,41 +1,258 @@
 package service
 
 import (
+	"github.com/jautyw/isa-investment-funds/internal/performance"
 	"github.com/jautyw/isa-investment-funds/internal/schema"
 	"time"
 )
 
+// Funds is a page of the funds offered to a customer. Total counts the funds matching the query across every page, and
+// NextCursor continues the listing, empty on the last page.
 type Funds struct {
-	Funds []Fund
+	Funds      []Fund
+	Total      int64
+	NextCursor string
 }
 
+// FundSort is the field funds are listed in order of, they are listed in the order they were added without one
+type FundSort string
+
+const (
+	SortFundsByName  FundSort = "name"
+	SortFundsByCode  FundSort = "code"
+	SortFundsByPrice FundSort = "price"
+)
+
+// FundQuery filters, sorts and pages the funds offered to a customer. Search matches the fund's name or code, and the
+// price range includes its ends. Cursor is the NextCursor of the previous page, which has to have been sorted the same
+// way, and a Limit of zero takes the default page size.
+type FundQuery struct {
+	RiskScore   schema.RiskScore
+	Search      string
+	MinPriceGBP *float64
+	MaxPriceGBP *float64
+	SortBy      FundSort
+	Descending  bool
+	Cursor      string
+	Limit       int
+}
+
+// Fund carries the AnnualChargePercent negotiated by the employer when it is from a workplace catalogue
 type Fund struct {
-	Name        string
-	Description string
-	Code        string
-	AmountGBP   float64
-	RiskScore   string
-	LastUpdated time.Time
+	Name                string
+	Description         string
+	Code                string
+	AmountGBP           float64
+	RiskScore           string
+	LastUpdated         time.Time
+	AnnualChargePercent *float64
 }
 
 type Overview struct {
-	Investments                []InvestmentSummary
+	Investments []InvestmentSummary
+	// TotalValueGBP is what all of the customer's priced holdings are worth at their latest prices
+	TotalValueGBP float64
+	// IsaAllowanceCurrentTaxYear is what remains of the overall allowance shared by the adult ISAs
 	IsaAllowanceCurrentTaxYear float64
+	Allowances                 []ISAAllowance
+	// LifetimeISA is only set for customers who have opened a lifetime ISA
+	LifetimeISA *LifetimeISASummary
 }
 
+// InvestmentSummary is a holding valued at the latest price of its fund. BookCostGBP is what the shares still held cost
+// on average and RealisedGainGBP what has been made on sales. The value and unrealised gain are left at zero for a fund
+// that has never been priced.
 type InvestmentSummary struct {
-	Name          string
-	Description   string
-	Code          string
-	NetShares     float64
-	NetInvestment float64
+	Name                  string
+	Description           string
+	Code                  string
+	ISAType               schema.ISAType
+	NetShares             float64
+	NetInvestment         float64
+	BookCostGBP           float64
+	AverageCostGBP        float64
+	RealisedGainGBP       float64
+	PriceGBP              float64
+	CurrentValueGBP       float64
+	UnrealisedGainGBP     float64
+	UnrealisedGainPercent float64
+}
+
+// ISAAllowance breaks down how a wrapper's allowance has been used in the current tax year. ReplacedGBP is the part
+// of SubscribedGBP that replaced earlier withdrawals, which doesn't use any allowance. TransferredInGBP is what was
+// subscribed with other providers this tax year and has been transferred in, which does.
+type ISAAllowance struct {
+	ISAType          schema.ISAType
+	SubscribedGBP    float64
+	WithdrawnGBP     float64
+	ReplacedGBP      float64
+	TransferredInGBP float64
+	RemainingGBP     float64
 }
 
 type Order struct {
-	OrderID         float64
-	OrderType       schema.OrderType
-	Name            string
-	PurchaseTime    time.Time
-	SharesPurchased float64
+	OrderID           uint
+	CustomerID        uint
+	OrderType         schema.OrderType
+	Name              string
+	Code              string
+	ISAType           schema.ISAType
+	PurchaseTime      time.Time
+	SharesPurchased   float64
+	AmountGBP         float64
+	Status            schema.OrderStatus
+	ExecutionTime     *time.Time
+	ExecutionPriceGBP *float64
+	WithdrawalReason  *schema.WithdrawalReason
+	// BookCostGBP and RealisedGainGBP are only set on executed sells
+	BookCostGBP     *float64
+	RealisedGainGBP *float64
+	// RiskOverride is set on buys of a fund riskier than the customer's risk profile, which they acknowledged
+	RiskOverride bool
+	// Replayed is set when the order was created by an earlier request with the same idempotency key
+	Replayed bool
+}
+
+// PlaceBuyOrderRequest is placed into the stocks and shares ISA when no ISAType is given. AcknowledgeRisk lets the
+// customer buy a fund that is riskier than their risk profile.
+type PlaceBuyOrderRequest struct {
+	CustomerID      int
+	Code            string
+	ISAType         schema.ISAType
 	AmountGBP       float64
+	AcknowledgeRisk bool
+	IdempotencyKey  string
+	RequestHash     string
+}
+
+// RiskProfile is scored from the customer's answers to the risk questionnaire
+type RiskProfile struct {
+	CustomerID uint
+	Profile    schema.RiskScore
+	ProfiledAt time.Time
+}
+
+// PlaceSellOrderRequest sells from the stocks and shares ISA when no ISAType is given. WithdrawalReason only applies to
+// lifetime ISAs, where a withdrawal without an authorised reason is charged.
+type PlaceSellOrderRequest struct {
+	CustomerID       int
+	Code             string
+	ISAType          schema.ISAType
+	Shares           float64
+	WithdrawalReason schema.WithdrawalReason
+	IdempotencyKey   string
+	RequestHash      string
+}
+
+type AllowanceHistory struct {
+	TaxYears []TaxYearAllowance
+}
+
+type TaxYearAllowance struct {
+	TaxYear               string
+	Start                 time.Time
+	End                   time.Time
+	SubscriptionsGBP      float64
+	WithdrawalsGBP        float64
+	ReplacedGBP           float64
+	TransferredInGBP      float64
+	RemainingAllowanceGBP float64
+}
+
+type LifetimeISA struct {
+	CustomerID  uint
+	DateOfBirth time.Time
+	OpenedAt    time.Time
+}
+
+type LifetimeISASummary struct {
+	OpenedAt             time.Time
+	BonusGBP             float64
+	WithdrawalChargesGBP float64
+	Ledger               []LedgerEntry
+}
+
+type LedgerEntry struct {
+	OrderID   uint
+	EntryType schema.LedgerEntryType
+	AmountGBP float64
+	CreatedAt time.Time
+}
+
+// RequestTransferRequest is for the stocks and shares ISA when no ISAType is given. CurrentYearAmountGBP is the part of
+// the transfer subscribed in the current tax year and PreviousYearsAmountGBP the part subscribed before it.
+type RequestTransferRequest struct {
+	CustomerID             int
+	Direction              schema.TransferDirection
+	ISAType                schema.ISAType
+	Method                 schema.TransferMethod
+	Provider               string
+	CurrentYearAmountGBP   float64
+	PreviousYearsAmountGBP float64
+}
+
+type Transfer struct {
+	TransferID             uint
+	CustomerID             uint
+	Direction              schema.TransferDirection
+	ISAType                schema.ISAType
+	Method                 schema.TransferMethod
+	Provider               string
+	CurrentYearAmountGBP   float64
+	PreviousYearsAmountGBP float64
+	Status                 schema.TransferStatus
+	RequestedAt            time.Time
+	UpdatedAt              time.Time
+	CompletedAt            *time.Time
+}
+
+// Performance is how the customer's portfolio has done over a period, from the value at the end of OpeningDate to the
+// last day of Series. MoneyWeightedReturnPercent is only annualised over a year or more, and is nil when the portfolio's
+// cash flows have no rate of return such as when it was only funded today. BenchmarkReturnPercent is the return of
+// the benchmarks of the funds held, weighted by how much was held in each, and RelativeReturnPercent is how far the
+// time-weighted return is above it. Both are nil when none of the funds held have a benchmark.
+type Performance struct {
+	Period                     performance.Period
+	OpeningDate                time.Time
+	OpeningValueGBP            float64
+	ClosingValueGBP            float64
+	InvestedGBP                float64
+	WithdrawnGBP               float64
+	GainGBP                    float64
+	TimeWeightedReturnPercent  float64
+	MoneyWeightedReturnPercent *float64
+	BenchmarkReturnPercent     *float64
+	RelativeReturnPercent      *float64
+	Funds                      []FundPerformance
+	Series                     []performance.Point
+}
+
+// FundPerformance compares the price return of a fund held during the period with that of its benchmark. The benchmark
+// details are empty when the fund doesn't have one, and a return is nil when there is no price or level to measure.
+type FundPerformance struct {
+	Code                   string
+	Name                   string
+	ReturnPercent          *float64
+	BenchmarkCode          string
+	BenchmarkName          string
+	BenchmarkReturnPercent *float64
+	RelativeReturnPercent  *float64
+}
+
+// RegisterBenchmarkRequest registers a benchmark, or renames one already registered with the code, along with its
+// closing levels. Only the date of each level is used.
+type RegisterBenchmarkRequest struct {
+	Code   string
+	Name   string
+	Levels []BenchmarkLevel
+}
+
+type BenchmarkLevel struct {
+	Date  time.Time
+	Level float64
+}
+
+type Benchmark struct {
+	Code string
+	Name string
 }
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/jautyw/isa-investment-funds/internal/payroll"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+	"github.com/jautyw/isa-investment-funds/internal/taxyear"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// ImportPayroll invests the contributions in an employer's payroll file, placing a buy order into each employee's
+// default fund in their stocks and shares ISA. Each order goes through the same checks as any other buy, and rows that
+// fail them, such as a contribution that would breach the employee's allowance, are flagged in the report rather than
+// failing the file. Orders are keyed on the employer, employee and pay date, so importing a file again doesn't invest
+// the same contributions twice. Orders use the allowance of the tax year they are placed in, so contributions paid in
+// any other tax year are flagged rather than counted against the wrong allowance. If the store fails partway through
+// the report of the rows handled so far is returned with the error.
+func (s Service) ImportPayroll(ctx context.Context, employerID int, file io.Reader) (*payroll.Report, error) {
+	_, err := s.store.GetEmployer(ctx, uint(employerID))
+	if errors.Is(err, storage.ErrEmployerNotFound) {
+		return nil, errors.Wrap(ErrEmployerNotFound, ErrImportingPayroll)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrImportingPayroll)
+	}
+
+	contributions, report, err := payroll.Parse(file)
+	if err != nil {
+		return nil, errors.Wrap(errors.Wrap(ErrInvalidPayrollFile, err.Error()), ErrImportingPayroll)
+	}
+
+	storeEmployees, err := s.store.GetEmployees(ctx, uint(employerID))
+	if err != nil {
+		return nil, errors.Wrap(err, ErrImportingPayroll)
+	}
+
+	employees := map[string]storage.Customer{}
+	for _, e := range storeEmployees {
+		if e.EmployeeReference != "" {
+			employees[e.EmployeeReference] = e
+		}
+	}
+
+	currentTaxYear := taxyear.For(s.clock.Now())
+	for _, c := range contributions {
+		if !currentTaxYear.Contains(c.PayDate) {
+			report.Flag(c.Flag(), payroll.ReasonOutsideTaxYear)
+			continue
+		}
+
+		employee, ok := employees[c.EmployeeReference]
+		if !ok {
+			report.Flag(c.Flag(), payroll.ReasonUnknownEmployee)
+			continue
+		}
+		if employee.DefaultFundCode == "" {
+			report.Flag(c.Flag(), payroll.ReasonNoDefaultFund)
+			continue
+		}
+
+		key := fmt.Sprintf("payroll:%d:%s:%s", employerID, c.EmployeeReference, c.PayDate.Format("2006-01-02"))
+		hash := sha256.Sum256([]byte(fmt.Sprintf("%s %.2f", employee.DefaultFundCode, c.AmountGBP)))
+		order, err := s.PlaceBuyOrder(ctx, PlaceBuyOrderRequest{
+			CustomerID:     int(employee.CustomerID),
+			Code:           employee.DefaultFundCode,
+			ISAType:        schema.StocksAndShares,
+			AmountGBP:      c.AmountGBP,
+			IdempotencyKey: key,
+			RequestHash:    hex.EncodeToString(hash[:]),
+		})
+		if reason, flag := payrollFlagReason(err); flag {
+			report.Flag(c.Flag(), reason)
+			continue
+		}
+		if err != nil {
+			report.StoppedAtLine = c.Line
+			return report, errors.Wrap(err, ErrImportingPayroll)
+		}
+
+		if order.Replayed {
+			report.Replayed++
+		} else {
+			report.Ordered++
+		}
+	}
+
+	return report, nil
+}
+
+// payrollFlagReason reports why a contribution was refused when the buy order failed one of its checks, as opposed to
+// failing to reach the store.
+func payrollFlagReason(err error) (string, bool) {
+	var multipleProductsErr *MultipleProductsError
+
+	switch {
+	case err == nil:
+		return "", false
+	case errors.Is(err, ErrISAAllowanceExceeded):
+		return payroll.ReasonAllowanceExceeded, true
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		return payroll.ReasonAlreadyImported, true
+	case errors.Is(err, ErrFundNotFound):
+		return payroll.ReasonFundNotOffered, true
+	case errors.Is(err, ErrCustomerNotEligible):
+		return payroll.ReasonNotEligible, true
+	case errors.Is(err, ErrFundUnsuitable):
+		return payroll.ReasonFundUnsuitable, true
+	case errors.As(err, &multipleProductsErr):
+		return payroll.ReasonMultipleProducts, true
+	default:
+		return "", false
+	}
+}
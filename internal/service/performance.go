@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"github.com/jautyw/isa-investment-funds/internal/money"
+	"github.com/jautyw/isa-investment-funds/internal/performance"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+	"github.com/pkg/errors"
+	"maps"
+	"sort"
+	"time"
+)
+
+// GetPerformance measures the customer's portfolio over the period from their executed orders and the price history
+// of the funds they have bought. Each day is valued at the latest prices at its end, and a period that reaches back
+// before the customer's first order is measured from the day before it, when the portfolio was worth nothing. Each fund
+// held during the period, and the portfolio as a whole, is compared with its benchmark over the same days.
+func (s Service) GetPerformance(ctx context.Context, customerID int, period performance.Period) (*Performance, error) {
+	if !period.Valid() {
+		return nil, errors.Wrap(ErrInvalidPeriod, ErrGettingPerformance)
+	}
+
+	if _, err := s.customer(ctx, customerID); err != nil {
+		return nil, errors.Wrap(err, ErrGettingPerformance)
+	}
+
+	orders, err := s.store.GetOrders(ctx, customerID)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingPerformance)
+	}
+
+	prices, err := s.store.GetFundPriceHistory(ctx, customerID)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingPerformance)
+	}
+
+	levels, err := s.store.GetBenchmarkHistory(ctx, customerID)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingPerformance)
+	}
+
+	var executed []storage.Order
+	for _, o := range orders {
+		if o.Status == schema.Executed && o.ExecutionTime != nil {
+			executed = append(executed, o)
+		}
+	}
+	sort.SliceStable(executed, func(a, b int) bool {
+		return executed[a].ExecutionTime.Before(*executed[b].ExecutionTime)
+	})
+
+	today := performance.Day(s.clock.Now())
+	result := &Performance{Period: period, OpeningDate: today}
+	if len(executed) == 0 {
+		return result, nil
+	}
+
+	first := performance.Day(*executed[0].ExecutionTime).AddDate(0, 0, -1)
+	opening := period.Opening(today)
+	if opening.Before(first) {
+		opening = first
+	}
+	result.OpeningDate = opening
+
+	// The holdings are replayed from the first order so that the value on the opening day is known
+	var codes []string
+	names := map[string]string{}
+	held := map[string]bool{}
+	shares := map[string]float64{}
+	latestPrice := map[string]float64{}
+	latestLevel := map[string]float64{}
+	benchmarkGrowth, benchmarked := 1.0, false
+	nextOrder, nextPrice, nextLevel := 0, 0, 0
+	for day := first; !day.After(today); day = day.AddDate(0, 0, 1) {
+		end := day.AddDate(0, 0, 1)
+		point := performance.Point{Date: day}
+
+		// What each holding was worth at the start of the day, along with what was invested in it during the day,
+		// weights the return of its benchmark
+		start := map[string]float64{}
+		for _, code := range codes {
+			start[code] = shares[code] * latestPrice[code]
+		}
+		previousLevel := maps.Clone(latestLevel)
+
+		for ; nextOrder < len(executed) && executed[nextOrder].ExecutionTime.Before(end); nextOrder++ {
+			o := executed[nextOrder]
+			if _, ok := shares[o.Code]; !ok {
+				codes = append(codes, o.Code)
+				names[o.Code] = o.Name
+			}
+			if o.OrderType == schema.Buy {
+				shares[o.Code] += o.SharesPurchased
+				start[o.Code] += o.AmountGBP
+				point.InvestedGBP += o.AmountGBP
+			} else {
+				shares[o.Code] -= o.SharesPurchased
+				point.WithdrawnGBP += o.AmountGBP
+			}
+		}
+
+		for ; nextPrice < len(prices) && prices[nextPrice].ValuationPoint.Before(end); nextPrice++ {
+			latestPrice[prices[nextPrice].Code] = prices[nextPrice].PriceGBP
+		}
+
+		for ; nextLevel < len(levels) && levels[nextLevel].Date.Before(end); nextLevel++ {
+			latestLevel[levels[nextLevel].FundCode] = levels[nextLevel].Level
+		}
+
+		for _, code := range codes {
+			point.ValueGBP += shares[code] * latestPrice[code]
+		}
+
+		if !day.After(opening) {
+			result.OpeningValueGBP = point.ValueGBP
+			for _, code := range codes {
+				held[code] = held[code] || shares[code] > 0
+			}
+			continue
+		}
+
+		var weighted, weight float64
+		for _, code := range codes {
+			held[code] = held[code] || start[code] > 0 || shares[code] > 0
+			if start[code] > 0 && previousLevel[code] > 0 {
+				weighted += start[code] * (latestLevel[code]/previousLevel[code] - 1)
+				weight += start[code]
+			}
+		}
+		if weight > 0 {
+			benchmarkGrowth *= 1 + weighted/weight
+			benchmarked = true
+		}
+
+		result.Series = append(result.Series, point)
+	}
+
+	twr := performance.TimeWeightedReturn(result.OpeningValueGBP, result.Series)
+	result.TimeWeightedReturnPercent = money.RoundPence(twr * 100)
+	// The money-weighted return is left out rather than failing the request when it can't be solved
+	if rate, err := performance.MoneyWeightedReturn(performance.CashFlows(opening, result.OpeningValueGBP, result.Series)); err == nil {
+		percent := money.RoundPence(rate * 100)
+		result.MoneyWeightedReturnPercent = &percent
+	}
+	if benchmarked {
+		benchmarkPercent, relativePercent := money.RoundPence((benchmarkGrowth-1)*100), money.RoundPence((twr-benchmarkGrowth+1)*100)
+		result.BenchmarkReturnPercent = &benchmarkPercent
+		result.RelativeReturnPercent = &relativePercent
+	}
+
+	result.Funds = fundPerformance(codes, names, held, prices, levels, opening, today)
+
+	result.ClosingValueGBP = result.OpeningValueGBP
+	for i, p := range result.Series {
+		result.InvestedGBP += p.InvestedGBP
+		result.WithdrawnGBP += p.WithdrawnGBP
+		result.ClosingValueGBP = p.ValueGBP
+		result.Series[i].ValueGBP = money.RoundPence(p.ValueGBP)
+	}
+	result.GainGBP = money.RoundPence(result.ClosingValueGBP - result.OpeningValueGBP - result.InvestedGBP + result.WithdrawnGBP)
+	result.OpeningValueGBP = money.RoundPence(result.OpeningValueGBP)
+	result.ClosingValueGBP = money.RoundPence(result.ClosingValueGBP)
+
+	return result, nil
+}
+
+// fundPerformance compares the price return of each fund held during the period with the return of its benchmark.
+func fundPerformance(codes []string, names map[string]string, held map[string]bool, prices []storage.FundPrice, levels []storage.BenchmarkLevel, opening, closing time.Time) []FundPerformance {
+	fundPrices := map[string][]performance.Level{}
+	for _, p := range prices {
+		fundPrices[p.Code] = append(fundPrices[p.Code], performance.Level{At: p.ValuationPoint, Value: p.PriceGBP})
+	}
+
+	benchmarks := map[string]storage.BenchmarkLevel{}
+	benchmarkLevels := map[string][]performance.Level{}
+	for _, l := range levels {
+		benchmarks[l.FundCode] = l
+		benchmarkLevels[l.FundCode] = append(benchmarkLevels[l.FundCode], performance.Level{At: l.Date, Value: l.Level})
+	}
+
+	var funds []FundPerformance
+	for _, code := range codes {
+		if !held[code] {
+			continue
+		}
+
+		fund := FundPerformance{Code: code, Name: names[code]}
+		fundReturn, fundPriced := performance.PeriodReturn(fundPrices[code], opening, closing)
+		if fundPriced {
+			percent := money.RoundPence(fundReturn * 100)
+			fund.ReturnPercent = &percent
+		}
+
+		if b, ok := benchmarks[code]; ok {
+			fund.BenchmarkCode, fund.BenchmarkName = b.BenchmarkCode, b.BenchmarkName
+			if benchmarkReturn, ok := performance.PeriodReturn(benchmarkLevels[code], opening, closing); ok {
+				percent := money.RoundPence(benchmarkReturn * 100)
+				fund.BenchmarkReturnPercent = &percent
+				if fundPriced {
+					relative := money.RoundPence((fundReturn - benchmarkReturn) * 100)
+					fund.RelativeReturnPercent = &relative
+				}
+			}
+		}
+
+		funds = append(funds, fund)
+	}
+
+	return funds
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"github.com/jautyw/isa-investment-funds/internal/riskprofile"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// SubmitRiskQuestionnaire scores the customer's answers to the risk questionnaire into their risk profile, replacing
+// any profile from an earlier questionnaire.
+func (s Service) SubmitRiskQuestionnaire(ctx context.Context, customerID int, answers map[string]int) (*RiskProfile, error) {
+	if _, err := s.customer(ctx, customerID); err != nil {
+		return nil, errors.Wrap(err, ErrSubmittingRiskQuestionnaire)
+	}
+
+	profile, err := riskprofile.Score(answers)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrSubmittingRiskQuestionnaire)
+	}
+
+	customer, err := s.store.SetRiskProfile(ctx, customerID, profile, s.clock.Now())
+	if errors.Is(err, storage.ErrCustomerNotFound) {
+		return nil, errors.Wrap(ErrCustomerNotFound, ErrSubmittingRiskQuestionnaire)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrSubmittingRiskQuestionnaire)
+	}
+
+	return &RiskProfile{
+		CustomerID: customer.CustomerID,
+		Profile:    customer.RiskProfile,
+		ProfiledAt: *customer.RiskProfiledAt,
+	}, nil
+}
+
+// checkSuitability refuses a buy of a fund that is riskier than the customer's risk profile unless the customer has
+// acknowledged the risk, in which case it reports that the order overrides their profile. Customers who haven't
+// completed the questionnaire aren't checked.
+func checkSuitability(customer *storage.Customer, fund *storage.Fund, acknowledged bool) (bool, error) {
+	if customer.RiskProfile == "" || !fund.RiskScore.Exceeds(customer.RiskProfile) {
+		return false, nil
+	}
+
+	if !acknowledged {
+		return false, errors.Wrapf(ErrFundUnsuitable, "%s is %s risk and the customer's profile is %s, the risk must be acknowledged to buy it", fund.Code, fund.RiskScore, customer.RiskProfile)
+	}
+
+	return true, nil
+}
+
+// riskProfileOf returns the customer's risk profile to be recorded on an order, nil if they haven't got one.
+func riskProfileOf(customer *storage.Customer) *schema.RiskScore {
+	if customer.RiskProfile == "" {
+		return nil
+	}
+
+	profile := customer.RiskProfile
+	return &profile
+}
@@ -4,46 +4,207 @@ package service
 import (
 	"context"
 	"fmt"
+	"github.com/jautyw/isa-investment-funds/internal/clock"
+	"github.com/jautyw/isa-investment-funds/internal/money"
+	"github.com/jautyw/isa-investment-funds/internal/riskprofile"
 	"github.com/jautyw/isa-investment-funds/internal/schema"
 	"github.com/jautyw/isa-investment-funds/internal/storage"
 	"github.com/pkg/errors"
+	"time"
 )
 
 type Service struct {
-	store Store
+	store                 Store
+	clock                 clock.Clock
+	allowMultipleProducts bool
+}
+
+// Option configures optional behaviour of the Service
+type Option func(*Service)
+
+// WithMultipleProducts lifts the rule restricting customers to a single product when allowed is true
+func WithMultipleProducts(allowed bool) Option {
+	return func(s *Service) {
+		s.allowMultipleProducts = allowed
+	}
+}
+
+// WithClock replaces the system clock the Service uses to timestamp orders
+func WithClock(c clock.Clock) Option {
+	return func(s *Service) {
+		s.clock = c
+	}
 }
 
 // NewService represents a new instance of the Service
-func NewService(store Store) *Service {
-	return &Service{
+func NewService(store Store, opts ...Option) *Service {
+	s := &Service{
 		store: store,
+		clock: clock.NewClock(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 const (
-	ErrGettingFunds        = "error getting funds for user"
-	ErrGettingOverview     = "error getting overview for user"
-	ErrGettingISAAllowance = "error getting allowance for user"
+	ErrGettingFunds         = "error getting funds for user"
+	ErrGettingOverview      = "error getting overview for user"
+	ErrGettingISAAllowance  = "error getting allowance for user"
+	ErrPlacingBuyOrder      = "error placing buy order for user"
+	ErrPlacingSellOrder     = "error placing sell order for user"
+	ErrExecutingOrders      = "error executing pending orders"
+	ErrCancellingOrder      = "error cancelling order for user"
+	ErrGettingHistory       = "error getting allowance history for user"
+	ErrOpeningLifetimeISA   = "error opening lifetime ISA for user"
+	ErrGettingLifetimeISA   = "error getting lifetime ISA for user"
+	ErrRequestingTransfer   = "error requesting transfer for user"
+	ErrGettingTransfers     = "error getting transfers for user"
+	ErrUpdatingTransfer     = "error updating transfer for user"
+	ErrGettingOrderHistory  = "error getting order history for user"
+	ErrGettingPerformance   = "error getting performance for user"
+	ErrRegisteringBenchmark = "error registering benchmark"
+	ErrSettingFundBenchmark = "error setting fund benchmark"
+	ErrImportingPayroll     = "error importing payroll file"
 
-	// isaAnnualGovernmentAllowance refers to the amount customers can save tax-free
+	ErrSubmittingRiskQuestionnaire = "error submitting risk questionnaire for user"
+
+	// isaAnnualGovernmentAllowance refers to the amount customers can save tax-free across all of their adult ISAs
 	isaAnnualGovernmentAllowance = 20000
+	// lifetimeISAAnnualAllowance is how much of the overall allowance can go into a lifetime ISA
+	lifetimeISAAnnualAllowance = 4000
+	// juniorISAAnnualAllowance is the allowance of a junior ISA, which is separate to the adult allowance
+	juniorISAAnnualAllowance = 9000
+
+	// orderExecutionBatchSize caps how many pending orders are settled in a single transaction
+	orderExecutionBatchSize = 100
+)
+
+var (
+	// ErrInvalidOrderAmount is returned when an order is placed for a non-positive amount
+	ErrInvalidOrderAmount = errors.New("order amount must be greater than zero")
+	// ErrCustomerNotFound is returned for a customer ID we hold no record of
+	ErrCustomerNotFound = errors.New("customer not found")
+	// ErrCustomerNotEligible is returned when the customer's status or residency doesn't allow the operation
+	ErrCustomerNotEligible = errors.New("customer is not eligible")
+	// ErrCustomerTypeNotOffered is returned when funds are asked for by a type of customer we don't offer them to yet
+	ErrCustomerTypeNotOffered = errors.New("funds are not offered to this customer type")
+	// ErrEmployerNotFound is returned for an employer ID we hold no record of
+	ErrEmployerNotFound = errors.New("employer not found")
+	// ErrInvalidPayrollFile is returned when a payroll file can't be read as a CSV
+	ErrInvalidPayrollFile = errors.New("invalid payroll file")
+	// ErrInvalidRiskAnswers is returned when the risk questionnaire isn't fully answered with the options it offers
+	ErrInvalidRiskAnswers = riskprofile.ErrInvalidAnswers
+	// ErrFundUnsuitable is returned when a customer buys a fund riskier than their risk profile without acknowledging it
+	ErrFundUnsuitable = errors.New("fund is riskier than the customer's risk profile")
+	// ErrFundNotFound is returned when an order references a fund that does not exist
+	ErrFundNotFound = errors.New("fund not found")
+	// ErrISAAllowanceExceeded is returned when an order would take the customer over their annual allowance
+	ErrISAAllowanceExceeded = errors.New("order exceeds remaining ISA allowance")
+	// ErrInsufficientShares is returned when a customer tries to sell more shares than they hold
+	ErrInsufficientShares = errors.New("order exceeds shares held")
+	// ErrOrderNotFound is returned when the customer has no order with the requested ID
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrOrderNotCancellable is returned when an order has already left the pending state
+	ErrOrderNotCancellable = errors.New("order is no longer pending and can't be cancelled")
+	// ErrInvalidISAType is returned when an order is placed into an ISA wrapper we don't offer
+	ErrInvalidISAType = errors.New("invalid ISA type")
+	// ErrLifetimeISANotOpen is returned when a customer trades in a lifetime ISA they haven't opened
+	ErrLifetimeISANotOpen = errors.New("lifetime ISA has not been opened")
+	// ErrLifetimeISAAlreadyOpen is returned when a customer tries to open a second lifetime ISA
+	ErrLifetimeISAAlreadyOpen = errors.New("lifetime ISA is already open")
+	// ErrLifetimeISAAgeIneligible is returned when a customer is too young or old to open or pay into a lifetime ISA
+	ErrLifetimeISAAgeIneligible = errors.New("customer's age does not allow this lifetime ISA operation")
+	// ErrInvalidWithdrawalReason is returned for a withdrawal reason that doesn't apply to the order
+	ErrInvalidWithdrawalReason = errors.New("invalid withdrawal reason")
+	// ErrInvalidTransfer is returned when a transfer is requested with a missing or invalid detail
+	ErrInvalidTransfer = errors.New("invalid transfer")
+	// ErrTransferNotFound is returned when the customer has no transfer with the requested ID
+	ErrTransferNotFound = errors.New("transfer not found")
+	// ErrTransferStatusConflict is returned when a transfer can't move from its current status to the requested one
+	ErrTransferStatusConflict = errors.New("transfer can't move to the requested status")
+	// ErrInvalidPeriod is returned when performance is asked for over a period we don't measure
+	ErrInvalidPeriod = errors.New("invalid performance period")
+	// ErrInvalidBenchmark is returned when a benchmark is registered with a missing detail or a level that isn't positive
+	ErrInvalidBenchmark = errors.New("invalid benchmark")
+	// ErrBenchmarkNotFound is returned when a fund is measured against a benchmark that hasn't been registered
+	ErrBenchmarkNotFound = errors.New("benchmark not found")
+	// ErrInvalidFundQuery is returned when funds are filtered, sorted or paged by something we can't list them by
+	ErrInvalidFundQuery = errors.New("invalid fund query")
+	// ErrIdempotencyKeyReused is returned when an idempotency key is sent again with a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key has already been used for a different request")
 )
 
+// MultipleProductsError is returned when a buy order would leave a customer holding more than a single product
+type MultipleProductsError struct {
+	HeldCode      string
+	RequestedCode string
+}
+
+func (e *MultipleProductsError) Error() string {
+	return fmt.Sprintf("customer already holds %s so can't buy %s, only a single product is allowed", e.HeldCode, e.RequestedCode)
+}
+
 // Store represents a collection of methods that can be used to call the store
 type Store interface {
-	GetFunds(ctx context.Context, customerType string) (*storage.Funds, error)
+	GetCustomer(ctx context.Context, customerID int) (*storage.Customer, error)
+	GetEmployer(ctx context.Context, employerID uint) (*storage.Employer, error)
+	GetEmployees(ctx context.Context, employerID uint) ([]storage.Customer, error)
+	SetRiskProfile(ctx context.Context, customerID int, profile schema.RiskScore, at time.Time) (*storage.Customer, error)
+	GetFunds(ctx context.Context, customerType string, query storage.FundQuery) (*storage.Funds, error)
 	GetInvestmentOverview(ctx context.Context, customerID int) ([]storage.InvestmentOverview, error)
-	GetAmountSpentCurrentTaxYear(ctx context.Context, customerID int) (float64, error)
+	GetCurrentTaxYearOrders(ctx context.Context, customerID int) ([]storage.Order, error)
+	GetFund(ctx context.Context, code string, customerType string) (*storage.Fund, error)
+	GetEmployerFunds(ctx context.Context, employerID uint, query storage.FundQuery) (*storage.Funds, error)
+	GetEmployerFund(ctx context.Context, code string, employerID uint) (*storage.Fund, error)
+	CreateOrder(ctx context.Context, order *schema.Orders) (*storage.Order, error)
+	GetAvailableShares(ctx context.Context, customerID int, code string, isaType schema.ISAType) (float64, error)
+	ExecutePendingOrders(ctx context.Context, limit int, executedAt time.Time) ([]storage.Order, error)
+	UpdateOrderStatus(ctx context.Context, customerID int, orderID uint, next schema.OrderStatus) (*storage.Order, error)
+	GetOrderByIdempotencyKey(ctx context.Context, customerID int, key string) (*storage.Order, error)
+	GetHeldFundCodes(ctx context.Context, customerID int) ([]string, error)
+	WithCustomerLock(ctx context.Context, customerID int, fn func(ctx context.Context) error) error
+	GetOrders(ctx context.Context, customerID int) ([]storage.Order, error)
+	GetFundPriceHistory(ctx context.Context, customerID int) ([]storage.FundPrice, error)
+	RegisterBenchmark(ctx context.Context, benchmark *schema.Benchmarks, levels []schema.BenchmarkLevels) (*storage.Benchmark, error)
+	SetFundBenchmark(ctx context.Context, fundCode string, benchmarkCode string) error
+	GetBenchmarkHistory(ctx context.Context, customerID int) ([]storage.BenchmarkLevel, error)
+	CreateLifetimeISA(ctx context.Context, account *schema.LifetimeISAAccounts) (*storage.LifetimeISA, error)
+	GetLifetimeISA(ctx context.Context, customerID int) (*storage.LifetimeISA, error)
+	GetLifetimeISALedger(ctx context.Context, customerID int) ([]storage.LedgerEntry, error)
+	CreateTransfer(ctx context.Context, transfer *schema.Transfers) (*storage.Transfer, error)
+	GetTransfers(ctx context.Context, customerID int) ([]storage.Transfer, error)
+	UpdateTransferStatus(ctx context.Context, customerID int, transferID uint, next schema.TransferStatus, at time.Time) (*storage.Transfer, error)
 }
 
-func (s Service) GetFunds(ctx context.Context, customerType string) (*Funds, error) {
-	// As desired we only want to provide information to retail customers so at this stage we block the operation.
-	if customerType != string(schema.Retail) {
-		return nil, errors.Wrap(errors.New(fmt.Sprintf("%s is wrong customer type", customerType)), ErrGettingFunds)
+// GetFunds returns the funds offered to the customer, which depend on the type of customer their record says they are.
+// Retail customers are offered all of the retail funds, and workplace customers the catalogue of their employer, a page
+// at a time filtered and sorted by the query.
+func (s Service) GetFunds(ctx context.Context, customerID int, query FundQuery) (*Funds, error) {
+	storeQuery, err := toStoreFundQuery(query)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingFunds)
+	}
+
+	customer, err := s.customer(ctx, customerID)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingFunds)
 	}
 
-	// We return all the funds available to "retail" customers
-	storeFunds, err := s.store.GetFunds(ctx, customerType)
+	var storeFunds *storage.Funds
+	switch customer.CustomerType {
+	case schema.Retail:
+		storeFunds, err = s.store.GetFunds(ctx, string(customer.CustomerType), storeQuery)
+	case schema.Workplace:
+		var employerID uint
+		employerID, err = customerEmployer(customer)
+		if err == nil {
+			storeFunds, err = s.store.GetEmployerFunds(ctx, employerID, storeQuery)
+		}
+	default:
+		err = errors.Wrapf(ErrCustomerTypeNotOffered, "%s customer", customer.CustomerType)
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, ErrGettingFunds)
 	}
@@ -51,16 +212,20 @@ func (s Service) GetFunds(ctx context.Context, customerType string) (*Funds, err
 	f := make([]Fund, len(storeFunds.Funds))
 	for i, sf := range storeFunds.Funds {
 		f[i] = Fund{
-			Name:        sf.Name,
-			Description: sf.Description,
-			Code:        sf.Code,
-			AmountGBP:   sf.AmountGBP,
-			RiskScore:   string(sf.RiskScore),
-			LastUpdated: sf.LastUpdated,
+			Name:                sf.Name,
+			Description:         sf.Description,
+			Code:                sf.Code,
+			AmountGBP:           sf.AmountGBP,
+			RiskScore:           string(sf.RiskScore),
+			LastUpdated:         sf.LastUpdated,
+			AnnualChargePercent: sf.AnnualChargePercent,
 		}
 	}
 
-	funds := &Funds{Funds: f}
+	funds := &Funds{Funds: f, Total: storeFunds.Total}
+	if storeFunds.Next != nil {
+		funds.NextCursor = encodeFundCursor(query, storeFunds.Next)
+	}
 
 	return funds, nil
 }
@@ -68,40 +233,329 @@ func (s Service) GetFunds(ctx context.Context, customerType string) (*Funds, err
 func (s Service) GetInvestmentOverview(ctx context.Context, customerID int) (*Overview, error) {
 	// At the moment we only expect a customer to have invested a single type of fund, however, we should keep in mind
 	// that we probably want to support multiple funds in the future.
-	// TODO We should also check whether a user exists here so that we don't just return an empty array.
+	if _, err := s.customer(ctx, customerID); err != nil {
+		return nil, errors.Wrap(err, ErrGettingOverview)
+	}
 
 	investmentSummaries, err := s.store.GetInvestmentOverview(ctx, customerID)
 	if err != nil {
 		return nil, errors.Wrap(err, ErrGettingOverview)
 	}
 
-	// Now we check the total amount the customer has invested in the current tax year to see what remains.
-	totalInvestedCurrentTaxYear, err := s.store.GetAmountSpentCurrentTaxYear(ctx, customerID)
+	// Now we check how the customer has paid into and withdrawn from each ISA in the current tax year to see what
+	// remains.
+	allowances, err := s.currentTaxYearAllowances(ctx, customerID)
 	if err != nil {
 		return nil, errors.Wrap(err, ErrGettingISAAllowance)
 	}
 
+	var totalValue float64
 	is := make([]InvestmentSummary, len(investmentSummaries))
 	for i, sis := range investmentSummaries {
-		is[i] = InvestmentSummary{
-			Name:          sis.Name,
-			Description:   sis.Description,
-			Code:          sis.Code,
-			NetShares:     sis.NetShares,
-			NetInvestment: sis.NetInvestment,
-		}
+		is[i] = valueInvestment(sis)
+		totalValue += is[i].CurrentValueGBP
+	}
+
+	lifetimeISA, err := s.lifetimeISASummary(ctx, customerID)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingLifetimeISA)
 	}
 
 	overview := &Overview{
-		Investments: is,
+		Investments:                is,
+		TotalValueGBP:              money.RoundPence(totalValue),
+		IsaAllowanceCurrentTaxYear: allowances.overallRemaining(),
+		Allowances:                 allowances.report(),
+		LifetimeISA:                lifetimeISA,
+	}
+
+	return overview, nil
+}
+
+func (s Service) PlaceBuyOrder(ctx context.Context, req PlaceBuyOrderRequest) (*Order, error) {
+	// A retried request is answered with the order it created the first time, before any of the checks are repeated.
+	replayed, err := s.replayOrder(ctx, req.CustomerID, req.IdempotencyKey, req.RequestHash)
+	if err != nil || replayed != nil {
+		return replayed, errors.Wrap(err, ErrPlacingBuyOrder)
 	}
 
-	// We check if they have already maxed out their annual tax free allowance
-	if totalInvestedCurrentTaxYear >= isaAnnualGovernmentAllowance {
-		overview.IsaAllowanceCurrentTaxYear = 0
+	if req.AmountGBP <= 0 {
+		return nil, errors.Wrap(ErrInvalidOrderAmount, ErrPlacingBuyOrder)
 	}
 
-	overview.IsaAllowanceCurrentTaxYear = isaAnnualGovernmentAllowance - totalInvestedCurrentTaxYear
+	isaType, err := orderISAType(req.ISAType)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPlacingBuyOrder)
+	}
 
-	return overview, nil
+	customer, err := s.tradingCustomer(ctx, req.CustomerID, true)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPlacingBuyOrder)
+	}
+
+	if isaType == schema.Lifetime {
+		if err := s.checkLifetimeISAContribution(ctx, customer); err != nil {
+			return nil, errors.Wrap(err, ErrPlacingBuyOrder)
+		}
+	}
+
+	// Customers can only buy the funds they are offered, in line with GetFunds.
+	fund, err := s.offeredFund(ctx, customer, req.Code)
+	if errors.Is(err, storage.ErrFundNotFound) {
+		return nil, errors.Wrap(ErrFundNotFound, ErrPlacingBuyOrder)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPlacingBuyOrder)
+	}
+
+	if fund.AmountGBP <= 0 {
+		return nil, errors.Wrap(errors.New(fmt.Sprintf("%s has no price", fund.Code)), ErrPlacingBuyOrder)
+	}
+
+	// A fund riskier than the customer's profile is only bought once they have acknowledged the risk, and the order
+	// records that it overrode their profile.
+	riskOverride, err := checkSuitability(customer, fund, req.AcknowledgeRisk)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPlacingBuyOrder)
+	}
+
+	// The checks against the customer's existing orders and the insert run under a lock on the customer, otherwise
+	// concurrent orders could each pass the checks before any of them has been inserted.
+	var order *Order
+	err = s.store.WithCustomerLock(ctx, req.CustomerID, func(ctx context.Context) error {
+		// A retry that waited on the lock behind the request it repeats is answered with that request's order, rather
+		// than checked again against the subscription it made.
+		replayed, err := s.replayOrder(ctx, req.CustomerID, req.IdempotencyKey, req.RequestHash)
+		if err != nil || replayed != nil {
+			order = replayed
+			return err
+		}
+
+		// Customers are restricted to a single product unless the rule has been lifted, so a buy into any other fund
+		// is refused while they still hold (or are waiting to hold) one.
+		if !s.allowMultipleProducts {
+			heldCodes, err := s.store.GetHeldFundCodes(ctx, req.CustomerID)
+			if err != nil {
+				return err
+			}
+
+			for _, code := range heldCodes {
+				if code != fund.Code {
+					return &MultipleProductsError{HeldCode: code, RequestedCode: fund.Code}
+				}
+			}
+		}
+
+		// Every subscription counts towards the allowance of its wrapper, less any withdrawals it replaces, so we reject
+		// anything that would take the customer over it.
+		allowances, err := s.currentTaxYearAllowances(ctx, req.CustomerID)
+		if err != nil {
+			return errors.Wrap(err, ErrGettingISAAllowance)
+		}
+
+		if req.AmountGBP > allowances.remaining(isaType) {
+			return errors.Wrapf(ErrISAAllowanceExceeded, "%s ISA", isaType)
+		}
+
+		// Orders are queued rather than filled instantly, the shares recorded here are an estimate until execution.
+		order, err = s.createOrder(ctx, &schema.Orders{
+			OrderType:         schema.Buy,
+			CustomerID:        uint(req.CustomerID),
+			FundID:            fund.ID,
+			Name:              fund.Name,
+			Description:       fund.Description,
+			Code:              fund.Code,
+			ISAType:           isaType,
+			Shares:            req.AmountGBP / fund.AmountGBP,
+			PurchasedValueGBP: req.AmountGBP,
+			OrderTime:         s.clock.Now(),
+			Status:            schema.Pending,
+			RiskOverride:      riskOverride,
+			RiskProfile:       riskProfileOf(customer),
+		}, req.IdempotencyKey, req.RequestHash)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPlacingBuyOrder)
+	}
+
+	return order, nil
+}
+
+func (s Service) PlaceSellOrder(ctx context.Context, req PlaceSellOrderRequest) (*Order, error) {
+	replayed, err := s.replayOrder(ctx, req.CustomerID, req.IdempotencyKey, req.RequestHash)
+	if err != nil || replayed != nil {
+		return replayed, errors.Wrap(err, ErrPlacingSellOrder)
+	}
+
+	if req.Shares <= 0 {
+		return nil, errors.Wrap(ErrInvalidOrderAmount, ErrPlacingSellOrder)
+	}
+
+	isaType, err := orderISAType(req.ISAType)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPlacingSellOrder)
+	}
+
+	customer, err := s.tradingCustomer(ctx, req.CustomerID, false)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPlacingSellOrder)
+	}
+
+	// The reason is recorded on lifetime ISA withdrawals so that unauthorised ones are charged when they execute.
+	var withdrawalReason *schema.WithdrawalReason
+	if isaType == schema.Lifetime {
+		reason, err := s.lifetimeISAWithdrawalReason(ctx, customer, req.WithdrawalReason)
+		if err != nil {
+			return nil, errors.Wrap(err, ErrPlacingSellOrder)
+		}
+		withdrawalReason = &reason
+	} else if req.WithdrawalReason != "" {
+		return nil, errors.Wrapf(ErrInvalidWithdrawalReason, "%s ISA", isaType)
+	}
+
+	fund, err := s.store.GetFund(ctx, req.Code, string(customer.CustomerType))
+	if errors.Is(err, storage.ErrFundNotFound) {
+		return nil, errors.Wrap(ErrFundNotFound, ErrPlacingSellOrder)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPlacingSellOrder)
+	}
+
+	var order *Order
+	err = s.store.WithCustomerLock(ctx, req.CustomerID, func(ctx context.Context) error {
+		// As with buys, a retry that waited on the lock replays the order rather than checking the holding it sold from.
+		replayed, err := s.replayOrder(ctx, req.CustomerID, req.IdempotencyKey, req.RequestHash)
+		if err != nil || replayed != nil {
+			order = replayed
+			return err
+		}
+
+		// Shares can only be sold from the wrapper they are held in, and shares already queued for sale are still held
+		// until executed but can't be sold twice.
+		availableShares, err := s.store.GetAvailableShares(ctx, req.CustomerID, fund.Code, isaType)
+		if err != nil {
+			return err
+		}
+
+		if req.Shares > availableShares {
+			return ErrInsufficientShares
+		}
+
+		// Proceeds are always priced from the fund itself so that clients cannot set their own price, like buys the
+		// value is an estimate until the order is executed.
+		order, err = s.createOrder(ctx, &schema.Orders{
+			OrderType:         schema.Sell,
+			CustomerID:        uint(req.CustomerID),
+			FundID:            fund.ID,
+			Name:              fund.Name,
+			Description:       fund.Description,
+			Code:              fund.Code,
+			ISAType:           isaType,
+			Shares:            req.Shares,
+			PurchasedValueGBP: req.Shares * fund.AmountGBP,
+			OrderTime:         s.clock.Now(),
+			Status:            schema.Pending,
+			WithdrawalReason:  withdrawalReason,
+		}, req.IdempotencyKey, req.RequestHash)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPlacingSellOrder)
+	}
+
+	return order, nil
+}
+
+// createOrder persists a new order along with the idempotency key of the request that placed it. If a concurrent
+// request with the same key wins the race to insert, its order is replayed instead.
+func (s Service) createOrder(ctx context.Context, order *schema.Orders, key string, requestHash string) (*Order, error) {
+	if key != "" {
+		order.IdempotencyKey = &key
+		order.RequestHash = requestHash
+	}
+
+	storeOrder, err := s.store.CreateOrder(ctx, order)
+	if errors.Is(err, storage.ErrDuplicateIdempotencyKey) {
+		return s.replayOrder(ctx, int(order.CustomerID), key, requestHash)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toOrder(storeOrder), nil
+}
+
+// replayOrder returns the order previously created with the idempotency key, or nil if the key hasn't been used. The
+// key may only be reused for an identical request.
+func (s Service) replayOrder(ctx context.Context, customerID int, key string, requestHash string) (*Order, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	storeOrder, err := s.store.GetOrderByIdempotencyKey(ctx, customerID, key)
+	if errors.Is(err, storage.ErrOrderNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if storeOrder.RequestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+
+	order := toOrder(storeOrder)
+	order.Replayed = true
+	return order, nil
+}
+
+// CancelOrder cancels one of the customer's orders before it reaches its valuation point. As allowance is only used by
+// pending and executed orders, cancelling a buy frees up the allowance it had reserved.
+func (s Service) CancelOrder(ctx context.Context, customerID int, orderID uint) (*Order, error) {
+	storeOrder, err := s.store.UpdateOrderStatus(ctx, customerID, orderID, schema.Cancelled)
+	if errors.Is(err, storage.ErrOrderNotFound) {
+		return nil, errors.Wrap(ErrOrderNotFound, ErrCancellingOrder)
+	}
+	if errors.Is(err, storage.ErrInvalidOrderTransition) {
+		return nil, errors.Wrap(ErrOrderNotCancellable, ErrCancellingOrder)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrCancellingOrder)
+	}
+
+	return toOrder(storeOrder), nil
+}
+
+// ExecutePendingOrders settles a batch of queued orders and returns how many were processed. Orders that can't be
+// priced are rejected and included in the count.
+func (s Service) ExecutePendingOrders(ctx context.Context) (int, error) {
+	settled, err := s.store.ExecutePendingOrders(ctx, orderExecutionBatchSize, s.clock.Now())
+	if err != nil {
+		return 0, errors.Wrap(err, ErrExecutingOrders)
+	}
+
+	return len(settled), nil
+}
+
+// toOrder maps the store's order model onto the one returned by the service.
+func toOrder(o *storage.Order) *Order {
+	return &Order{
+		OrderID:           o.OrderID,
+		CustomerID:        o.CustomerID,
+		OrderType:         o.OrderType,
+		Name:              o.Name,
+		Code:              o.Code,
+		ISAType:           o.ISAType,
+		PurchaseTime:      o.PurchaseTime,
+		SharesPurchased:   o.SharesPurchased,
+		AmountGBP:         o.AmountGBP,
+		Status:            o.Status,
+		ExecutionTime:     o.ExecutionTime,
+		ExecutionPriceGBP: o.ExecutionPriceGBP,
+		WithdrawalReason:  o.WithdrawalReason,
+		BookCostGBP:       o.BookCostGBP,
+		RealisedGainGBP:   o.RealisedGainGBP,
+		RiskOverride:      o.RiskOverride,
+	}
 }
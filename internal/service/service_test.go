@@ -2,16 +2,46 @@ package service_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"github.com/golang/mock/gomock"
+	"github.com/jautyw/isa-investment-funds/internal/clock"
+	"github.com/jautyw/isa-investment-funds/internal/payroll"
+	"github.com/jautyw/isa-investment-funds/internal/performance"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
 	"github.com/jautyw/isa-investment-funds/internal/service"
 	mocks "github.com/jautyw/isa-investment-funds/internal/service/mocks"
 	"github.com/jautyw/isa-investment-funds/internal/storage"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 	"time"
 )
 
+// expectCustomerLock runs the locked section of an order inline, as the store would inside its transaction
+func expectCustomerLock(ms *mocks.MockStore, customerID int) {
+	ms.EXPECT().WithCustomerLock(gomock.Any(), customerID, gomock.Any()).DoAndReturn(func(ctx context.Context, _ int, fn func(context.Context) error) error {
+		return fn(ctx)
+	}).Times(1)
+}
+
+// expectCustomer finds the customer as an active, UK resident, retail customer
+func expectCustomer(ms *mocks.MockStore, customerID int) {
+	expectCustomerBornOn(ms, customerID, time.Date(1990, 6, 15, 0, 0, 0, 0, time.UTC))
+}
+
+// expectCustomerBornOn finds the customer as expectCustomer does, with the given date of birth
+func expectCustomerBornOn(ms *mocks.MockStore, customerID int, dateOfBirth time.Time) {
+	ms.EXPECT().GetCustomer(gomock.Any(), customerID).Return(&storage.Customer{
+		CustomerID:   uint(customerID),
+		CustomerType: schema.Retail,
+		DateOfBirth:  dateOfBirth,
+		Residency:    schema.UKResident,
+		Status:       schema.CustomerActive,
+	}, nil).AnyTimes()
+}
+
 func TestNewService(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -71,14 +101,15 @@ func TestService_GetFunds(t *testing.T) {
 		},
 	}
 
-	ms.EXPECT().GetFunds(ctx, "retail").Return(storeFunds, nil).Times(1)
+	expectCustomer(ms, 1)
+	ms.EXPECT().GetFunds(ctx, "retail", storage.FundQuery{Limit: 20}).Return(storeFunds, nil).Times(1)
 
-	f, err := h.GetFunds(ctx, "retail")
+	f, err := h.GetFunds(ctx, 1, service.FundQuery{})
 	assert.NoError(t, err)
 	assert.Equal(t, serviceFunds, f)
 }
 
-func TestService_GetFundsInvalidCustomer(t *testing.T) {
+func TestService_GetFundsQuery(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	ms := mocks.NewMockStore(ctrl)
@@ -86,10 +117,131 @@ func TestService_GetFundsInvalidCustomer(t *testing.T) {
 	assert.NotNil(t, h)
 
 	ctx := context.Background()
+	minPrice, maxPrice := 1.0, 10.0
+	query := service.FundQuery{RiskScore: schema.Medium, Search: " esg ", MinPriceGBP: &minPrice, MaxPriceGBP: &maxPrice, SortBy: service.SortFundsByPrice, Descending: true, Limit: 1}
 
-	_, err := h.GetFunds(ctx, "workplace")
-	assert.Error(t, err)
-	assert.ErrorContains(t, err, "workplace is wrong customer type")
+	expectCustomer(ms, 1)
+	ms.EXPECT().GetFunds(ctx, "retail", storage.FundQuery{RiskScore: schema.Medium, Search: "esg", MinPriceGBP: &minPrice, MaxPriceGBP: &maxPrice, SortBy: storage.SortFundsByPrice, Descending: true, Limit: 1}).Return(&storage.Funds{
+		Funds: []storage.Fund{{ID: 3, Name: "ESG Global All Cap UCITS ETF", Code: "V3AM", AmountGBP: 4.92}},
+		Total: 2,
+		Next:  &storage.FundCursor{ID: 3, Name: "ESG Global All Cap UCITS ETF", Code: "V3AM", PriceGBP: 4.92},
+	}, nil).Times(1)
+
+	f, err := h.GetFunds(ctx, 1, query)
+	assert.NoError(t, err)
+	assert.Len(t, f.Funds, 1)
+	assert.Equal(t, int64(2), f.Total)
+	assert.NotEmpty(t, f.NextCursor)
+
+	// The next page continues from the last fund on the first
+	ms.EXPECT().GetFunds(ctx, "retail", gomock.Any()).DoAndReturn(func(_ context.Context, _ string, q storage.FundQuery) (*storage.Funds, error) {
+		assert.Equal(t, &storage.FundCursor{ID: 3, Name: "ESG Global All Cap UCITS ETF", Code: "V3AM", PriceGBP: 4.92}, q.After)
+		return &storage.Funds{Funds: []storage.Fund{{ID: 1, Code: "V3AB", AmountGBP: 2.5}}, Total: 2}, nil
+	}).Times(1)
+
+	query.Cursor = f.NextCursor
+	f, err = h.GetFunds(ctx, 1, query)
+	assert.NoError(t, err)
+	assert.Len(t, f.Funds, 1)
+	assert.Empty(t, f.NextCursor)
+}
+
+func TestService_GetFundsInvalidQuery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	minPrice, maxPrice, negative := 10.0, 1.0, -1.0
+
+	expectCustomer(ms, 1)
+	ms.EXPECT().GetFunds(ctx, "retail", gomock.Any()).Return(&storage.Funds{
+		Funds: []storage.Fund{{ID: 3, Code: "V3AM"}},
+		Total: 2,
+		Next:  &storage.FundCursor{ID: 3, Code: "V3AM"},
+	}, nil).Times(1)
+
+	f, err := h.GetFunds(ctx, 1, service.FundQuery{SortBy: service.SortFundsByCode, Limit: 1})
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		query   service.FundQuery
+		message string
+	}{
+		"unknown risk score":        {query: service.FundQuery{RiskScore: "extreme"}, message: "extreme is not a risk score"},
+		"negative price":            {query: service.FundQuery{MinPriceGBP: &negative}, message: "prices can't be negative"},
+		"inverted range":            {query: service.FundQuery{MinPriceGBP: &minPrice, MaxPriceGBP: &maxPrice}, message: "minimum price is above the maximum price"},
+		"unknown sort":              {query: service.FundQuery{SortBy: "charge"}, message: "funds can't be sorted by charge"},
+		"page too large":            {query: service.FundQuery{Limit: 101}, message: "limit must be from 1 to 100"},
+		"malformed cursor":          {query: service.FundQuery{Cursor: "not a cursor"}, message: "cursor is invalid"},
+		"cursor sorted differently": {query: service.FundQuery{SortBy: service.SortFundsByName, Cursor: f.NextCursor}, message: "cursor is from a listing sorted differently"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := h.GetFunds(ctx, 1, tt.query)
+			assert.ErrorIs(t, err, service.ErrInvalidFundQuery)
+			assert.ErrorContains(t, err, tt.message)
+			assert.ErrorContains(t, err, service.ErrGettingFunds)
+		})
+	}
+}
+
+func TestService_GetFundsWorkplaceCustomer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	employerID := uint(7)
+	charge := 0.15
+
+	ms.EXPECT().GetCustomer(ctx, 2).Return(&storage.Customer{CustomerID: 2, CustomerType: schema.Workplace, EmployerID: &employerID, Residency: schema.UKResident, Status: schema.CustomerActive}, nil).Times(1)
+	ms.EXPECT().GetEmployerFunds(ctx, employerID, storage.FundQuery{Limit: 20}).Return(&storage.Funds{Funds: []storage.Fund{
+		{ID: 3, Name: "ESG Global All Cap UCITS ETF", Code: "V3AM", AmountGBP: 4.92, RiskScore: schema.Medium, AnnualChargePercent: &charge},
+	}}, nil).Times(1)
+
+	f, err := h.GetFunds(ctx, 2, service.FundQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, &service.Funds{Funds: []service.Fund{
+		{Name: "ESG Global All Cap UCITS ETF", Code: "V3AM", AmountGBP: 4.92, RiskScore: "medium", AnnualChargePercent: &charge},
+	}}, f)
+}
+
+func TestService_GetFundsWorkplaceCustomerWithoutEmployer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	ms.EXPECT().GetCustomer(ctx, 2).Return(&storage.Customer{CustomerID: 2, CustomerType: schema.Workplace, Residency: schema.UKResident, Status: schema.CustomerActive}, nil).Times(1)
+
+	_, err := h.GetFunds(ctx, 2, service.FundQuery{})
+	assert.ErrorIs(t, err, service.ErrCustomerNotEligible)
+	assert.ErrorContains(t, err, "workplace customer is not linked to an employer")
+	assert.ErrorContains(t, err, service.ErrGettingFunds)
+}
+
+func TestService_GetFundsCustomerNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	ms.EXPECT().GetCustomer(ctx, 404).Return(nil, storage.ErrCustomerNotFound).Times(1)
+
+	_, err := h.GetFunds(ctx, 404, service.FundQuery{})
+	assert.ErrorIs(t, err, service.ErrCustomerNotFound)
 	assert.ErrorContains(t, err, service.ErrGettingFunds)
 }
 
@@ -102,9 +254,10 @@ func TestService_GetFundsError(t *testing.T) {
 
 	ctx := context.Background()
 
-	ms.EXPECT().GetFunds(ctx, "retail").Return(nil, errors.New(service.ErrGettingFunds)).Times(1)
+	expectCustomer(ms, 1)
+	ms.EXPECT().GetFunds(ctx, "retail", gomock.Any()).Return(nil, errors.New(service.ErrGettingFunds)).Times(1)
 
-	_, err := h.GetFunds(ctx, "retail")
+	_, err := h.GetFunds(ctx, 1, service.FundQuery{})
 	assert.Error(t, err)
 	assert.ErrorContains(t, err, service.ErrGettingFunds)
 }
@@ -117,6 +270,7 @@ func TestService_GetInvestmentOverview(t *testing.T) {
 	assert.NotNil(t, h)
 
 	ctx := context.Background()
+	expectCustomer(ms, 10000)
 
 	storeFunds := []storage.InvestmentOverview{
 		{
@@ -125,44 +279,95 @@ func TestService_GetInvestmentOverview(t *testing.T) {
 			Code:          "V3AM",
 			NetShares:     5,
 			NetInvestment: 24.6,
+			BookCostGBP:   24.6,
+			PriceGBP:      5.5,
 		},
 		{
+			// Never priced so can't be valued
 			Name:          "ESG Global All Cap UCITS ETF - (USD) Accumulating",
 			Description:   "Some desc 2",
 			Code:          "V3AB",
 			NetShares:     10,
 			NetInvestment: 49.2,
+			BookCostGBP:   50,
 		},
 	}
 
 	expectedOverview := &service.Overview{
 		Investments: []service.InvestmentSummary{
 			{
-				Name:          "ESG Global All Cap UCITS ETF",
-				Description:   "Some desc",
-				Code:          "V3AM",
-				NetShares:     5,
-				NetInvestment: 24.6,
+				Name:                  "ESG Global All Cap UCITS ETF",
+				Description:           "Some desc",
+				Code:                  "V3AM",
+				NetShares:             5,
+				NetInvestment:         24.6,
+				BookCostGBP:           24.6,
+				AverageCostGBP:        24.6 / 5,
+				PriceGBP:              5.5,
+				CurrentValueGBP:       27.5,
+				UnrealisedGainGBP:     2.9,
+				UnrealisedGainPercent: 11.79,
 			},
 			{
-				Name:          "ESG Global All Cap UCITS ETF - (USD) Accumulating",
-				Description:   "Some desc 2",
-				Code:          "V3AB",
-				NetShares:     10,
-				NetInvestment: 49.2,
+				Name:           "ESG Global All Cap UCITS ETF - (USD) Accumulating",
+				Description:    "Some desc 2",
+				Code:           "V3AB",
+				NetShares:      10,
+				NetInvestment:  49.2,
+				BookCostGBP:    50,
+				AverageCostGBP: 5,
 			},
 		},
+		TotalValueGBP:              27.5,
 		IsaAllowanceCurrentTaxYear: 19926.2,
+		Allowances: []service.ISAAllowance{
+			{ISAType: schema.StocksAndShares, SubscribedGBP: 73.8, RemainingGBP: 19926.2},
+			{ISAType: schema.Cash, RemainingGBP: 19926.2},
+			{ISAType: schema.Lifetime, RemainingGBP: 4000},
+			{ISAType: schema.Junior, RemainingGBP: 9000},
+		},
 	}
 
 	ms.EXPECT().GetInvestmentOverview(ctx, 10000).Return(storeFunds, nil).Times(1)
-	ms.EXPECT().GetAmountSpentCurrentTaxYear(ctx, 10000).Return(73.8, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return([]storage.Order{{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 73.8}}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(nil, storage.ErrLifetimeISANotFound).Times(1)
 
 	overview, err := h.GetInvestmentOverview(ctx, 10000)
 	assert.NoError(t, err)
 	assert.Equal(t, expectedOverview, overview)
 }
 
+func TestService_GetInvestmentOverviewAfterProfitableSale(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	ms.EXPECT().GetInvestmentOverview(ctx, 10000).Return([]storage.InvestmentOverview{
+		// £1,000 was invested and £700 taken out again at a £200 profit, so £500 of book cost remains
+		{Code: "V3AM", ISAType: schema.StocksAndShares, NetShares: 100, NetInvestment: 300, BookCostGBP: 500, RealisedGainGBP: 200, PriceGBP: 4.5},
+		{Code: "V3AM", ISAType: schema.Lifetime, NetShares: 10, NetInvestment: 40, BookCostGBP: 40, PriceGBP: 4.5},
+	}, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(nil, storage.ErrLifetimeISANotFound).Times(1)
+
+	overview, err := h.GetInvestmentOverview(ctx, 10000)
+	assert.NoError(t, err)
+	assert.Equal(t, 450.0, overview.Investments[0].CurrentValueGBP)
+	assert.Equal(t, 5.0, overview.Investments[0].AverageCostGBP)
+	assert.Equal(t, 200.0, overview.Investments[0].RealisedGainGBP)
+	assert.Equal(t, -50.0, overview.Investments[0].UnrealisedGainGBP)
+	assert.Equal(t, -10.0, overview.Investments[0].UnrealisedGainPercent)
+	assert.Equal(t, 5.0, overview.Investments[1].UnrealisedGainGBP)
+	assert.Equal(t, 495.0, overview.TotalValueGBP)
+}
+
 func TestService_GetInvestmentOverviewError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -171,6 +376,7 @@ func TestService_GetInvestmentOverviewError(t *testing.T) {
 	assert.NotNil(t, h)
 
 	ctx := context.Background()
+	expectCustomer(ms, 10000)
 	ms.EXPECT().GetInvestmentOverview(ctx, 10000).Return(nil, errors.New(service.ErrGettingOverview)).Times(1)
 
 	_, err := h.GetInvestmentOverview(ctx, 10000)
@@ -178,6 +384,22 @@ func TestService_GetInvestmentOverviewError(t *testing.T) {
 	assert.ErrorContains(t, err, service.ErrGettingOverview)
 }
 
+func TestService_GetInvestmentOverviewCustomerNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	ms.EXPECT().GetCustomer(ctx, 404).Return(nil, storage.ErrCustomerNotFound).Times(1)
+
+	// Nothing else is looked up for a customer we don't know
+	_, err := h.GetInvestmentOverview(ctx, 404)
+	assert.ErrorIs(t, err, service.ErrCustomerNotFound)
+	assert.ErrorContains(t, err, service.ErrGettingOverview)
+}
+
 func TestService_GetInvestmentISAAllowanceError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -186,6 +408,7 @@ func TestService_GetInvestmentISAAllowanceError(t *testing.T) {
 	assert.NotNil(t, h)
 
 	ctx := context.Background()
+	expectCustomer(ms, 10000)
 
 	storeFunds := []storage.InvestmentOverview{
 		{
@@ -205,9 +428,1576 @@ func TestService_GetInvestmentISAAllowanceError(t *testing.T) {
 	}
 
 	ms.EXPECT().GetInvestmentOverview(ctx, 10000).Return(storeFunds, nil).Times(1)
-	ms.EXPECT().GetAmountSpentCurrentTaxYear(ctx, 10000).Return(float64(0), errors.New(service.ErrGettingISAAllowance)).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return(nil, errors.New(service.ErrGettingISAAllowance)).Times(1)
 
 	_, err := h.GetInvestmentOverview(ctx, 10000)
 	assert.Error(t, err)
 	assert.ErrorContains(t, err, service.ErrGettingISAAllowance)
 }
+
+func TestService_PlaceBuyOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	storeFund := &storage.Fund{
+		ID:          1,
+		Name:        "ESG Global All Cap UCITS ETF",
+		Description: "Some desc",
+		Code:        "V3AM",
+		AmountGBP:   4.92,
+		RiskScore:   "medium",
+	}
+
+	storeOrder := &storage.Order{
+		OrderID:         7,
+		CustomerID:      10000,
+		Name:            "ESG Global All Cap UCITS ETF",
+		Code:            "V3AM",
+		PurchaseTime:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		OrderType:       "buy",
+		SharesPurchased: 100,
+		AmountGBP:       492,
+	}
+
+	expectedOrder := &service.Order{
+		OrderID:         7,
+		CustomerID:      10000,
+		OrderType:       "buy",
+		Name:            "ESG Global All Cap UCITS ETF",
+		Code:            "V3AM",
+		PurchaseTime:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		SharesPurchased: 100,
+		AmountGBP:       492,
+	}
+
+	ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(storeFund, nil).Times(1)
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetHeldFundCodes(ctx, 10000).Return([]string{"V3AM"}, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return([]storage.Order{{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 19000}}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().CreateOrder(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, o *schema.Orders) (*storage.Order, error) {
+		assert.Equal(t, schema.Buy, o.OrderType)
+		assert.Equal(t, schema.Pending, o.Status)
+		assert.Equal(t, uint(10000), o.CustomerID)
+		assert.Equal(t, uint(1), o.FundID)
+		assert.Equal(t, float64(492), o.PurchasedValueGBP)
+		assert.Equal(t, now, o.OrderTime)
+		assert.InDelta(t, 100, o.Shares, 0.0001)
+		assert.Equal(t, schema.StocksAndShares, o.ISAType)
+		return storeOrder, nil
+	}).Times(1)
+
+	order, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", AmountGBP: 492})
+	assert.NoError(t, err)
+	assert.Equal(t, expectedOrder, order)
+}
+
+func TestService_PlaceBuyOrderInvalidAmount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", AmountGBP: -1})
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, service.ErrInvalidOrderAmount)
+	assert.ErrorContains(t, err, service.ErrPlacingBuyOrder)
+}
+
+func TestService_PlaceBuyOrderFundNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	ms.EXPECT().GetFund(ctx, "NOPE", "retail").Return(nil, storage.ErrFundNotFound).Times(1)
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "NOPE", AmountGBP: 100})
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, service.ErrFundNotFound)
+}
+
+func TestService_PlaceBuyOrderCustomerNotEligible(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	// Customers who have moved abroad can't subscribe but can still sell what they hold
+	ms.EXPECT().GetCustomer(ctx, 10000).Return(&storage.Customer{CustomerID: 10000, CustomerType: schema.Retail, Residency: schema.NonUKResident, Status: schema.CustomerActive}, nil).Times(1)
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", AmountGBP: 100})
+	assert.ErrorIs(t, err, service.ErrCustomerNotEligible)
+	assert.ErrorContains(t, err, "only UK residents can subscribe to an ISA")
+
+	// Suspended customers can't place any orders
+	ms.EXPECT().GetCustomer(ctx, 10000).Return(&storage.Customer{CustomerID: 10000, CustomerType: schema.Retail, Residency: schema.UKResident, Status: schema.CustomerSuspended}, nil).Times(1)
+
+	_, err = h.PlaceSellOrder(ctx, service.PlaceSellOrderRequest{CustomerID: 10000, Code: "V3AM", Shares: 10})
+	assert.ErrorIs(t, err, service.ErrCustomerNotEligible)
+	assert.ErrorContains(t, err, "customer is suspended")
+}
+
+func TestService_PlaceBuyOrderNotInEmployerCatalogue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	employerID := uint(7)
+
+	// Workplace customers can only buy the funds their employer has approved
+	ms.EXPECT().GetCustomer(ctx, 2).Return(&storage.Customer{CustomerID: 2, CustomerType: schema.Workplace, EmployerID: &employerID, Residency: schema.UKResident, Status: schema.CustomerActive}, nil).Times(1)
+	ms.EXPECT().GetEmployerFund(ctx, "V3AB", employerID).Return(nil, storage.ErrFundNotFound).Times(1)
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 2, Code: "V3AB", AmountGBP: 100})
+	assert.ErrorIs(t, err, service.ErrFundNotFound)
+}
+
+func TestService_PlaceBuyOrderAllowanceExceeded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(&storage.Fund{ID: 1, Code: "V3AM", AmountGBP: 4.92}, nil).Times(1)
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetHeldFundCodes(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return([]storage.Order{{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 19900}}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", AmountGBP: 100.01})
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, service.ErrISAAllowanceExceeded)
+	assert.ErrorContains(t, err, service.ErrPlacingBuyOrder)
+}
+
+func TestService_PlaceBuyOrderLifetimeISAAllowanceExceeded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	// Plenty of the overall allowance is left but the lifetime ISA has its own limit
+	ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(&storage.LifetimeISA{CustomerID: 10000}, nil).Times(1)
+	ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(&storage.Fund{ID: 1, Code: "V3AM", AmountGBP: 4.92}, nil).Times(1)
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetHeldFundCodes(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return([]storage.Order{{OrderType: schema.Buy, ISAType: schema.Lifetime, AmountGBP: 3500}}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", ISAType: schema.Lifetime, AmountGBP: 600})
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, service.ErrISAAllowanceExceeded)
+}
+
+func TestService_PlaceBuyOrderLifetimeISAOverallAllowanceExceeded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	// The lifetime ISA is untouched but the overall allowance has been used elsewhere
+	ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(&storage.LifetimeISA{CustomerID: 10000}, nil).Times(1)
+	ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(&storage.Fund{ID: 1, Code: "V3AM", AmountGBP: 4.92}, nil).Times(1)
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetHeldFundCodes(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return([]storage.Order{
+		{OrderType: schema.Buy, ISAType: schema.Cash, AmountGBP: 5000},
+		{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 14000},
+	}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", ISAType: schema.Lifetime, AmountGBP: 1500})
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, service.ErrISAAllowanceExceeded)
+}
+
+func TestService_PlaceBuyOrderJuniorISASeparateAllowance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	// The adult allowance is used up, which has no bearing on the junior ISA
+	ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(&storage.Fund{ID: 1, Code: "V3AM", AmountGBP: 4.92}, nil).Times(1)
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetHeldFundCodes(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return([]storage.Order{
+		{OrderType: schema.Buy, ISAType: schema.Junior, AmountGBP: 4000},
+		{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 20000},
+	}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().CreateOrder(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, o *schema.Orders) (*storage.Order, error) {
+		assert.Equal(t, schema.Junior, o.ISAType)
+		return &storage.Order{OrderID: 7, ISAType: o.ISAType, AmountGBP: o.PurchasedValueGBP}, nil
+	}).Times(1)
+
+	order, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", ISAType: schema.Junior, AmountGBP: 5000})
+	assert.NoError(t, err)
+	assert.Equal(t, schema.Junior, order.ISAType)
+
+	ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(&storage.Fund{ID: 1, Code: "V3AM", AmountGBP: 4.92}, nil).Times(1)
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetHeldFundCodes(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return([]storage.Order{{OrderType: schema.Buy, ISAType: schema.Junior, AmountGBP: 9000}}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+
+	_, err = h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", ISAType: schema.Junior, AmountGBP: 1})
+	assert.ErrorIs(t, err, service.ErrISAAllowanceExceeded)
+}
+
+func TestService_PlaceBuyOrderInvalidISAType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", ISAType: "pension", AmountGBP: 100})
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, service.ErrInvalidISAType)
+}
+
+func TestService_PlaceSellOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	storeFund := &storage.Fund{ID: 1, Name: "ESG Global All Cap UCITS ETF", Code: "V3AM", AmountGBP: 5}
+
+	ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(storeFund, nil).Times(1)
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetAvailableShares(ctx, 10000, "V3AM", schema.StocksAndShares).Return(float64(40), nil).Times(1)
+	ms.EXPECT().CreateOrder(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, o *schema.Orders) (*storage.Order, error) {
+		assert.Equal(t, schema.Sell, o.OrderType)
+		assert.Equal(t, schema.Pending, o.Status)
+		assert.Equal(t, float64(20), o.Shares)
+		assert.Equal(t, float64(100), o.PurchasedValueGBP)
+		return &storage.Order{OrderID: 8, CustomerID: 10000, Code: "V3AM", OrderType: o.OrderType, SharesPurchased: o.Shares, AmountGBP: o.PurchasedValueGBP}, nil
+	}).Times(1)
+
+	order, err := h.PlaceSellOrder(ctx, service.PlaceSellOrderRequest{CustomerID: 10000, Code: "V3AM", Shares: 20})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(8), order.OrderID)
+	assert.Equal(t, float64(100), order.AmountGBP)
+}
+
+func TestService_PlaceSellOrderInsufficientShares(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(&storage.Fund{ID: 1, Code: "V3AM", AmountGBP: 5}, nil).Times(1)
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetAvailableShares(ctx, 10000, "V3AM", schema.StocksAndShares).Return(float64(40), nil).Times(1)
+
+	// 50 shares are held but 10 of them are already queued for sale
+	_, err := h.PlaceSellOrder(ctx, service.PlaceSellOrderRequest{CustomerID: 10000, Code: "V3AM", Shares: 40.5})
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, service.ErrInsufficientShares)
+	assert.ErrorContains(t, err, service.ErrPlacingSellOrder)
+}
+
+func TestService_PlaceSellOrderSharesHeldInAnotherISA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(&storage.LifetimeISA{CustomerID: 10000}, nil).Times(1)
+	ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(&storage.Fund{ID: 1, Code: "V3AM", AmountGBP: 5}, nil).Times(1)
+	expectCustomerLock(ms, 10000)
+	// The 50 shares held are in the stocks and shares ISA, so none are available to sell from the lifetime ISA
+	ms.EXPECT().GetAvailableShares(ctx, 10000, "V3AM", schema.Lifetime).Return(float64(0), nil).Times(1)
+
+	_, err := h.PlaceSellOrder(ctx, service.PlaceSellOrderRequest{CustomerID: 10000, Code: "V3AM", ISAType: schema.Lifetime, Shares: 20})
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, service.ErrInsufficientShares)
+}
+
+func TestService_ExecutePendingOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	ms.EXPECT().ExecutePendingOrders(ctx, 100, now).Return([]storage.Order{{OrderID: 1}, {OrderID: 2}}, nil).Times(1)
+
+	executed, err := h.ExecutePendingOrders(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, executed)
+}
+
+func TestService_ExecutePendingOrdersError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	ms.EXPECT().ExecutePendingOrders(ctx, 100, gomock.Any()).Return(nil, errors.New("db unavailable")).Times(1)
+
+	_, err := h.ExecutePendingOrders(ctx)
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, service.ErrExecutingOrders)
+}
+
+func TestService_CancelOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	ms.EXPECT().UpdateOrderStatus(ctx, 10000, uint(7), schema.Cancelled).Return(&storage.Order{OrderID: 7, CustomerID: 10000, Status: schema.Cancelled}, nil).Times(1)
+
+	order, err := h.CancelOrder(ctx, 10000, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, schema.Cancelled, order.Status)
+}
+
+func TestService_CancelOrderAlreadyExecuted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	ms.EXPECT().UpdateOrderStatus(ctx, 10000, uint(7), schema.Cancelled).Return(nil, storage.ErrInvalidOrderTransition).Times(1)
+
+	_, err := h.CancelOrder(ctx, 10000, 7)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, service.ErrOrderNotCancellable)
+	assert.ErrorContains(t, err, service.ErrCancellingOrder)
+}
+
+func TestService_CancelOrderNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	ms.EXPECT().UpdateOrderStatus(ctx, 10000, uint(7), schema.Cancelled).Return(nil, storage.ErrOrderNotFound).Times(1)
+
+	_, err := h.CancelOrder(ctx, 10000, 7)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, service.ErrOrderNotFound)
+}
+
+func TestService_PlaceBuyOrderIdempotentReplay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	key := "retry-123"
+
+	// The original order is replayed without repeating any of the checks
+	ms.EXPECT().GetOrderByIdempotencyKey(ctx, 10000, key).Return(&storage.Order{OrderID: 7, CustomerID: 10000, AmountGBP: 492, IdempotencyKey: &key, RequestHash: "abc"}, nil).Times(1)
+
+	order, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", AmountGBP: 492, IdempotencyKey: key, RequestHash: "abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), order.OrderID)
+	assert.True(t, order.Replayed)
+}
+
+func TestService_PlaceBuyOrderIdempotencyKeyReused(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	key := "retry-123"
+
+	ms.EXPECT().GetOrderByIdempotencyKey(ctx, 10000, key).Return(&storage.Order{OrderID: 7, IdempotencyKey: &key, RequestHash: "abc"}, nil).Times(1)
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", AmountGBP: 500, IdempotencyKey: key, RequestHash: "def"})
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, service.ErrIdempotencyKeyReused)
+}
+
+func TestService_PlaceBuyOrderIdempotencyKeyRace(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+	key := "retry-123"
+
+	// A concurrent retry inserts its order between our lookup and insert, so we replay that order
+	expectCustomerLock(ms, 10000)
+	gomock.InOrder(
+		ms.EXPECT().GetOrderByIdempotencyKey(ctx, 10000, key).Return(nil, storage.ErrOrderNotFound).Times(1),
+		ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(&storage.Fund{ID: 1, Code: "V3AM", AmountGBP: 4.92}, nil).Times(1),
+		ms.EXPECT().GetOrderByIdempotencyKey(ctx, 10000, key).Return(nil, storage.ErrOrderNotFound).Times(1),
+		ms.EXPECT().GetHeldFundCodes(ctx, 10000).Return(nil, nil).Times(1),
+		ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return(nil, nil).Times(1),
+		ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1),
+		ms.EXPECT().CreateOrder(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, o *schema.Orders) (*storage.Order, error) {
+			assert.Equal(t, key, *o.IdempotencyKey)
+			assert.Equal(t, "abc", o.RequestHash)
+			return nil, storage.ErrDuplicateIdempotencyKey
+		}).Times(1),
+		ms.EXPECT().GetOrderByIdempotencyKey(ctx, 10000, key).Return(&storage.Order{OrderID: 7, IdempotencyKey: &key, RequestHash: "abc"}, nil).Times(1),
+	)
+
+	order, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", AmountGBP: 492, IdempotencyKey: key, RequestHash: "abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), order.OrderID)
+	assert.True(t, order.Replayed)
+}
+
+func TestService_PlaceOrderIdempotentReplayUnderLock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+	key := "retry-123"
+	fund := &storage.Fund{ID: 1, Code: "V3AM", AmountGBP: 4.92}
+
+	// A retry that waited on the lock while the original was placed replays it, without the allowance or single product
+	// checks seeing the original's subscription
+	expectCustomerLock(ms, 10000)
+	gomock.InOrder(
+		ms.EXPECT().GetOrderByIdempotencyKey(ctx, 10000, key).Return(nil, storage.ErrOrderNotFound).Times(1),
+		ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(fund, nil).Times(1),
+		ms.EXPECT().GetOrderByIdempotencyKey(ctx, 10000, key).Return(&storage.Order{OrderID: 7, IdempotencyKey: &key, RequestHash: "abc"}, nil).Times(1),
+	)
+
+	order, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", AmountGBP: 20000, IdempotencyKey: key, RequestHash: "abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), order.OrderID)
+	assert.True(t, order.Replayed)
+
+	// Sells are replayed in the same way rather than checked against the holding the original sold from
+	expectCustomerLock(ms, 10000)
+	gomock.InOrder(
+		ms.EXPECT().GetOrderByIdempotencyKey(ctx, 10000, key).Return(nil, storage.ErrOrderNotFound).Times(1),
+		ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(fund, nil).Times(1),
+		ms.EXPECT().GetOrderByIdempotencyKey(ctx, 10000, key).Return(&storage.Order{OrderID: 8, IdempotencyKey: &key, RequestHash: "def"}, nil).Times(1),
+	)
+
+	order, err = h.PlaceSellOrder(ctx, service.PlaceSellOrderRequest{CustomerID: 10000, Code: "V3AM", Shares: 100, IdempotencyKey: key, RequestHash: "def"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(8), order.OrderID)
+	assert.True(t, order.Replayed)
+}
+
+func TestService_PlaceBuyOrderSingleProductRule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	ms.EXPECT().GetFund(ctx, "V3AB", "retail").Return(&storage.Fund{ID: 2, Code: "V3AB", AmountGBP: 4.92}, nil).Times(1)
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetHeldFundCodes(ctx, 10000).Return([]string{"V3AM"}, nil).Times(1)
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AB", AmountGBP: 100})
+	assert.Error(t, err)
+
+	var multipleProductsErr *service.MultipleProductsError
+	assert.ErrorAs(t, err, &multipleProductsErr)
+	assert.Equal(t, "V3AM", multipleProductsErr.HeldCode)
+	assert.Equal(t, "V3AB", multipleProductsErr.RequestedCode)
+}
+
+func TestService_PlaceBuyOrderMultipleProductsAllowed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms, service.WithMultipleProducts(true))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	// With the rule lifted the customer's existing holdings aren't checked
+	ms.EXPECT().GetFund(ctx, "V3AB", "retail").Return(&storage.Fund{ID: 2, Code: "V3AB", AmountGBP: 4.92}, nil).Times(1)
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().CreateOrder(ctx, gomock.Any()).Return(&storage.Order{OrderID: 9, Code: "V3AB"}, nil).Times(1)
+
+	order, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AB", AmountGBP: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, "V3AB", order.Code)
+}
+
+func TestService_GetAllowanceHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	soldAt := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	ms.EXPECT().GetOrders(ctx, 10000).Return([]storage.Order{
+		{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 15000, Status: schema.Executed, PurchaseTime: time.Date(2023, 4, 5, 12, 0, 0, 0, time.UTC)},
+		{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 8000, Status: schema.Executed, PurchaseTime: time.Date(2023, 4, 6, 12, 0, 0, 0, time.UTC)},
+		{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 5000, Status: schema.Cancelled, PurchaseTime: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{OrderType: schema.Sell, ISAType: schema.StocksAndShares, AmountGBP: 2500, Status: schema.Executed, PurchaseTime: time.Date(2024, 1, 9, 12, 0, 0, 0, time.UTC), ExecutionTime: &soldAt},
+		// Replaces the withdrawal before using any allowance
+		{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 3000, Status: schema.Executed, PurchaseTime: time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)},
+		// Junior ISAs don't share the adult allowance
+		{OrderType: schema.Buy, ISAType: schema.Junior, AmountGBP: 4000, Status: schema.Executed, PurchaseTime: time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)},
+		{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 1000, Status: schema.Pending, PurchaseTime: time.Date(2025, 4, 30, 12, 0, 0, 0, time.UTC)},
+	}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return([]storage.Transfer{
+		{Direction: schema.TransferIn, ISAType: schema.Cash, CurrentYearAmountGBP: 2000, PreviousYearsAmountGBP: 9000, Status: schema.TransferCompleted, RequestedAt: time.Date(2025, 4, 10, 12, 0, 0, 0, time.UTC)},
+		// Transfers out and rejected transfers don't affect the allowance
+		{Direction: schema.TransferOut, ISAType: schema.Cash, CurrentYearAmountGBP: 500, Status: schema.TransferCompleted, RequestedAt: time.Date(2025, 4, 10, 12, 0, 0, 0, time.UTC)},
+		{Direction: schema.TransferIn, ISAType: schema.Cash, CurrentYearAmountGBP: 500, Status: schema.TransferRejected, RequestedAt: time.Date(2025, 4, 10, 12, 0, 0, 0, time.UTC)},
+	}, nil).Times(1)
+
+	history, err := h.GetAllowanceHistory(ctx, 10000)
+	assert.NoError(t, err)
+
+	years := make([]string, len(history.TaxYears))
+	for i, y := range history.TaxYears {
+		years[i] = y.TaxYear
+	}
+	assert.Equal(t, []string{"2025/26", "2024/25", "2023/24", "2022/23"}, years)
+
+	assert.Equal(t, 1000.0, history.TaxYears[0].SubscriptionsGBP)
+	assert.Equal(t, 2000.0, history.TaxYears[0].TransferredInGBP)
+	assert.Equal(t, 17000.0, history.TaxYears[0].RemainingAllowanceGBP)
+	assert.Equal(t, 0.0, history.TaxYears[1].SubscriptionsGBP)
+	assert.Equal(t, 20000.0, history.TaxYears[1].RemainingAllowanceGBP)
+	assert.Equal(t, 11000.0, history.TaxYears[2].SubscriptionsGBP)
+	assert.Equal(t, 2500.0, history.TaxYears[2].WithdrawalsGBP)
+	assert.Equal(t, 2500.0, history.TaxYears[2].ReplacedGBP)
+	assert.Equal(t, 11500.0, history.TaxYears[2].RemainingAllowanceGBP)
+	assert.Equal(t, 15000.0, history.TaxYears[3].SubscriptionsGBP)
+}
+
+func TestService_GetAllowanceHistoryNoOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	ms.EXPECT().GetOrders(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+
+	history, err := h.GetAllowanceHistory(ctx, 10000)
+	assert.NoError(t, err)
+	assert.Len(t, history.TaxYears, 1)
+	assert.Equal(t, "2025/26", history.TaxYears[0].TaxYear)
+	assert.Equal(t, 20000.0, history.TaxYears[0].RemainingAllowanceGBP)
+}
+
+func TestService_GetAllowanceHistoryError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	ms.EXPECT().GetOrders(ctx, 10000).Return(nil, errors.New("db unavailable")).Times(1)
+
+	_, err := h.GetAllowanceHistory(ctx, 10000)
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, service.ErrGettingHistory)
+}
+
+func TestService_OpenLifetimeISA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	now := time.Date(2025, 5, 31, 12, 0, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	// The day before their 40th birthday
+	dateOfBirth := time.Date(1985, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	ms.EXPECT().GetCustomer(ctx, 10000).Return(&storage.Customer{CustomerID: 10000, CustomerType: schema.Retail, DateOfBirth: dateOfBirth, Residency: schema.UKResident, Status: schema.CustomerActive}, nil).Times(1)
+	ms.EXPECT().CreateLifetimeISA(ctx, &schema.LifetimeISAAccounts{CustomerID: 10000, OpenedAt: now}).
+		Return(&storage.LifetimeISA{CustomerID: 10000, OpenedAt: now}, nil).Times(1)
+
+	account, err := h.OpenLifetimeISA(ctx, 10000)
+	assert.NoError(t, err)
+	assert.Equal(t, &service.LifetimeISA{CustomerID: 10000, DateOfBirth: dateOfBirth, OpenedAt: now}, account)
+}
+
+func TestService_OpenLifetimeISAAgeIneligible(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	customer := func(dateOfBirth time.Time) *storage.Customer {
+		return &storage.Customer{CustomerID: 10000, CustomerType: schema.Retail, DateOfBirth: dateOfBirth, Residency: schema.UKResident, Status: schema.CustomerActive}
+	}
+
+	// Their 40th birthday
+	ms.EXPECT().GetCustomer(ctx, 10000).Return(customer(time.Date(1985, 6, 1, 0, 0, 0, 0, time.UTC)), nil).Times(1)
+	_, err := h.OpenLifetimeISA(ctx, 10000)
+	assert.ErrorIs(t, err, service.ErrLifetimeISAAgeIneligible)
+	assert.ErrorContains(t, err, service.ErrOpeningLifetimeISA)
+
+	// The day before their 18th birthday
+	ms.EXPECT().GetCustomer(ctx, 10000).Return(customer(time.Date(2007, 6, 2, 0, 0, 0, 0, time.UTC)), nil).Times(1)
+	_, err = h.OpenLifetimeISA(ctx, 10000)
+	assert.ErrorIs(t, err, service.ErrLifetimeISAAgeIneligible)
+}
+
+func TestService_OpenLifetimeISAAlreadyOpen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	ms.EXPECT().CreateLifetimeISA(ctx, gomock.Any()).Return(nil, storage.ErrLifetimeISAAlreadyOpen).Times(1)
+
+	_, err := h.OpenLifetimeISA(ctx, 10000)
+	assert.ErrorIs(t, err, service.ErrLifetimeISAAlreadyOpen)
+}
+
+func TestService_PlaceBuyOrderLifetimeISANotOpen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(nil, storage.ErrLifetimeISANotFound).Times(1)
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", ISAType: schema.Lifetime, AmountGBP: 100})
+	assert.ErrorIs(t, err, service.ErrLifetimeISANotOpen)
+}
+
+func TestService_PlaceBuyOrderLifetimeISAContributionAge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	// Half past midnight on 1 June in the UK, which is still 31 May in UTC
+	now := time.Date(2025, 5, 31, 23, 30, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	// Contributions stop on their 50th birthday, which starts at midnight UK time
+	expectCustomerBornOn(ms, 10000, time.Date(1975, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(&storage.LifetimeISA{CustomerID: 10000}, nil).Times(1)
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", ISAType: schema.Lifetime, AmountGBP: 100})
+	assert.ErrorIs(t, err, service.ErrLifetimeISAAgeIneligible)
+}
+
+func TestService_PlaceSellOrderLifetimeISAWithdrawalReason(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		dateOfBirth time.Time
+		requested   schema.WithdrawalReason
+		expected    schema.WithdrawalReason
+	}{
+		{name: "no reason is unauthorised", dateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), expected: schema.Unauthorised},
+		{name: "first home", dateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), requested: schema.FirstHome, expected: schema.FirstHome},
+		{name: "authorised from 60", dateOfBirth: time.Date(1965, 6, 1, 0, 0, 0, 0, time.UTC), expected: schema.AgedSixty},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			ms := mocks.NewMockStore(ctrl)
+			h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+
+			ctx := context.Background()
+			expectCustomerBornOn(ms, 10000, tt.dateOfBirth)
+
+			ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(&storage.LifetimeISA{CustomerID: 10000}, nil).Times(1)
+			ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(&storage.Fund{ID: 1, Code: "V3AM", AmountGBP: 5}, nil).Times(1)
+			expectCustomerLock(ms, 10000)
+			ms.EXPECT().GetAvailableShares(ctx, 10000, "V3AM", schema.Lifetime).Return(float64(50), nil).Times(1)
+			ms.EXPECT().CreateOrder(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, o *schema.Orders) (*storage.Order, error) {
+				assert.Equal(t, tt.expected, *o.WithdrawalReason)
+				return &storage.Order{OrderID: 8, ISAType: o.ISAType, WithdrawalReason: o.WithdrawalReason}, nil
+			}).Times(1)
+
+			order, err := h.PlaceSellOrder(ctx, service.PlaceSellOrderRequest{CustomerID: 10000, Code: "V3AM", ISAType: schema.Lifetime, Shares: 10, WithdrawalReason: tt.requested})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, *order.WithdrawalReason)
+		})
+	}
+}
+
+func TestService_PlaceSellOrderInvalidWithdrawalReason(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	// Withdrawal reasons only apply to lifetime ISAs
+	_, err := h.PlaceSellOrder(ctx, service.PlaceSellOrderRequest{CustomerID: 10000, Code: "V3AM", Shares: 10, WithdrawalReason: schema.FirstHome})
+	assert.ErrorIs(t, err, service.ErrInvalidWithdrawalReason)
+
+	// Customers can't claim to be 60 before they are
+	ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(&storage.LifetimeISA{CustomerID: 10000}, nil).Times(1)
+
+	_, err = h.PlaceSellOrder(ctx, service.PlaceSellOrderRequest{CustomerID: 10000, Code: "V3AM", ISAType: schema.Lifetime, Shares: 10, WithdrawalReason: schema.AgedSixty})
+	assert.ErrorIs(t, err, service.ErrInvalidWithdrawalReason)
+}
+
+func TestService_GetInvestmentOverviewLifetimeISA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+	openedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	executedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	ms.EXPECT().GetInvestmentOverview(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return([]storage.Order{{OrderType: schema.Buy, ISAType: schema.Lifetime, AmountGBP: 1000}}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(&storage.LifetimeISA{CustomerID: 10000, OpenedAt: openedAt}, nil).Times(1)
+	ms.EXPECT().GetLifetimeISALedger(ctx, 10000).Return([]storage.LedgerEntry{
+		{EntryID: 1, OrderID: 1, EntryType: schema.GovernmentBonus, AmountGBP: 250, CreatedAt: executedAt},
+		{EntryID: 2, OrderID: 2, EntryType: schema.WithdrawalCharge, AmountGBP: -25, CreatedAt: executedAt},
+	}, nil).Times(1)
+
+	overview, err := h.GetInvestmentOverview(ctx, 10000)
+	assert.NoError(t, err)
+	assert.Equal(t, &service.LifetimeISASummary{
+		OpenedAt:             openedAt,
+		BonusGBP:             250,
+		WithdrawalChargesGBP: 25,
+		Ledger: []service.LedgerEntry{
+			{OrderID: 1, EntryType: schema.GovernmentBonus, AmountGBP: 250, CreatedAt: executedAt},
+			{OrderID: 2, EntryType: schema.WithdrawalCharge, AmountGBP: -25, CreatedAt: executedAt},
+		},
+	}, overview.LifetimeISA)
+	assert.Equal(t, float64(3000), overview.Allowances[2].RemainingGBP)
+}
+
+func TestService_PlaceBuyOrderReplacesWithdrawal(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+	soldAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// The whole allowance has been used, but £5,000 of it was withdrawn afterwards so can be paid back in
+	taxYearOrders := []storage.Order{
+		{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 20000, PurchaseTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{OrderType: schema.Sell, ISAType: schema.StocksAndShares, AmountGBP: 5000, PurchaseTime: time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC), ExecutionTime: &soldAt},
+	}
+
+	ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(&storage.Fund{ID: 1, Code: "V3AM", AmountGBP: 5}, nil).Times(2)
+	expectCustomerLock(ms, 10000)
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetHeldFundCodes(ctx, 10000).Return([]string{"V3AM"}, nil).Times(2)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return(taxYearOrders, nil).Times(2)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(2)
+	ms.EXPECT().CreateOrder(ctx, gomock.Any()).Return(&storage.Order{OrderID: 7, AmountGBP: 5000}, nil).Times(1)
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", AmountGBP: 5000.01})
+	assert.ErrorIs(t, err, service.ErrISAAllowanceExceeded)
+
+	order, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", AmountGBP: 5000})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), order.OrderID)
+}
+
+func TestService_PlaceBuyOrderLifetimeISAWithdrawalNotReplaceable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+	soldAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	taxYearOrders := []storage.Order{
+		{OrderType: schema.Buy, ISAType: schema.Lifetime, AmountGBP: 4000, PurchaseTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{OrderType: schema.Sell, ISAType: schema.Lifetime, AmountGBP: 1000, PurchaseTime: time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC), ExecutionTime: &soldAt},
+	}
+
+	ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(&storage.LifetimeISA{CustomerID: 10000}, nil).Times(1)
+	ms.EXPECT().GetFund(ctx, "V3AM", "retail").Return(&storage.Fund{ID: 1, Code: "V3AM", AmountGBP: 5}, nil).Times(1)
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetHeldFundCodes(ctx, 10000).Return([]string{"V3AM"}, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return(taxYearOrders, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", ISAType: schema.Lifetime, AmountGBP: 1})
+	assert.ErrorIs(t, err, service.ErrISAAllowanceExceeded)
+}
+
+func TestService_GetInvestmentOverviewFlexibleAllowance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+	soldAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// A buy placed before the withdrawal executed can't replace it
+	ms.EXPECT().GetInvestmentOverview(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return([]storage.Order{
+		{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 10000, PurchaseTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 1000, PurchaseTime: time.Date(2024, 5, 31, 13, 0, 0, 0, time.UTC)},
+		{OrderType: schema.Sell, ISAType: schema.StocksAndShares, AmountGBP: 4000, PurchaseTime: time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC), ExecutionTime: &soldAt},
+		{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 3000, PurchaseTime: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)},
+	}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(nil, storage.ErrLifetimeISANotFound).Times(1)
+
+	overview, err := h.GetInvestmentOverview(ctx, 10000)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(9000), overview.IsaAllowanceCurrentTaxYear)
+	assert.Equal(t, service.ISAAllowance{
+		ISAType:       schema.StocksAndShares,
+		SubscribedGBP: 14000,
+		WithdrawnGBP:  4000,
+		ReplacedGBP:   3000,
+		RemainingGBP:  10000,
+	}, overview.Allowances[0])
+	// Withdrawals can only be replaced into the ISA they were taken from
+	assert.Equal(t, float64(9000), overview.Allowances[1].RemainingGBP)
+}
+
+func TestService_RequestTransfer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return([]storage.Order{{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 10000}}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().CreateTransfer(ctx, &schema.Transfers{
+		CustomerID:             10000,
+		Direction:              schema.TransferIn,
+		ISAType:                schema.StocksAndShares,
+		Method:                 schema.InSpecieTransfer,
+		Provider:               "Other Provider",
+		CurrentYearAmountGBP:   5000,
+		PreviousYearsAmountGBP: 30000,
+		Status:                 schema.TransferRequested,
+		RequestedAt:            now,
+		UpdatedAt:              now,
+	}).Return(&storage.Transfer{
+		TransferID:             1,
+		CustomerID:             10000,
+		Direction:              schema.TransferIn,
+		ISAType:                schema.StocksAndShares,
+		Method:                 schema.InSpecieTransfer,
+		Provider:               "Other Provider",
+		CurrentYearAmountGBP:   5000,
+		PreviousYearsAmountGBP: 30000,
+		Status:                 schema.TransferRequested,
+		RequestedAt:            now,
+		UpdatedAt:              now,
+	}, nil).Times(1)
+
+	transfer, err := h.RequestTransfer(ctx, service.RequestTransferRequest{
+		CustomerID:             10000,
+		Direction:              schema.TransferIn,
+		Method:                 schema.InSpecieTransfer,
+		Provider:               "Other Provider",
+		CurrentYearAmountGBP:   5000,
+		PreviousYearsAmountGBP: 30000,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), transfer.TransferID)
+	assert.Equal(t, schema.TransferRequested, transfer.Status)
+}
+
+func TestService_RequestTransferAllowanceExceeded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	// £8,000 has already been transferred in this year, a cancelled transfer and one from last year don't count
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return([]storage.Order{{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 10000}}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return([]storage.Transfer{
+		{Direction: schema.TransferIn, ISAType: schema.Cash, CurrentYearAmountGBP: 8000, Status: schema.TransferInProgress, RequestedAt: time.Date(2025, 4, 20, 12, 0, 0, 0, time.UTC)},
+		{Direction: schema.TransferIn, ISAType: schema.Cash, CurrentYearAmountGBP: 8000, Status: schema.TransferCancelled, RequestedAt: time.Date(2025, 4, 21, 12, 0, 0, 0, time.UTC)},
+		{Direction: schema.TransferIn, ISAType: schema.Cash, CurrentYearAmountGBP: 8000, Status: schema.TransferCompleted, RequestedAt: time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)},
+	}, nil).Times(1)
+
+	_, err := h.RequestTransfer(ctx, service.RequestTransferRequest{
+		CustomerID:           10000,
+		Direction:            schema.TransferIn,
+		Method:               schema.CashTransfer,
+		Provider:             "Other Provider",
+		CurrentYearAmountGBP: 2500,
+	})
+	assert.ErrorIs(t, err, service.ErrISAAllowanceExceeded)
+}
+
+func TestService_RequestTransferInvalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	valid := service.RequestTransferRequest{
+		CustomerID:           10000,
+		Direction:            schema.TransferOut,
+		Method:               schema.CashTransfer,
+		Provider:             "Other Provider",
+		CurrentYearAmountGBP: 100,
+	}
+
+	tests := map[string]func(r *service.RequestTransferRequest){
+		"direction":       func(r *service.RequestTransferRequest) { r.Direction = "sideways" },
+		"method":          func(r *service.RequestTransferRequest) { r.Method = "cheque" },
+		"provider":        func(r *service.RequestTransferRequest) { r.Provider = "" },
+		"zero amount":     func(r *service.RequestTransferRequest) { r.CurrentYearAmountGBP = 0 },
+		"negative amount": func(r *service.RequestTransferRequest) { r.PreviousYearsAmountGBP = -1 },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := valid
+			mutate(&req)
+			_, err := h.RequestTransfer(ctx, req)
+			assert.ErrorIs(t, err, service.ErrInvalidTransfer)
+		})
+	}
+
+	req := valid
+	req.ISAType = "pension"
+	_, err := h.RequestTransfer(ctx, req)
+	assert.ErrorIs(t, err, service.ErrInvalidISAType)
+}
+
+func TestService_RequestTransferLifetimeISANotOpen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	ms.EXPECT().GetLifetimeISA(ctx, 10000).Return(nil, storage.ErrLifetimeISANotFound).Times(1)
+
+	_, err := h.RequestTransfer(ctx, service.RequestTransferRequest{
+		CustomerID:           10000,
+		Direction:            schema.TransferIn,
+		ISAType:              schema.Lifetime,
+		Method:               schema.CashTransfer,
+		Provider:             "Other Provider",
+		CurrentYearAmountGBP: 1000,
+	})
+	assert.ErrorIs(t, err, service.ErrLifetimeISANotOpen)
+}
+
+func TestService_UpdateTransferStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	ms.EXPECT().UpdateTransferStatus(ctx, 10000, uint(1), schema.TransferCompleted, now).Return(&storage.Transfer{TransferID: 1, Status: schema.TransferCompleted, CompletedAt: &now}, nil).Times(1)
+
+	transfer, err := h.UpdateTransferStatus(ctx, 10000, 1, schema.TransferCompleted)
+	assert.NoError(t, err)
+	assert.Equal(t, schema.TransferCompleted, transfer.Status)
+	assert.Equal(t, &now, transfer.CompletedAt)
+}
+
+func TestService_UpdateTransferStatusErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	_, err := h.UpdateTransferStatus(ctx, 10000, 1, "lost")
+	assert.ErrorIs(t, err, service.ErrInvalidTransfer)
+
+	ms.EXPECT().UpdateTransferStatus(ctx, 10000, uint(2), schema.TransferCompleted, now).Return(nil, storage.ErrTransferNotFound).Times(1)
+	_, err = h.UpdateTransferStatus(ctx, 10000, 2, schema.TransferCompleted)
+	assert.ErrorIs(t, err, service.ErrTransferNotFound)
+
+	ms.EXPECT().UpdateTransferStatus(ctx, 10000, uint(3), schema.TransferCompleted, now).Return(nil, storage.ErrInvalidTransferTransition).Times(1)
+	_, err = h.UpdateTransferStatus(ctx, 10000, 3, schema.TransferCompleted)
+	assert.ErrorIs(t, err, service.ErrTransferStatusConflict)
+}
+
+func TestService_GetOrderHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+	bookCost, gain := 400.0, 100.0
+
+	ms.EXPECT().GetOrders(ctx, 10000).Return([]storage.Order{
+		{OrderID: 1, OrderType: schema.Buy, Code: "V3AM", AmountGBP: 800, Status: schema.Executed},
+		{OrderID: 2, OrderType: schema.Sell, Code: "V3AM", AmountGBP: 500, Status: schema.Executed, BookCostGBP: &bookCost, RealisedGainGBP: &gain},
+	}, nil).Times(1)
+
+	orders, err := h.GetOrderHistory(ctx, 10000)
+	assert.NoError(t, err)
+	assert.Equal(t, []service.Order{
+		{OrderID: 2, OrderType: schema.Sell, Code: "V3AM", AmountGBP: 500, Status: schema.Executed, BookCostGBP: &bookCost, RealisedGainGBP: &gain},
+		{OrderID: 1, OrderType: schema.Buy, Code: "V3AM", AmountGBP: 800, Status: schema.Executed},
+	}, orders)
+
+	ms.EXPECT().GetOrders(ctx, 10000).Return(nil, errors.New("db unavailable")).Times(1)
+
+	_, err = h.GetOrderHistory(ctx, 10000)
+	assert.ErrorContains(t, err, service.ErrGettingOrderHistory)
+}
+
+func TestService_GetPerformance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+	at := func(date string, hour int) time.Time {
+		d, _ := time.Parse("2006-01-02", date)
+		return d.Add(time.Duration(hour) * time.Hour)
+	}
+	boughtAt, soldAt := at("2025-03-03", 13), at("2025-03-05", 13)
+
+	orders := []storage.Order{
+		{OrderID: 1, OrderType: schema.Buy, Code: "V3AM", SharesPurchased: 100, AmountGBP: 400, Status: schema.Executed, ExecutionTime: &boughtAt},
+		{OrderID: 2, OrderType: schema.Sell, Code: "V3AM", SharesPurchased: 50, AmountGBP: 220, Status: schema.Executed, ExecutionTime: &soldAt},
+		{OrderID: 3, OrderType: schema.Buy, Code: "V3AM", AmountGBP: 1000, Status: schema.Pending},
+	}
+	prices := []storage.FundPrice{
+		{FundID: 1, Code: "V3AM", ValuationPoint: at("2025-03-03", 12), PriceGBP: 4},
+		{FundID: 1, Code: "V3AM", ValuationPoint: at("2025-03-04", 12), PriceGBP: 4.4},
+		{FundID: 1, Code: "V3AM", ValuationPoint: at("2025-03-05", 12), PriceGBP: 4.4},
+		{FundID: 1, Code: "V3AM", ValuationPoint: at("2025-03-06", 12), PriceGBP: 4.84},
+	}
+
+	// The benchmark rose 5% on the same two days
+	levels := []storage.BenchmarkLevel{
+		{FundCode: "V3AM", BenchmarkCode: "FTGAC", BenchmarkName: "FTSE Global All Cap Index", Date: at("2025-03-02", 0), Level: 1000},
+		{FundCode: "V3AM", BenchmarkCode: "FTGAC", BenchmarkName: "FTSE Global All Cap Index", Date: at("2025-03-03", 0), Level: 1000},
+		{FundCode: "V3AM", BenchmarkCode: "FTGAC", BenchmarkName: "FTSE Global All Cap Index", Date: at("2025-03-04", 0), Level: 1050},
+		{FundCode: "V3AM", BenchmarkCode: "FTGAC", BenchmarkName: "FTSE Global All Cap Index", Date: at("2025-03-06", 0), Level: 1102.5},
+	}
+
+	h := service.NewService(ms, service.WithClock(clock.Fixed(at("2025-03-06", 15))))
+	ms.EXPECT().GetOrders(ctx, 10000).Return(orders, nil).Times(1)
+	ms.EXPECT().GetFundPriceHistory(ctx, 10000).Return(prices, nil).Times(1)
+	ms.EXPECT().GetBenchmarkHistory(ctx, 10000).Return(levels, nil).Times(1)
+
+	perf, err := h.GetPerformance(ctx, 10000, performance.SinceInception)
+	assert.NoError(t, err)
+	assert.Equal(t, []performance.Point{
+		{Date: performance.Day(at("2025-03-03", 0)), ValueGBP: 400, InvestedGBP: 400},
+		{Date: performance.Day(at("2025-03-04", 0)), ValueGBP: 440},
+		{Date: performance.Day(at("2025-03-05", 0)), ValueGBP: 220, WithdrawnGBP: 220},
+		{Date: performance.Day(at("2025-03-06", 0)), ValueGBP: 242},
+	}, perf.Series)
+	assert.Equal(t, performance.Day(at("2025-03-02", 0)), perf.OpeningDate)
+	assert.Equal(t, 0.0, perf.OpeningValueGBP)
+	assert.Equal(t, 242.0, perf.ClosingValueGBP)
+	assert.Equal(t, 62.0, perf.GainGBP)
+	// The fund rose 10% on two days and the sale in between doesn't change that
+	assert.Equal(t, 21.0, perf.TimeWeightedReturnPercent)
+	// Less money was invested for the second rise, so the customer's own return is lower
+	assert.Equal(t, 18.74, *perf.MoneyWeightedReturnPercent)
+	assert.Equal(t, 10.25, *perf.BenchmarkReturnPercent)
+	assert.Equal(t, 10.75, *perf.RelativeReturnPercent)
+
+	fundReturn, benchmarkReturn, relativeReturn := 21.0, 10.25, 10.75
+	assert.Equal(t, []service.FundPerformance{{
+		Code:                   "V3AM",
+		ReturnPercent:          &fundReturn,
+		BenchmarkCode:          "FTGAC",
+		BenchmarkName:          "FTSE Global All Cap Index",
+		BenchmarkReturnPercent: &benchmarkReturn,
+		RelativeReturnPercent:  &relativeReturn,
+	}}, perf.Funds)
+
+	// A month later the period opens after the first rise
+	h = service.NewService(ms, service.WithClock(clock.Fixed(at("2025-04-04", 15))))
+	ms.EXPECT().GetOrders(ctx, 10000).Return(orders, nil).Times(1)
+	ms.EXPECT().GetFundPriceHistory(ctx, 10000).Return(prices, nil).Times(1)
+	ms.EXPECT().GetBenchmarkHistory(ctx, 10000).Return(nil, nil).Times(1)
+
+	perf, err = h.GetPerformance(ctx, 10000, performance.OneMonth)
+	assert.NoError(t, err)
+	assert.Equal(t, performance.Day(at("2025-03-04", 0)), perf.OpeningDate)
+	assert.Len(t, perf.Series, 31)
+	assert.Equal(t, 440.0, perf.OpeningValueGBP)
+	assert.Equal(t, 242.0, perf.ClosingValueGBP)
+	assert.Equal(t, 22.0, perf.GainGBP)
+	assert.Equal(t, 10.0, perf.TimeWeightedReturnPercent)
+	// Without benchmark levels there is nothing to compare with
+	assert.Nil(t, perf.BenchmarkReturnPercent)
+	assert.Nil(t, perf.Funds[0].BenchmarkReturnPercent)
+}
+
+func TestService_GetPerformanceNoOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	ms.EXPECT().GetOrders(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetFundPriceHistory(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetBenchmarkHistory(ctx, 10000).Return(nil, nil).Times(1)
+
+	perf, err := h.GetPerformance(ctx, 10000, performance.OneYear)
+	assert.NoError(t, err)
+	assert.Empty(t, perf.Series)
+	assert.Nil(t, perf.MoneyWeightedReturnPercent)
+
+	_, err = h.GetPerformance(ctx, 10000, performance.Period("5Y"))
+	assert.ErrorIs(t, err, service.ErrInvalidPeriod)
+
+	ms.EXPECT().GetOrders(ctx, 10000).Return(nil, errors.New("db unavailable")).Times(1)
+	_, err = h.GetPerformance(ctx, 10000, performance.OneYear)
+	assert.ErrorContains(t, err, service.ErrGettingPerformance)
+}
+
+func TestService_RegisterBenchmark(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+
+	ctx := context.Background()
+	date := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+
+	ms.EXPECT().RegisterBenchmark(ctx, &schema.Benchmarks{Code: "FTGAC", Name: "FTSE Global All Cap Index"}, []schema.BenchmarkLevels{
+		{Date: date, Level: 1000},
+		{Date: date.AddDate(0, 0, 1), Level: 1012},
+	}).Return(&storage.Benchmark{ID: 1, Code: "FTGAC", Name: "FTSE Global All Cap Index"}, nil).Times(1)
+
+	benchmark, err := h.RegisterBenchmark(ctx, service.RegisterBenchmarkRequest{
+		Code:   " FTGAC ",
+		Name:   "FTSE Global All Cap Index",
+		Levels: []service.BenchmarkLevel{{Date: date, Level: 1000}, {Date: date.AddDate(0, 0, 1), Level: 1012}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &service.Benchmark{Code: "FTGAC", Name: "FTSE Global All Cap Index"}, benchmark)
+
+	_, err = h.RegisterBenchmark(ctx, service.RegisterBenchmarkRequest{Code: "FTGAC"})
+	assert.ErrorIs(t, err, service.ErrInvalidBenchmark)
+
+	_, err = h.RegisterBenchmark(ctx, service.RegisterBenchmarkRequest{Code: "FTGAC", Name: "FTSE Global All Cap Index", Levels: []service.BenchmarkLevel{{Date: date, Level: 0}}})
+	assert.ErrorIs(t, err, service.ErrInvalidBenchmark)
+
+	_, err = h.RegisterBenchmark(ctx, service.RegisterBenchmarkRequest{Code: "FTGAC", Name: "FTSE Global All Cap Index", Levels: []service.BenchmarkLevel{{Date: date, Level: 1000}, {Date: date, Level: 1001}}})
+	assert.ErrorIs(t, err, service.ErrInvalidBenchmark)
+}
+
+func TestService_SetFundBenchmark(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+
+	ctx := context.Background()
+
+	ms.EXPECT().SetFundBenchmark(ctx, "V3AM", "FTGAC").Return(nil).Times(1)
+	err := h.SetFundBenchmark(ctx, "V3AM", "FTGAC")
+	assert.NoError(t, err)
+
+	ms.EXPECT().SetFundBenchmark(ctx, "XXXX", "FTGAC").Return(storage.ErrFundNotFound).Times(1)
+	err = h.SetFundBenchmark(ctx, "XXXX", "FTGAC")
+	assert.ErrorIs(t, err, service.ErrFundNotFound)
+
+	ms.EXPECT().SetFundBenchmark(ctx, "V3AM", "XXXX").Return(storage.ErrBenchmarkNotFound).Times(1)
+	err = h.SetFundBenchmark(ctx, "V3AM", "XXXX")
+	assert.ErrorIs(t, err, service.ErrBenchmarkNotFound)
+}
+
+func TestService_ImportPayroll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms, service.WithMultipleProducts(true), service.WithClock(clock.Fixed(time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC))))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	employerID := uint(1)
+	employee := func(customerID int) *storage.Customer {
+		return &storage.Customer{CustomerID: uint(customerID), CustomerType: schema.Workplace, EmployerID: &employerID, Residency: schema.UKResident, Status: schema.CustomerActive}
+	}
+	fund := &storage.Fund{ID: 3, Code: "V3AM", AmountGBP: 5}
+	file := `employee_reference,amount,pay_date
+EMP001,100,2025-03-28
+EMP002,100,2025-03-28
+EMP003,100,2025-03-28
+EMP004,100,2025-03-28
+EMP999,100,2025-03-28
+EMP001,abc,2025-03-28
+`
+
+	ms.EXPECT().GetEmployer(ctx, employerID).Return(&storage.Employer{ID: employerID, Name: "Acme Ltd"}, nil).Times(1)
+	ms.EXPECT().GetEmployees(ctx, employerID).Return([]storage.Customer{
+		{CustomerID: 2, EmployeeReference: "EMP001", DefaultFundCode: "V3AM"},
+		{CustomerID: 3, EmployeeReference: "EMP002", DefaultFundCode: "V3AM"},
+		{CustomerID: 4, EmployeeReference: "EMP003"},
+		{CustomerID: 5, EmployeeReference: "EMP004", DefaultFundCode: "V3AM"},
+	}, nil).Times(1)
+
+	// EMP001 is invested in their default fund
+	ms.EXPECT().GetOrderByIdempotencyKey(ctx, 2, "payroll:1:EMP001:2025-03-28").Return(nil, storage.ErrOrderNotFound).Times(2)
+	ms.EXPECT().GetCustomer(ctx, 2).Return(employee(2), nil).Times(1)
+	ms.EXPECT().GetEmployerFund(ctx, "V3AM", employerID).Return(fund, nil).Times(1)
+	expectCustomerLock(ms, 2)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 2).Return(nil, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 2).Return(nil, nil).Times(1)
+	ms.EXPECT().CreateOrder(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, o *schema.Orders) (*storage.Order, error) {
+		assert.Equal(t, uint(2), o.CustomerID)
+		assert.Equal(t, schema.StocksAndShares, o.ISAType)
+		assert.Equal(t, float64(100), o.PurchasedValueGBP)
+		assert.Equal(t, float64(20), o.Shares)
+		return &storage.Order{OrderID: 8, CustomerID: 2}, nil
+	}).Times(1)
+
+	// EMP002 has almost used their allowance, so the contribution is flagged rather than ordered
+	ms.EXPECT().GetOrderByIdempotencyKey(ctx, 3, "payroll:1:EMP002:2025-03-28").Return(nil, storage.ErrOrderNotFound).Times(2)
+	ms.EXPECT().GetCustomer(ctx, 3).Return(employee(3), nil).Times(1)
+	ms.EXPECT().GetEmployerFund(ctx, "V3AM", employerID).Return(fund, nil).Times(1)
+	expectCustomerLock(ms, 3)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 3).Return([]storage.Order{{OrderType: schema.Buy, ISAType: schema.StocksAndShares, AmountGBP: 19950}}, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 3).Return(nil, nil).Times(1)
+
+	// EMP004's contribution was ordered by an earlier import of the file
+	hash := sha256.Sum256([]byte("V3AM 100.00"))
+	ms.EXPECT().GetOrderByIdempotencyKey(ctx, 5, "payroll:1:EMP004:2025-03-28").Return(&storage.Order{OrderID: 6, CustomerID: 5, RequestHash: hex.EncodeToString(hash[:])}, nil).Times(1)
+
+	report, err := h.ImportPayroll(ctx, 1, strings.NewReader(file))
+	assert.NoError(t, err)
+	assert.Equal(t, &payroll.Report{
+		RowsRead: 6,
+		Ordered:  1,
+		Replayed: 1,
+		Flagged: []payroll.Flag{
+			{Line: 3, EmployeeReference: "EMP002", Amount: "100.00", PayDate: "2025-03-28", Reason: payroll.ReasonAllowanceExceeded},
+			{Line: 4, EmployeeReference: "EMP003", Amount: "100.00", PayDate: "2025-03-28", Reason: payroll.ReasonNoDefaultFund},
+			{Line: 6, EmployeeReference: "EMP999", Amount: "100.00", PayDate: "2025-03-28", Reason: payroll.ReasonUnknownEmployee},
+			{Line: 7, EmployeeReference: "EMP001", Amount: "abc", PayDate: "2025-03-28", Reason: payroll.ReasonInvalidAmount},
+		},
+	}, report)
+}
+
+func TestService_ImportPayrollOutsideTaxYear(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	// Imported on 7 April, after the tax year the March payroll was paid in has ended
+	h := service.NewService(ms, service.WithClock(clock.Fixed(time.Date(2025, 4, 7, 9, 0, 0, 0, time.UTC))))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	employerID := uint(1)
+	file := `EMP001,100,2025-03-28
+EMP001,100,2025-04-05
+EMP001,100,2025-04-06
+`
+
+	ms.EXPECT().GetEmployer(ctx, employerID).Return(&storage.Employer{ID: employerID, Name: "Acme Ltd"}, nil).Times(1)
+	ms.EXPECT().GetEmployees(ctx, employerID).Return([]storage.Customer{{CustomerID: 2, EmployeeReference: "EMP001", DefaultFundCode: "V3AM"}}, nil).Times(1)
+
+	// Only the contribution paid on the first day of the new tax year is ordered
+	ms.EXPECT().GetOrderByIdempotencyKey(ctx, 2, "payroll:1:EMP001:2025-04-06").Return(nil, storage.ErrOrderNotFound).Times(2)
+	ms.EXPECT().GetCustomer(ctx, 2).Return(&storage.Customer{CustomerID: 2, CustomerType: schema.Workplace, EmployerID: &employerID, Residency: schema.UKResident, Status: schema.CustomerActive}, nil).Times(1)
+	ms.EXPECT().GetEmployerFund(ctx, "V3AM", employerID).Return(&storage.Fund{ID: 3, Code: "V3AM", AmountGBP: 5}, nil).Times(1)
+	expectCustomerLock(ms, 2)
+	ms.EXPECT().GetHeldFundCodes(ctx, 2).Return(nil, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 2).Return(nil, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 2).Return(nil, nil).Times(1)
+	ms.EXPECT().CreateOrder(ctx, gomock.Any()).Return(&storage.Order{OrderID: 8, CustomerID: 2}, nil).Times(1)
+
+	report, err := h.ImportPayroll(ctx, 1, strings.NewReader(file))
+	assert.NoError(t, err)
+	assert.Equal(t, &payroll.Report{
+		RowsRead: 3,
+		Ordered:  1,
+		Flagged: []payroll.Flag{
+			{Line: 1, EmployeeReference: "EMP001", Amount: "100.00", PayDate: "2025-03-28", Reason: payroll.ReasonOutsideTaxYear},
+			{Line: 2, EmployeeReference: "EMP001", Amount: "100.00", PayDate: "2025-04-05", Reason: payroll.ReasonOutsideTaxYear},
+		},
+	}, report)
+}
+
+func TestService_ImportPayrollStoreError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC))))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	employerID := uint(1)
+	file := `EMP001,100,2025-03-28
+EMP002,100,2025-03-28
+EMP003,100,2025-03-28
+`
+
+	ms.EXPECT().GetEmployer(ctx, employerID).Return(&storage.Employer{ID: employerID, Name: "Acme Ltd"}, nil).Times(1)
+	ms.EXPECT().GetEmployees(ctx, employerID).Return([]storage.Customer{
+		{CustomerID: 2, EmployeeReference: "EMP001", DefaultFundCode: "V3AM"},
+		{CustomerID: 3, EmployeeReference: "EMP002", DefaultFundCode: "V3AM"},
+		{CustomerID: 4, EmployeeReference: "EMP003", DefaultFundCode: "V3AM"},
+	}, nil).Times(1)
+
+	// EMP001 is suspended, which is flagged with a fixed reason rather than the error's message
+	ms.EXPECT().GetOrderByIdempotencyKey(ctx, 2, "payroll:1:EMP001:2025-03-28").Return(nil, storage.ErrOrderNotFound).Times(1)
+	ms.EXPECT().GetCustomer(ctx, 2).Return(&storage.Customer{CustomerID: 2, CustomerType: schema.Workplace, EmployerID: &employerID, Residency: schema.UKResident, Status: schema.CustomerSuspended}, nil).Times(1)
+
+	// The store fails on EMP002, so EMP003 is never reached
+	ms.EXPECT().GetOrderByIdempotencyKey(ctx, 3, "payroll:1:EMP002:2025-03-28").Return(nil, errors.New("db unavailable")).Times(1)
+
+	report, err := h.ImportPayroll(ctx, 1, strings.NewReader(file))
+	assert.ErrorContains(t, err, service.ErrImportingPayroll)
+	assert.Equal(t, &payroll.Report{
+		RowsRead: 3,
+		Flagged: []payroll.Flag{
+			{Line: 1, EmployeeReference: "EMP001", Amount: "100.00", PayDate: "2025-03-28", Reason: payroll.ReasonNotEligible},
+		},
+		StoppedAtLine: 2,
+	}, report)
+}
+
+func TestService_ImportPayrollEmployerNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+
+	ms.EXPECT().GetEmployer(ctx, uint(404)).Return(nil, storage.ErrEmployerNotFound).Times(1)
+
+	_, err := h.ImportPayroll(ctx, 404, strings.NewReader("EMP001,100,2025-03-28\n"))
+	assert.ErrorIs(t, err, service.ErrEmployerNotFound)
+	assert.ErrorContains(t, err, service.ErrImportingPayroll)
+}
+
+func TestService_SubmitRiskQuestionnaire(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := service.NewService(ms, service.WithClock(clock.Fixed(now)))
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	answers := map[string]int{"horizon": 4, "fall": 3, "experience": 2, "reliance": 3, "goal": 3}
+
+	ms.EXPECT().SetRiskProfile(ctx, 10000, schema.Medium, now).Return(&storage.Customer{CustomerID: 10000, RiskProfile: schema.Medium, RiskProfiledAt: &now}, nil).Times(1)
+
+	profile, err := h.SubmitRiskQuestionnaire(ctx, 10000, answers)
+	assert.NoError(t, err)
+	assert.Equal(t, &service.RiskProfile{CustomerID: 10000, Profile: schema.Medium, ProfiledAt: now}, profile)
+}
+
+func TestService_SubmitRiskQuestionnaireInvalidAnswers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	expectCustomer(ms, 10000)
+
+	_, err := h.SubmitRiskQuestionnaire(ctx, 10000, map[string]int{"horizon": 4, "fall": 5, "experience": 2, "reliance": 3, "goal": 3})
+	assert.ErrorIs(t, err, service.ErrInvalidRiskAnswers)
+	assert.ErrorContains(t, err, service.ErrSubmittingRiskQuestionnaire)
+
+	ms.EXPECT().GetCustomer(ctx, 404).Return(nil, storage.ErrCustomerNotFound).Times(1)
+
+	_, err = h.SubmitRiskQuestionnaire(ctx, 404, map[string]int{"horizon": 4, "fall": 3, "experience": 2, "reliance": 3, "goal": 3})
+	assert.ErrorIs(t, err, service.ErrCustomerNotFound)
+}
+
+func TestService_PlaceBuyOrderFundUnsuitable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := mocks.NewMockStore(ctrl)
+	h := service.NewService(ms)
+	assert.NotNil(t, h)
+
+	ctx := context.Background()
+	fund := &storage.Fund{ID: 2, Code: "V3AB", Name: "Global Equity", AmountGBP: 5, RiskScore: schema.High}
+
+	ms.EXPECT().GetCustomer(ctx, 10000).Return(&storage.Customer{CustomerID: 10000, CustomerType: schema.Retail, Residency: schema.UKResident, Status: schema.CustomerActive, RiskProfile: schema.Low}, nil).AnyTimes()
+	ms.EXPECT().GetFund(ctx, "V3AB", "retail").Return(fund, nil).Times(2)
+
+	// A fund riskier than the customer's profile is refused until they acknowledge the risk
+	_, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AB", AmountGBP: 100})
+	assert.ErrorIs(t, err, service.ErrFundUnsuitable)
+	assert.ErrorContains(t, err, "V3AB is high risk and the customer's profile is low")
+
+	expectCustomerLock(ms, 10000)
+	ms.EXPECT().GetHeldFundCodes(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetCurrentTaxYearOrders(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().GetTransfers(ctx, 10000).Return(nil, nil).Times(1)
+	ms.EXPECT().CreateOrder(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, o *schema.Orders) (*storage.Order, error) {
+		assert.True(t, o.RiskOverride)
+		assert.Equal(t, schema.Low, *o.RiskProfile)
+		return &storage.Order{OrderID: 9, CustomerID: 10000, Code: "V3AB", OrderType: schema.Buy, RiskOverride: true}, nil
+	}).Times(1)
+
+	order, err := h.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AB", AmountGBP: 100, AcknowledgeRisk: true})
+	assert.NoError(t, err)
+	assert.True(t, order.RiskOverride)
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// RequestTransfer records a request to transfer an ISA to or from another provider. Subscriptions made in the current
+// tax year that are transferred in count towards the customer's allowance, so the request is refused if they don't
+// fit, whereas subscriptions from earlier years don't use any.
+func (s Service) RequestTransfer(ctx context.Context, req RequestTransferRequest) (*Transfer, error) {
+	isaType, err := validateTransfer(req)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrRequestingTransfer)
+	}
+
+	// Transferring in subscribes to an ISA with us, so only UK residents can, whereas any active customer can transfer out
+	if _, err := s.tradingCustomer(ctx, req.CustomerID, req.Direction == schema.TransferIn); err != nil {
+		return nil, errors.Wrap(err, ErrRequestingTransfer)
+	}
+
+	if req.Direction == schema.TransferIn && isaType == schema.Lifetime {
+		if _, err := s.lifetimeISA(ctx, req.CustomerID); err != nil {
+			return nil, errors.Wrap(err, ErrRequestingTransfer)
+		}
+	}
+
+	var transfer *Transfer
+	err = s.store.WithCustomerLock(ctx, req.CustomerID, func(ctx context.Context) error {
+		if req.Direction == schema.TransferIn && req.CurrentYearAmountGBP > 0 {
+			allowances, err := s.currentTaxYearAllowances(ctx, req.CustomerID)
+			if err != nil {
+				return errors.Wrap(err, ErrGettingISAAllowance)
+			}
+
+			if req.CurrentYearAmountGBP > allowances.remaining(isaType) {
+				return errors.Wrapf(ErrISAAllowanceExceeded, "%s ISA", isaType)
+			}
+		}
+
+		now := s.clock.Now()
+		storeTransfer, err := s.store.CreateTransfer(ctx, &schema.Transfers{
+			CustomerID:             uint(req.CustomerID),
+			Direction:              req.Direction,
+			ISAType:                isaType,
+			Method:                 req.Method,
+			Provider:               req.Provider,
+			CurrentYearAmountGBP:   req.CurrentYearAmountGBP,
+			PreviousYearsAmountGBP: req.PreviousYearsAmountGBP,
+			Status:                 schema.TransferRequested,
+			RequestedAt:            now,
+			UpdatedAt:              now,
+		})
+		if err != nil {
+			return err
+		}
+
+		transfer = toTransfer(storeTransfer)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, ErrRequestingTransfer)
+	}
+
+	return transfer, nil
+}
+
+// validateTransfer checks a transfer request and returns the ISA wrapper it is for.
+func validateTransfer(req RequestTransferRequest) (schema.ISAType, error) {
+	if req.Direction != schema.TransferIn && req.Direction != schema.TransferOut {
+		return "", errors.Wrapf(ErrInvalidTransfer, "%s is not a transfer direction", req.Direction)
+	}
+
+	if req.Method != schema.CashTransfer && req.Method != schema.InSpecieTransfer {
+		return "", errors.Wrapf(ErrInvalidTransfer, "%s is not a transfer method", req.Method)
+	}
+
+	if req.Provider == "" {
+		return "", errors.Wrap(ErrInvalidTransfer, "provider is required")
+	}
+
+	if req.CurrentYearAmountGBP < 0 || req.PreviousYearsAmountGBP < 0 || req.CurrentYearAmountGBP+req.PreviousYearsAmountGBP <= 0 {
+		return "", errors.Wrap(ErrInvalidTransfer, "amount must be greater than zero")
+	}
+
+	return orderISAType(req.ISAType)
+}
+
+func (s Service) GetTransfers(ctx context.Context, customerID int) ([]Transfer, error) {
+	if _, err := s.customer(ctx, customerID); err != nil {
+		return nil, errors.Wrap(err, ErrGettingTransfers)
+	}
+
+	storeTransfers, err := s.store.GetTransfers(ctx, customerID)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingTransfers)
+	}
+
+	transfers := make([]Transfer, len(storeTransfers))
+	for i := range storeTransfers {
+		transfers[i] = *toTransfer(&storeTransfers[i])
+	}
+
+	return transfers, nil
+}
+
+// UpdateTransferStatus moves one of the customer's transfers on through its lifecycle as the other provider responds.
+// A transfer in that is rejected or cancelled no longer uses any allowance.
+func (s Service) UpdateTransferStatus(ctx context.Context, customerID int, transferID uint, status schema.TransferStatus) (*Transfer, error) {
+	if !status.Valid() {
+		return nil, errors.Wrap(errors.Wrapf(ErrInvalidTransfer, "%s is not a transfer status", status), ErrUpdatingTransfer)
+	}
+
+	storeTransfer, err := s.store.UpdateTransferStatus(ctx, customerID, transferID, status, s.clock.Now())
+	if errors.Is(err, storage.ErrTransferNotFound) {
+		return nil, errors.Wrap(ErrTransferNotFound, ErrUpdatingTransfer)
+	}
+	if errors.Is(err, storage.ErrInvalidTransferTransition) {
+		return nil, errors.Wrap(errors.Wrapf(ErrTransferStatusConflict, "can't move to %s", status), ErrUpdatingTransfer)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrUpdatingTransfer)
+	}
+
+	return toTransfer(storeTransfer), nil
+}
+
+// transferCountsTowardsAllowance reports whether a transfer brings current year subscriptions in from another
+// provider. Transfers out don't give any allowance back, as it was used when the money was first subscribed.
+func transferCountsTowardsAllowance(t storage.Transfer) bool {
+	return t.Direction == schema.TransferIn && t.Status != schema.TransferRejected && t.Status != schema.TransferCancelled
+}
+
+// toTransfer maps the store's transfer model onto the one returned by the service.
+func toTransfer(t *storage.Transfer) *Transfer {
+	return &Transfer{
+		TransferID:             t.TransferID,
+		CustomerID:             t.CustomerID,
+		Direction:              t.Direction,
+		ISAType:                t.ISAType,
+		Method:                 t.Method,
+		Provider:               t.Provider,
+		CurrentYearAmountGBP:   t.CurrentYearAmountGBP,
+		PreviousYearsAmountGBP: t.PreviousYearsAmountGBP,
+		Status:                 t.Status,
+		RequestedAt:            t.RequestedAt,
+		UpdatedAt:              t.UpdatedAt,
+		CompletedAt:            t.CompletedAt,
+	}
+}
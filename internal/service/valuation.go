@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"github.com/jautyw/isa-investment-funds/internal/money"
+	"github.com/jautyw/isa-investment-funds/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// valueInvestment values a holding at the latest price of its fund. The unrealised gain is what the holding is worth
+// over its book cost, the average cost of the shares still held, so gains already taken on sales aren't counted again.
+func valueInvestment(sis storage.InvestmentOverview) InvestmentSummary {
+	is := InvestmentSummary{
+		Name:            sis.Name,
+		Description:     sis.Description,
+		Code:            sis.Code,
+		ISAType:         sis.ISAType,
+		NetShares:       sis.NetShares,
+		NetInvestment:   sis.NetInvestment,
+		BookCostGBP:     money.RoundPence(sis.BookCostGBP),
+		RealisedGainGBP: money.RoundPence(sis.RealisedGainGBP),
+		PriceGBP:        sis.PriceGBP,
+	}
+	if sis.NetShares > 0 {
+		is.AverageCostGBP = sis.BookCostGBP / sis.NetShares
+	}
+
+	// Without a price the holding can't be valued, reporting it as worth nothing would show a complete loss.
+	if sis.PriceGBP <= 0 {
+		return is
+	}
+
+	value := sis.NetShares * sis.PriceGBP
+	is.CurrentValueGBP = money.RoundPence(value)
+	is.UnrealisedGainGBP = money.RoundPence(value - sis.BookCostGBP)
+	if sis.BookCostGBP > 0 {
+		is.UnrealisedGainPercent = money.RoundPence((value - sis.BookCostGBP) / sis.BookCostGBP * 100)
+	}
+
+	return is
+}
+
+// GetOrderHistory returns all of the customer's orders, newest first. Executed sells carry their book cost and the
+// gain realised on them.
+func (s Service) GetOrderHistory(ctx context.Context, customerID int) ([]Order, error) {
+	if _, err := s.customer(ctx, customerID); err != nil {
+		return nil, errors.Wrap(err, ErrGettingOrderHistory)
+	}
+
+	storeOrders, err := s.store.GetOrders(ctx, customerID)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingOrderHistory)
+	}
+
+	orders := make([]Order, len(storeOrders))
+	for i := range storeOrders {
+		orders[len(storeOrders)-1-i] = *toOrder(&storeOrders[i])
+	}
+
+	return orders, nil
+}
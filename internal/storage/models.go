@@ -5,17 +5,100 @@ import (
 	"time"
 )
 
+type Customer struct {
+	CustomerID   uint                `gorm:"column:customer_id"`
+	CustomerType schema.CustomerType `gorm:"column:customer_type"`
+	EmployerID   *uint               `gorm:"column:employer_id"`
+	// EmployeeReference and DefaultFundCode are empty unless the customer is a workplace customer
+	EmployeeReference string `gorm:"column:employee_reference"`
+	DefaultFundCode   string `gorm:"column:default_fund_code"`
+	// RiskProfile is empty until the customer has completed the risk questionnaire
+	RiskProfile    schema.RiskScore      `gorm:"column:risk_profile"`
+	RiskProfiledAt *time.Time            `gorm:"column:risk_profiled_at"`
+	DateOfBirth    time.Time             `gorm:"column:date_of_birth"`
+	Residency      schema.Residency      `gorm:"column:residency"`
+	Status         schema.CustomerStatus `gorm:"column:status"`
+	CreatedAt      time.Time             `gorm:"column:created_at"`
+}
+
+type Employer struct {
+	ID   uint   `gorm:"column:id"`
+	Name string `gorm:"column:name"`
+}
+
+// Funds is a page of a fund listing. Total counts the funds matching the query's filters across every page, and Next
+// is where the next page starts, nil on the last page.
 type Funds struct {
 	Funds []Fund
+	Total int64
+	Next  *FundCursor
+}
+
+// FundSort is the field a fund listing is ordered by. Funds that tie are ordered by their ID, and a listing without a
+// sort is ordered by ID alone.
+type FundSort string
+
+const (
+	SortFundsByName  FundSort = "name"
+	SortFundsByCode  FundSort = "code"
+	SortFundsByPrice FundSort = "price"
+)
+
+// FundQuery filters, sorts and pages a fund listing. Search matches the fund's name or code, and the price range
+// includes its ends. A Limit of zero lists every fund after the cursor.
+type FundQuery struct {
+	RiskScore   schema.RiskScore
+	Search      string
+	MinPriceGBP *float64
+	MaxPriceGBP *float64
+	SortBy      FundSort
+	Descending  bool
+	After       *FundCursor
+	Limit       int
 }
 
+// FundCursor is the last fund of a page, the next page starts after it in the listing's order
+type FundCursor struct {
+	ID       uint
+	Name     string
+	Code     string
+	PriceGBP float64
+}
+
+// Fund is priced at its latest valuation point, AmountGBP is zero and LastUpdated unset until it has been priced.
+// AnnualChargePercent is only set on the funds of an employer's catalogue.
 type Fund struct {
-	Name        string           `gorm:"name"`
-	Description string           `gorm:"description"`
-	Code        string           `gorm:"code"`
-	AmountGBP   float64          `gorm:"amount_gbp"`
-	RiskScore   schema.RiskScore `gorm:"risk_score"`
-	LastUpdated time.Time        `gorm:"last_updated"`
+	ID                  uint             `gorm:"id"`
+	Name                string           `gorm:"name"`
+	Description         string           `gorm:"description"`
+	Code                string           `gorm:"code"`
+	AmountGBP           float64          `gorm:"amount_gbp"`
+	RiskScore           schema.RiskScore `gorm:"risk_score"`
+	LastUpdated         time.Time        `gorm:"last_updated"`
+	AnnualChargePercent *float64         `gorm:"column:annual_charge_percent"`
+}
+
+// FundPrice only carries the fund's Code when it comes from the price history
+type FundPrice struct {
+	FundID         uint      `gorm:"column:fund_id"`
+	Code           string    `gorm:"column:code"`
+	ValuationPoint time.Time `gorm:"column:valuation_point"`
+	PriceGBP       float64   `gorm:"column:price_gbp"`
+}
+
+type Benchmark struct {
+	ID   uint   `gorm:"column:id"`
+	Code string `gorm:"column:code"`
+	Name string `gorm:"column:name"`
+}
+
+// BenchmarkLevel is a level of the benchmark that the fund with FundCode is measured against
+type BenchmarkLevel struct {
+	FundCode      string    `gorm:"column:fund_code"`
+	BenchmarkCode string    `gorm:"column:benchmark_code"`
+	BenchmarkName string    `gorm:"column:benchmark_name"`
+	Date          time.Time `gorm:"column:date"`
+	Level         float64   `gorm:"column:level"`
 }
 
 type Overview struct {
@@ -23,18 +106,65 @@ type Overview struct {
 }
 
 type InvestmentOverview struct {
-	Name          string  `gorm:"column:name"`
-	Description   string  `gorm:"column:description"`
-	Code          string  `gorm:"column:code"`
-	NetShares     float64 `gorm:"column:net_shares"`
-	NetInvestment float64 `gorm:"column:net_investment"`
+	Name          string         `gorm:"column:name"`
+	Description   string         `gorm:"column:description"`
+	Code          string         `gorm:"column:code"`
+	ISAType       schema.ISAType `gorm:"column:isa_type"`
+	NetShares     float64        `gorm:"column:net_shares"`
+	NetInvestment float64        `gorm:"column:net_investment"`
+	// BookCostGBP is the average cost of the shares still held and RealisedGainGBP the total gain made on sales
+	BookCostGBP     float64 `gorm:"column:book_cost_gbp"`
+	RealisedGainGBP float64 `gorm:"column:realised_gain_gbp"`
+	// PriceGBP is the fund's latest price, zero if it has never been priced
+	PriceGBP float64 `gorm:"column:price_gbp"`
 }
 
 type Order struct {
-	OrderID         float64          `gorm:"order_id"`
-	Name            string           `gorm:"name"`
-	PurchaseTime    time.Time        `gorm:"purchase_time"`
-	OrderType       schema.OrderType `gorm:"orderType"`
-	SharesPurchased float64          `gorm:"shares_purchased"`
-	AmountGBP       float64          `gorm:"amount_gbp"`
+	OrderID           uint                     `gorm:"order_id"`
+	CustomerID        uint                     `gorm:"customer_id"`
+	Name              string                   `gorm:"name"`
+	Code              string                   `gorm:"code"`
+	PurchaseTime      time.Time                `gorm:"purchase_time"`
+	OrderType         schema.OrderType         `gorm:"orderType"`
+	SharesPurchased   float64                  `gorm:"shares_purchased"`
+	AmountGBP         float64                  `gorm:"amount_gbp"`
+	Status            schema.OrderStatus       `gorm:"status"`
+	ExecutionTime     *time.Time               `gorm:"execution_time"`
+	ExecutionPriceGBP *float64                 `gorm:"execution_price_gbp"`
+	IdempotencyKey    *string                  `gorm:"idempotency_key"`
+	RequestHash       string                   `gorm:"request_hash"`
+	ISAType           schema.ISAType           `gorm:"isa_type"`
+	WithdrawalReason  *schema.WithdrawalReason `gorm:"withdrawal_reason"`
+	BookCostGBP       *float64                 `gorm:"book_cost_gbp"`
+	RealisedGainGBP   *float64                 `gorm:"realised_gain_gbp"`
+	RiskOverride      bool                     `gorm:"risk_override"`
+	RiskProfile       *schema.RiskScore        `gorm:"risk_profile"`
+}
+
+type LifetimeISA struct {
+	CustomerID uint      `gorm:"column:customer_id"`
+	OpenedAt   time.Time `gorm:"column:opened_at"`
+}
+
+type LedgerEntry struct {
+	EntryID   uint                   `gorm:"column:entry_id"`
+	OrderID   uint                   `gorm:"column:order_id"`
+	EntryType schema.LedgerEntryType `gorm:"column:entry_type"`
+	AmountGBP float64                `gorm:"column:amount_gbp"`
+	CreatedAt time.Time              `gorm:"column:created_at"`
+}
+
+type Transfer struct {
+	TransferID             uint                     `gorm:"column:transfer_id"`
+	CustomerID             uint                     `gorm:"column:customer_id"`
+	Direction              schema.TransferDirection `gorm:"column:direction"`
+	ISAType                schema.ISAType           `gorm:"column:isa_type"`
+	Method                 schema.TransferMethod    `gorm:"column:method"`
+	Provider               string                   `gorm:"column:provider"`
+	CurrentYearAmountGBP   float64                  `gorm:"column:current_year_amount_gbp"`
+	PreviousYearsAmountGBP float64                  `gorm:"column:previous_years_amount_gbp"`
+	Status                 schema.TransferStatus    `gorm:"column:status"`
+	RequestedAt            time.Time                `gorm:"column:requested_at"`
+	UpdatedAt              time.Time                `gorm:"column:updated_at"`
+	CompletedAt            *time.Time               `gorm:"column:completed_at"`
 }
@@ -3,61 +3,565 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"github.com/jautyw/isa-investment-funds/internal/clock"
+	"github.com/jautyw/isa-investment-funds/internal/costbasis"
+	"github.com/jautyw/isa-investment-funds/internal/money"
 	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/taxyear"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
+	"strings"
 	"time"
 )
 
 type Store struct {
-	db *gorm.DB
+	db    *gorm.DB
+	clock clock.Clock
+}
+
+// Option configures optional behaviour of the Store
+type Option func(*Store)
+
+// WithClock replaces the system clock the Store uses to work out the current tax year
+func WithClock(c clock.Clock) Option {
+	return func(s *Store) {
+		s.clock = c
+	}
 }
 
 // NewStore will instantiate a new instance of the Store
-func NewStore(db *gorm.DB) *Store {
-	return &Store{
-		db: db,
+func NewStore(db *gorm.DB, opts ...Option) *Store {
+	s := &Store{
+		db:    db,
+		clock: clock.NewClock(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 const (
-	tableFunds  = "funds"
-	tableOrders = "orders"
+	tableCustomers  = "customers"
+	tableFunds      = "funds"
+	tableEmployers  = "employers"
+	tableFundPrices = "fund_prices"
+	tableOrders     = "orders"
+
+	tableLifetimeISAAccounts = "lifetime_isa_accounts"
+	tableLifetimeISALedger   = "lifetime_isa_ledgers"
+	tableTransfers           = "transfers"
+	tableBenchmarks          = "benchmarks"
+	tableBenchmarkLevels     = "benchmark_levels"
+
+	ErrGettingCustomer              = "error getting customer from db"
+	ErrCreatingCustomer             = "error creating customer in db"
+	ErrGettingEmployer              = "error getting employer from db"
+	ErrSettingRiskProfile           = "error setting customer risk profile in db"
+	ErrGettingEmployees             = "error getting employees from db"
+	ErrGettingFunds                 = "error getting funds from db"
+	ErrGettingInvestmentOverview    = "error getting investment overview from db"
+	ErrGettingCurrentTaxYearOrders  = "error getting current tax year orders from db"
+	ErrGettingFund                  = "error getting fund from db"
+	ErrCreatingOrder                = "error creating order in db"
+	ErrGettingAvailableShares       = "error getting available shares from db"
+	ErrUpdatingOrderStatus          = "error updating order status in db"
+	ErrExecutingPendingOrders       = "error executing pending orders in db"
+	ErrGettingOrderByIdempotencyKey = "error getting order by idempotency key from db"
+	ErrGettingHeldFundCodes         = "error getting held fund codes from db"
+	ErrLockingCustomer              = "error locking customer in db"
+	ErrGettingOrders                = "error getting orders from db"
+	ErrCreatingLifetimeISA          = "error creating lifetime ISA in db"
+	ErrGettingLifetimeISA           = "error getting lifetime ISA from db"
+	ErrGettingLifetimeISALedger     = "error getting lifetime ISA ledger from db"
+	ErrCreatingTransfer             = "error creating transfer in db"
+	ErrGettingTransfers             = "error getting transfers from db"
+	ErrUpdatingTransferStatus       = "error updating transfer status in db"
+	ErrGettingFundPrice             = "error getting fund price from db"
+	ErrGettingFundIDsByCode         = "error getting fund ids by code from db"
+	ErrUpsertingFundPrices          = "error upserting fund prices in db"
+	ErrGettingFundPriceHistory      = "error getting fund price history from db"
+	ErrRegisteringBenchmark         = "error registering benchmark in db"
+	ErrSettingFundBenchmark         = "error setting fund benchmark in db"
+	ErrGettingBenchmarkHistory      = "error getting benchmark history from db"
+
+	// latestFundPrice selects each fund along with its price at the latest valuation point, funds that have never
+	// been priced have a price of zero.
+	latestFundPrice     = `funds.*, COALESCE(latest.price_gbp, 0) AS amount_gbp, latest.valuation_point AS last_updated`
+	latestFundPriceJoin = `LEFT JOIN LATERAL (
+        SELECT price_gbp, valuation_point FROM fund_prices
+        WHERE fund_prices.fund_id = funds.id
+        ORDER BY valuation_point DESC LIMIT 1
+    ) latest ON true`
+	// employerFundJoin limits the funds to those the employer has approved, along with the charge it negotiated
+	employerFundJoin = `JOIN employer_funds ON employer_funds.fund_id = funds.id`
+	// latestFundPriceGBP is the fund's current price as selected by latestFundPrice, for filtering and sorting on
+	latestFundPriceGBP = `COALESCE(latest.price_gbp, 0)`
+
+	// customerLockNamespace keeps the advisory locks taken on customers apart from any other advisory locks
+	customerLockNamespace = 1
 
-	ErrGettingFunds                     = "error getting funds from db"
-	ErrGettingInvestmentOverview        = "error getting investment overview from db"
-	ErrGettingAmountSpentCurrentTaxYear = "error getting the amount spent in the current tax year"
+	// lifetimeISABonusRate is the government bonus paid on lifetime ISA subscriptions
+	lifetimeISABonusRate = 0.25
+	// lifetimeISAWithdrawalChargeRate is charged on the full amount of an unauthorised lifetime ISA withdrawal
+	lifetimeISAWithdrawalChargeRate = 0.25
 )
 
 var (
-	// lastYearApril6 refers to the day the new tax year begins
-	lastYearApril6 = time.Date(time.Now().Year()-1, 4, 6, 0, 0, 0, 0, time.UTC)
+	// ErrCustomerNotFound is returned when no customer matches the requested ID
+	ErrCustomerNotFound = errors.New("customer not found")
+	// ErrEmployerNotFound is returned when no employer matches the requested ID
+	ErrEmployerNotFound = errors.New("employer not found")
+	// ErrFundNotFound is returned when no fund matches the requested code
+	ErrFundNotFound = errors.New("fund not found")
+	// ErrOrderNotFound is returned when no order matches the requested ID
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrInvalidOrderTransition is returned when an order cannot move from its current status to the one requested
+	ErrInvalidOrderTransition = errors.New("invalid order status transition")
+	// ErrDuplicateIdempotencyKey is returned when the customer already has an order created with the same key
+	ErrDuplicateIdempotencyKey = errors.New("idempotency key already used")
+	// ErrLifetimeISANotFound is returned when the customer hasn't opened a lifetime ISA
+	ErrLifetimeISANotFound = errors.New("lifetime ISA not found")
+	// ErrLifetimeISAAlreadyOpen is returned when the customer already has a lifetime ISA
+	ErrLifetimeISAAlreadyOpen = errors.New("lifetime ISA already open")
+	// ErrFundPriceNotFound is returned when a fund wasn't priced at or before the requested time
+	ErrFundPriceNotFound = errors.New("fund price not found")
+	// ErrBenchmarkNotFound is returned when no benchmark matches the requested code
+	ErrBenchmarkNotFound = errors.New("benchmark not found")
+	// ErrTransferNotFound is returned when no transfer matches the requested ID
+	ErrTransferNotFound = errors.New("transfer not found")
+	// ErrInvalidTransferTransition is returned when a transfer cannot move from its current status to the one requested
+	ErrInvalidTransferTransition = errors.New("invalid transfer status transition")
+
+	// allowanceOrderStatuses are the statuses of orders that use up allowance. Pending buys are included as they
+	// already commit the customer's money, cancelled and rejected orders never do.
+	allowanceOrderStatuses = []schema.OrderStatus{schema.Pending, schema.Executed}
 )
 
-func (s *Store) GetFunds(ctx context.Context, customerType string) (*Funds, error) {
-	var funds []Fund
-	err := s.db.WithContext(ctx).Table(tableFunds).Where("customer_type = ?", customerType).Find(&funds).Error
+// txKey is the context key under which WithCustomerLock carries its transaction
+type txKey struct{}
+
+// conn returns the transaction carried by ctx if there is one, so that store calls made inside WithCustomerLock
+// join its transaction, otherwise the store's own connection.
+func (s *Store) conn(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return s.db.WithContext(ctx)
+}
+
+// WithCustomerLock runs fn in a transaction holding an advisory lock on the customer, so that concurrent callers for
+// the same customer are serialised until it commits. Store calls made with the ctx passed to fn run in the
+// transaction, and it is rolled back if fn returns an error.
+func (s *Store) WithCustomerLock(ctx context.Context, customerID int, fn func(ctx context.Context) error) error {
+	return s.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", customerLockNamespace, customerID).Error; err != nil {
+			return errors.Wrap(err, ErrLockingCustomer)
+		}
+
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+func (s *Store) GetCustomer(ctx context.Context, customerID int) (*Customer, error) {
+	var customer schema.Customers
+	err := s.conn(ctx).Table(tableCustomers).Where("customer_id = ?", customerID).Take(&customer).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(ErrCustomerNotFound, ErrGettingCustomer)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingCustomer)
+	}
+
+	return toCustomer(&customer), nil
+}
+
+func (s *Store) CreateCustomer(ctx context.Context, customer *schema.Customers) (*Customer, error) {
+	if err := s.conn(ctx).Table(tableCustomers).Create(customer).Error; err != nil {
+		return nil, errors.Wrap(err, ErrCreatingCustomer)
+	}
+
+	return toCustomer(customer), nil
+}
+
+func (s *Store) GetEmployer(ctx context.Context, employerID uint) (*Employer, error) {
+	var employer Employer
+	err := s.conn(ctx).Table(tableEmployers).Where("id = ?", employerID).Take(&employer).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(ErrEmployerNotFound, ErrGettingEmployer)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingEmployer)
+	}
+
+	return &employer, nil
+}
+
+// SetRiskProfile stores the profile scored from the customer's latest risk questionnaire, replacing any earlier one.
+func (s *Store) SetRiskProfile(ctx context.Context, customerID int, profile schema.RiskScore, at time.Time) (*Customer, error) {
+	result := s.conn(ctx).Table(tableCustomers).Where("customer_id = ?", customerID).
+		Updates(map[string]interface{}{"risk_profile": profile, "risk_profiled_at": at})
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, ErrSettingRiskProfile)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.Wrap(ErrCustomerNotFound, ErrSettingRiskProfile)
+	}
+
+	return s.GetCustomer(ctx, customerID)
+}
+
+// GetEmployees returns the workplace customers linked to the employer.
+func (s *Store) GetEmployees(ctx context.Context, employerID uint) ([]Customer, error) {
+	var customers []schema.Customers
+	err := s.conn(ctx).Table(tableCustomers).Where("employer_id = ?", employerID).Order("customer_id").Find(&customers).Error
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingEmployees)
+	}
+
+	employees := make([]Customer, len(customers))
+	for i := range customers {
+		employees[i] = *toCustomer(&customers[i])
+	}
+
+	return employees, nil
+}
+
+// GetFunds lists the page of funds offered to the customer type that the query asks for.
+func (s *Store) GetFunds(ctx context.Context, customerType string, query FundQuery) (*Funds, error) {
+	offered := s.conn(ctx).
+		Table(tableFunds).
+		Joins(latestFundPriceJoin).
+		Where("funds.customer_type = ?", customerType)
+
+	funds, err := listFunds(offered, latestFundPrice, query)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingFunds)
+	}
+
+	return funds, nil
+}
+
+func (s *Store) GetFund(ctx context.Context, code string, customerType string) (*Fund, error) {
+	var fund Fund
+	err := s.conn(ctx).
+		Table(tableFunds).
+		Select(latestFundPrice).
+		Joins(latestFundPriceJoin).
+		Where("funds.code = ? AND funds.customer_type = ?", code, customerType).
+		Take(&fund).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(ErrFundNotFound, ErrGettingFund)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingFund)
+	}
+
+	return &fund, nil
+}
+
+// GetEmployerFunds lists the page of workplace funds the employer has approved for its employees that the query asks
+// for.
+func (s *Store) GetEmployerFunds(ctx context.Context, employerID uint, query FundQuery) (*Funds, error) {
+	offered := s.conn(ctx).
+		Table(tableFunds).
+		Joins(employerFundJoin).
+		Joins(latestFundPriceJoin).
+		Where("employer_funds.employer_id = ? AND funds.customer_type = ?", employerID, schema.Workplace)
+
+	funds, err := listFunds(offered, latestFundPrice+", employer_funds.annual_charge_percent", query)
 	if err != nil {
 		return nil, errors.Wrap(err, ErrGettingFunds)
 	}
 
-	return &Funds{Funds: funds}, nil
+	return funds, nil
+}
+
+// listFunds filters the offered funds by the query, counting every fund that matches before selecting the page after
+// its cursor. Pages are found by seeking past the cursor's position in the sort order rather than by offset, so a
+// page isn't shifted by funds being added while a listing is paged through.
+func listFunds(offered *gorm.DB, selected string, query FundQuery) (*Funds, error) {
+	if query.RiskScore != "" {
+		offered = offered.Where("funds.risk_score = ?", query.RiskScore)
+	}
+	if query.Search != "" {
+		pattern := "%" + likeEscaper.Replace(query.Search) + "%"
+		offered = offered.Where("(funds.name ILIKE ? OR funds.code ILIKE ?)", pattern, pattern)
+	}
+	if query.MinPriceGBP != nil {
+		offered = offered.Where(latestFundPriceGBP+" >= ?", *query.MinPriceGBP)
+	}
+	if query.MaxPriceGBP != nil {
+		offered = offered.Where(latestFundPriceGBP+" <= ?", *query.MaxPriceGBP)
+	}
+	offered = offered.Session(&gorm.Session{})
+
+	var total int64
+	if err := offered.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	direction, seek := "ASC", ">"
+	if query.Descending {
+		direction, seek = "DESC", "<"
+	}
+
+	page := offered.Select(selected)
+	if column := fundSortColumn(query.SortBy); column != "" {
+		if query.After != nil {
+			page = page.Where("("+column+", funds.id) "+seek+" (?, ?)", fundSortValue(query.SortBy, query.After), query.After.ID)
+		}
+		page = page.Order(column + " " + direction)
+	} else if query.After != nil {
+		page = page.Where("funds.id "+seek+" ?", query.After.ID)
+	}
+	page = page.Order("funds.id " + direction)
+
+	// One fund more than the page holds is fetched to tell whether there is another page
+	if query.Limit > 0 {
+		page = page.Limit(query.Limit + 1)
+	}
+
+	var funds []Fund
+	if err := page.Scan(&funds).Error; err != nil {
+		return nil, err
+	}
+
+	listed := &Funds{Funds: funds, Total: total}
+	if query.Limit > 0 && len(funds) > query.Limit {
+		listed.Funds = funds[:query.Limit]
+		last := listed.Funds[query.Limit-1]
+		listed.Next = &FundCursor{ID: last.ID, Name: last.Name, Code: last.Code, PriceGBP: last.AmountGBP}
+	}
+
+	return listed, nil
+}
+
+// likeEscaper stops the wildcards of ILIKE in a search from matching anything but themselves
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// fundSortColumn returns the column funds are sorted on ahead of their ID, none when they're only sorted by ID.
+func fundSortColumn(sortBy FundSort) string {
+	switch sortBy {
+	case SortFundsByName:
+		return "funds.name"
+	case SortFundsByCode:
+		return "funds.code"
+	case SortFundsByPrice:
+		return latestFundPriceGBP
+	default:
+		return ""
+	}
+}
+
+// fundSortValue returns the cursor's value of the column funds are sorted on.
+func fundSortValue(sortBy FundSort, cursor *FundCursor) interface{} {
+	switch sortBy {
+	case SortFundsByName:
+		return cursor.Name
+	case SortFundsByCode:
+		return cursor.Code
+	default:
+		return cursor.PriceGBP
+	}
+}
+
+// GetEmployerFund returns the workplace fund with the code if the employer has approved it.
+func (s *Store) GetEmployerFund(ctx context.Context, code string, employerID uint) (*Fund, error) {
+	var fund Fund
+	err := s.conn(ctx).
+		Table(tableFunds).
+		Select(latestFundPrice+", employer_funds.annual_charge_percent").
+		Joins(employerFundJoin).
+		Joins(latestFundPriceJoin).
+		Where("funds.code = ? AND employer_funds.employer_id = ? AND funds.customer_type = ?", code, employerID, schema.Workplace).
+		Take(&fund).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(ErrFundNotFound, ErrGettingFund)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingFund)
+	}
+
+	return &fund, nil
+}
+
+// GetFundPriceAsOf returns the fund's price at the latest valuation point at or before the given time.
+func (s *Store) GetFundPriceAsOf(ctx context.Context, fundID uint, at time.Time) (*FundPrice, error) {
+	var price FundPrice
+	err := s.conn(ctx).
+		Table(tableFundPrices).
+		Where("fund_id = ? AND valuation_point <= ?", fundID, at).
+		Order("valuation_point DESC").
+		Take(&price).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(ErrFundPriceNotFound, ErrGettingFundPrice)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingFundPrice)
+	}
+
+	return &price, nil
+}
+
+// GetFundPriceHistory returns every price of the funds the customer has placed orders for, oldest first.
+func (s *Store) GetFundPriceHistory(ctx context.Context, customerID int) ([]FundPrice, error) {
+	var prices []FundPrice
+	err := s.conn(ctx).
+		Table(tableFundPrices).
+		Select("fund_prices.fund_id, funds.code, fund_prices.valuation_point, fund_prices.price_gbp").
+		Joins("JOIN funds ON funds.id = fund_prices.fund_id").
+		Where("fund_prices.fund_id IN (?)", s.conn(ctx).Table(tableOrders).Select("fund_id").Where("customer_id = ?", customerID)).
+		Order("fund_prices.valuation_point, fund_prices.fund_id").
+		Scan(&prices).Error
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingFundPriceHistory)
+	}
+
+	return prices, nil
+}
+
+// RegisterBenchmark creates the benchmark, or renames it if its code is already registered, and records its levels
+// replacing any already held for the same dates.
+func (s *Store) RegisterBenchmark(ctx context.Context, benchmark *schema.Benchmarks, levels []schema.BenchmarkLevels) (*Benchmark, error) {
+	err := s.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(tableBenchmarks).
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "code"}},
+				DoUpdates: clause.AssignmentColumns([]string{"name"}),
+			}).
+			Create(benchmark).Error
+		if err != nil {
+			return err
+		}
+
+		if len(levels) == 0 {
+			return nil
+		}
+		for i := range levels {
+			levels[i].BenchmarkID = benchmark.ID
+		}
+
+		return tx.Table(tableBenchmarkLevels).
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "benchmark_id"}, {Name: "date"}},
+				DoUpdates: clause.AssignmentColumns([]string{"level"}),
+			}).
+			Create(&levels).Error
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, ErrRegisteringBenchmark)
+	}
+
+	return &Benchmark{ID: benchmark.ID, Code: benchmark.Code, Name: benchmark.Name}, nil
+}
+
+// SetFundBenchmark measures every listing of the fund against the benchmark.
+func (s *Store) SetFundBenchmark(ctx context.Context, fundCode string, benchmarkCode string) error {
+	var benchmark schema.Benchmarks
+	err := s.conn(ctx).Table(tableBenchmarks).Where("code = ?", benchmarkCode).Take(&benchmark).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.Wrap(ErrBenchmarkNotFound, ErrSettingFundBenchmark)
+	}
+	if err != nil {
+		return errors.Wrap(err, ErrSettingFundBenchmark)
+	}
+
+	result := s.conn(ctx).Table(tableFunds).Where("code = ?", fundCode).Update("benchmark_id", benchmark.ID)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, ErrSettingFundBenchmark)
+	}
+	if result.RowsAffected == 0 {
+		return errors.Wrap(ErrFundNotFound, ErrSettingFundBenchmark)
+	}
+
+	return nil
+}
+
+// GetBenchmarkHistory returns every level of the benchmarks of the funds the customer has placed orders for, oldest
+// first and against the code of each fund measured against them.
+func (s *Store) GetBenchmarkHistory(ctx context.Context, customerID int) ([]BenchmarkLevel, error) {
+	var levels []BenchmarkLevel
+	err := s.conn(ctx).
+		Table(tableBenchmarkLevels).
+		Distinct("funds.code AS fund_code, benchmarks.code AS benchmark_code, benchmarks.name AS benchmark_name, benchmark_levels.date, benchmark_levels.level").
+		Joins("JOIN benchmarks ON benchmarks.id = benchmark_levels.benchmark_id").
+		Joins("JOIN funds ON funds.benchmark_id = benchmarks.id").
+		Where("funds.id IN (?)", s.conn(ctx).Table(tableOrders).Select("fund_id").Where("customer_id = ?", customerID)).
+		Order("benchmark_levels.date, fund_code").
+		Scan(&levels).Error
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingBenchmarkHistory)
+	}
+
+	return levels, nil
+}
+
+// GetFundIDsByCode maps each fund code onto the funds that share it, as a fund is listed once for each customer type
+// it is offered to.
+func (s *Store) GetFundIDsByCode(ctx context.Context) (map[string][]uint, error) {
+	var funds []schema.Funds
+	err := s.conn(ctx).Table(tableFunds).Select("id, code").Order("id").Find(&funds).Error
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingFundIDsByCode)
+	}
+
+	ids := map[string][]uint{}
+	for _, f := range funds {
+		ids[f.Code] = append(ids[f.Code], f.ID)
+	}
+
+	return ids, nil
+}
+
+// UpsertFundPrices records the prices, replacing any already held for the same fund and valuation point so that
+// corrected prices can be loaded again.
+func (s *Store) UpsertFundPrices(ctx context.Context, prices []schema.FundPrices) error {
+	if len(prices) == 0 {
+		return nil
+	}
+
+	err := s.conn(ctx).
+		Table(tableFundPrices).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "fund_id"}, {Name: "valuation_point"}},
+			DoUpdates: clause.AssignmentColumns([]string{"price_gbp"}),
+		}).
+		Create(&prices).Error
+	if err != nil {
+		return errors.Wrap(err, ErrUpsertingFundPrices)
+	}
+
+	return nil
 }
 
 func (s *Store) GetInvestmentOverview(ctx context.Context, customerID int) ([]InvestmentOverview, error) {
 	var investmentOverview []InvestmentOverview
 
-	err := s.db.WithContext(ctx).
+	err := s.conn(ctx).
 		Table(tableOrders).
 		Select(`
         name, 
         description, 
         code, 
+        isa_type, 
         SUM(CASE WHEN order_type = 'buy' THEN total_shares ELSE -total_shares END) AS net_shares,
-        SUM(CASE WHEN order_type = 'buy' THEN purchased_value_gbp ELSE -purchased_value_gbp END) AS net_investment
+        SUM(CASE WHEN order_type = 'buy' THEN purchased_value_gbp ELSE -purchased_value_gbp END) AS net_investment,
+        SUM(CASE WHEN order_type = 'buy' THEN purchased_value_gbp ELSE -COALESCE(book_cost_gbp, purchased_value_gbp) END) AS book_cost_gbp,
+        COALESCE(SUM(realised_gain_gbp), 0) AS realised_gain_gbp,
+        COALESCE(latest.price_gbp, 0) AS price_gbp
     `).
+		// Each holding is valued at the latest price of its fund
+		Joins(`LEFT JOIN LATERAL (
+            SELECT price_gbp FROM fund_prices
+            WHERE fund_prices.fund_id = orders.fund_id
+            ORDER BY valuation_point DESC LIMIT 1
+        ) latest ON true`).
 		Where("customer_id = ?", customerID).
-		Group("name, description, code").
+		Where("status = ?", schema.Executed). // Only filled orders make up a holding
+		Group("name, description, code, isa_type, latest.price_gbp").
 		Having("SUM(CASE WHEN order_type = 'buy' THEN total_shares ELSE -total_shares END) > 0"). // Ensures only investments with positive net shares are included
 		Scan(&investmentOverview).Error
 	if err != nil {
@@ -67,15 +571,510 @@ func (s *Store) GetInvestmentOverview(ctx context.Context, customerID int) ([]In
 	return investmentOverview, nil
 }
 
-func (s *Store) GetAmountSpentCurrentTaxYear(ctx context.Context, customerID int) (float64, error) {
-	var allowance sql.NullFloat64
-	err := s.db.WithContext(ctx).Table(tableOrders).Select("SUM(purchased_value_gbp)").Where("customer_id = ?", customerID).Where("order_type = ?", schema.Buy).Where("order_time BETWEEN ? AND ?", lastYearApril6, time.Now()).Find(&allowance).Error
+// GetCurrentTaxYearOrders returns the orders that count towards the customer's allowance in the current tax year,
+// oldest first. These are the buys placed this year, and the sells executed this year as flexible ISAs let withdrawals
+// be replaced in the year they are made.
+func (s *Store) GetCurrentTaxYearOrders(ctx context.Context, customerID int) ([]Order, error) {
+	year := taxyear.For(s.clock.Now())
+
+	var rows []schema.Orders
+	err := s.conn(ctx).
+		Table(tableOrders).
+		Where("customer_id = ?", customerID).
+		Where(`((order_type = ? AND status IN ? AND order_time >= ? AND order_time < ?)
+        OR (order_type = ? AND status = ? AND execution_time >= ? AND execution_time < ?))`,
+			schema.Buy, allowanceOrderStatuses, year.Start, year.End,
+			schema.Sell, schema.Executed, year.Start, year.End).
+		Order("order_time, order_id").
+		Find(&rows).Error
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingCurrentTaxYearOrders)
+	}
+
+	orders := make([]Order, len(rows))
+	for i := range rows {
+		orders[i] = *toOrder(&rows[i])
+	}
+
+	return orders, nil
+}
+
+func (s *Store) CreateOrder(ctx context.Context, order *schema.Orders) (*Order, error) {
+	// A conflict can only come from the idempotency key as order IDs are generated, so a row that wasn't inserted
+	// means a concurrent request got there first with the same key.
+	result := s.conn(ctx).Table(tableOrders).Clauses(clause.OnConflict{DoNothing: true}).Create(order)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, ErrCreatingOrder)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.Wrap(ErrDuplicateIdempotencyKey, ErrCreatingOrder)
+	}
+
+	return toOrder(order), nil
+}
+
+func (s *Store) GetOrderByIdempotencyKey(ctx context.Context, customerID int, key string) (*Order, error) {
+	var order schema.Orders
+	err := s.conn(ctx).Table(tableOrders).Where("customer_id = ? AND idempotency_key = ?", customerID, key).Take(&order).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(ErrOrderNotFound, ErrGettingOrderByIdempotencyKey)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingOrderByIdempotencyKey)
+	}
+
+	return toOrder(&order), nil
+}
+
+// GetOrders returns every order the customer has placed, oldest first.
+func (s *Store) GetOrders(ctx context.Context, customerID int) ([]Order, error) {
+	var rows []schema.Orders
+	err := s.conn(ctx).Table(tableOrders).Where("customer_id = ?", customerID).Order("order_time, order_id").Find(&rows).Error
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingOrders)
+	}
+
+	orders := make([]Order, len(rows))
+	for i := range rows {
+		orders[i] = *toOrder(&rows[i])
+	}
+
+	return orders, nil
+}
+
+// GetAvailableShares returns the number of shares in a fund the customer can still sell from an ISA wrapper. This is
+// the executed holding less any shares already queued for sale, which are still held until executed but can't be
+// sold a second time. Both are read in a single query so that they can't be taken from different points in time.
+func (s *Store) GetAvailableShares(ctx context.Context, customerID int, code string, isaType schema.ISAType) (float64, error) {
+	var shares sql.NullFloat64
+	err := s.conn(ctx).
+		Table(tableOrders).
+		Select(`SUM(CASE WHEN order_type = 'buy' AND status = 'executed' THEN total_shares
+            WHEN order_type = 'sell' THEN -total_shares ELSE 0 END)`).
+		Where("customer_id = ? AND code = ? AND isa_type = ?", customerID, code, isaType).
+		Where("status IN ?", []schema.OrderStatus{schema.Pending, schema.Executed}).
+		Find(&shares).Error
+	if err != nil {
+		return 0, errors.Wrap(err, ErrGettingAvailableShares)
+	}
+
+	if !shares.Valid {
+		shares.Float64 = 0
+	}
+	return shares.Float64, nil
+}
+
+// GetHeldFundCodes returns the codes of every fund the customer either holds shares in or has a pending buy for.
+func (s *Store) GetHeldFundCodes(ctx context.Context, customerID int) ([]string, error) {
+	var codes []string
+	err := s.conn(ctx).
+		Table(tableOrders).
+		Select("code").
+		Where("customer_id = ?", customerID).
+		Where("status IN ?", []schema.OrderStatus{schema.Pending, schema.Executed}).
+		Group("code").
+		Having(`SUM(CASE WHEN status = 'executed' AND order_type = 'buy' THEN total_shares WHEN status = 'executed' THEN -total_shares ELSE 0 END) > 0
+        OR SUM(CASE WHEN status = 'pending' AND order_type = 'buy' THEN 1 ELSE 0 END) > 0`).
+		Order("code").
+		Pluck("code", &codes).Error
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingHeldFundCodes)
+	}
+
+	return codes, nil
+}
+
+// UpdateOrderStatus moves a customer's order to the next status, refusing any transition the order lifecycle doesn't
+// allow. Orders belonging to another customer are reported as not found.
+func (s *Store) UpdateOrderStatus(ctx context.Context, customerID int, orderID uint, next schema.OrderStatus) (*Order, error) {
+	var order schema.Orders
+	err := s.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(tableOrders).Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ? AND customer_id = ?", orderID, customerID).Take(&order).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrOrderNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if !order.Status.CanTransitionTo(next) {
+			return errors.Wrapf(ErrInvalidOrderTransition, "%s to %s", order.Status, next)
+		}
+
+		order.Status = next
+		return tx.Table(tableOrders).Where("order_id = ?", orderID).Update("status", next).Error
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, ErrUpdatingOrderStatus)
+	}
+
+	return toOrder(&order), nil
+}
+
+// pendingOrder is a queued order along with the fund price it will be executed at.
+type pendingOrder struct {
+	schema.Orders
+	PriceGBP float64 `gorm:"column:price_gbp"`
+}
+
+// ExecutePendingOrders settles up to limit pending orders. Orders are forward priced, so each is executed at the
+// first valuation point of its fund at or after the order was placed and is only picked up once that exists. Rows are
+// claimed with FOR UPDATE SKIP LOCKED so that several replicas can run this concurrently without executing an order
+// twice. The customers of the claimed orders are then locked as WithCustomerLock does, so that no order is placed
+// against a holding while it is being settled, and sells are checked against the holding again before executing.
+func (s *Store) ExecutePendingOrders(ctx context.Context, limit int, executedAt time.Time) ([]Order, error) {
+	var settled []Order
+	err := s.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		var pending []pendingOrder
+		err := tx.Table(tableOrders).
+			Select("orders.*, next.price_gbp AS price_gbp").
+			Joins(`JOIN LATERAL (
+                SELECT price_gbp FROM fund_prices
+                WHERE fund_prices.fund_id = orders.fund_id AND fund_prices.valuation_point >= orders.order_time
+                ORDER BY valuation_point LIMIT 1
+            ) next ON true`).
+			Where("orders.status = ?", schema.Pending).
+			Order("orders.order_time, orders.order_id").
+			Limit(limit).
+			Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: tableOrders}, Options: "SKIP LOCKED"}).
+			Scan(&pending).Error
+		if err != nil {
+			return err
+		}
+
+		if err := lockCustomers(tx, pending); err != nil {
+			return err
+		}
+
+		for _, p := range pending {
+			order := p.Orders
+			price := p.PriceGBP
+
+			// A fund without a usable price can't be traded, so the order is rejected rather than left in the queue.
+			next := schema.Executed
+			if price <= 0 {
+				next = schema.Rejected
+			}
+
+			// A sell that would take the holding below zero is rejected, whatever was checked when it was placed.
+			if next == schema.Executed && order.OrderType == schema.Sell {
+				held, err := heldShares(tx, &order)
+				if err != nil {
+					return err
+				}
+				if order.Shares > held {
+					next = schema.Rejected
+				}
+			}
+			if !order.Status.CanTransitionTo(next) {
+				return errors.Wrapf(ErrInvalidOrderTransition, "%s to %s", order.Status, next)
+			}
+
+			updates := map[string]interface{}{"status": next}
+			if next == schema.Executed {
+				// Buys are placed for a cash amount and sells for a number of shares, the other side is set by the price.
+				if order.OrderType == schema.Buy {
+					order.Shares = order.PurchasedValueGBP / price
+				} else {
+					order.PurchasedValueGBP = order.Shares * price
+				}
+				order.ExecutionTime = &executedAt
+				order.ExecutionPriceGBP = &price
+
+				updates["total_shares"] = order.Shares
+				updates["purchased_value_gbp"] = order.PurchasedValueGBP
+				updates["execution_time"] = executedAt
+				updates["execution_price_gbp"] = price
+			}
+			order.Status = next
+
+			if err := tx.Table(tableOrders).Where("order_id = ?", order.OrderID).Updates(updates).Error; err != nil {
+				return err
+			}
+
+			if next == schema.Executed {
+				if order.OrderType == schema.Sell {
+					if err := recordRealisedGain(tx, &order); err != nil {
+						return err
+					}
+				}
+
+				if err := recordLifetimeISALedger(tx, &order, executedAt); err != nil {
+					return err
+				}
+			}
+
+			settled = append(settled, *toOrder(&order))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, ErrExecutingPendingOrders)
+	}
+
+	return settled, nil
+}
+
+// lockCustomers takes the advisory lock WithCustomerLock uses on every customer with a claimed order. The locks are
+// taken in customer order so that concurrent executors can't deadlock on each other.
+func lockCustomers(tx *gorm.DB, pending []pendingOrder) error {
+	customerIDs := make([]uint, 0, len(pending))
+	for _, p := range pending {
+		customerIDs = append(customerIDs, p.CustomerID)
+	}
+	sort.Slice(customerIDs, func(i, j int) bool { return customerIDs[i] < customerIDs[j] })
+
+	for i, customerID := range customerIDs {
+		if i > 0 && customerID == customerIDs[i-1] {
+			continue
+		}
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", customerLockNamespace, customerID).Error; err != nil {
+			return errors.Wrap(err, ErrLockingCustomer)
+		}
+	}
+
+	return nil
+}
+
+// heldShares returns the number of shares the customer holds in the fund of an order, from the same ISA wrapper. Only
+// executed orders count, including any executed earlier in the same batch.
+func heldShares(tx *gorm.DB, order *schema.Orders) (float64, error) {
+	var shares sql.NullFloat64
+	err := tx.Table(tableOrders).
+		Select("SUM(CASE WHEN order_type = 'buy' THEN total_shares ELSE -total_shares END)").
+		Where("customer_id = ? AND code = ? AND isa_type = ?", order.CustomerID, order.Code, order.ISAType).
+		Where("status = ?", schema.Executed).
+		Find(&shares).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return shares.Float64, nil
+}
+
+// recordRealisedGain works out the book cost of an executed sell from the average cost of the holding it came from,
+// and records it along with the gain realised over it. The holding is pooled from the customer's other executed orders
+// for the fund in the same ISA wrapper, in the order they executed. Orders from before execution times were recorded
+// are taken to have executed when they were placed.
+func recordRealisedGain(tx *gorm.DB, order *schema.Orders) error {
+	var history []schema.Orders
+	err := tx.Table(tableOrders).
+		Where("customer_id = ? AND code = ? AND isa_type = ?", order.CustomerID, order.Code, order.ISAType).
+		Where("status = ? AND order_id <> ?", schema.Executed, order.OrderID).
+		Order("COALESCE(execution_time, order_time), order_id").
+		Find(&history).Error
+	if err != nil {
+		return err
+	}
+
+	var pool costbasis.Pool
+	for _, o := range history {
+		if o.OrderType == schema.Buy {
+			pool.Buy(o.Shares, o.PurchasedValueGBP)
+		} else {
+			pool.Sell(o.Shares, o.PurchasedValueGBP)
+		}
+	}
+
+	cost, gain := pool.Sell(order.Shares, order.PurchasedValueGBP)
+	cost, gain = money.RoundPence(cost), money.RoundPence(gain)
+	order.BookCostGBP = &cost
+	order.RealisedGainGBP = &gain
+
+	return tx.Table(tableOrders).Where("order_id = ?", order.OrderID).Updates(map[string]interface{}{
+		"book_cost_gbp":     cost,
+		"realised_gain_gbp": gain,
+	}).Error
+}
+
+// recordLifetimeISALedger adds the government bonus for an executed lifetime ISA subscription, or the charge for an
+// unauthorised lifetime ISA withdrawal. Both are worked out from the executed value of the order.
+func recordLifetimeISALedger(tx *gorm.DB, order *schema.Orders, at time.Time) error {
+	if order.ISAType != schema.Lifetime {
+		return nil
+	}
+
+	entry := schema.LifetimeISALedger{
+		CustomerID: order.CustomerID,
+		OrderID:    order.OrderID,
+		CreatedAt:  at,
+	}
+
+	switch {
+	case order.OrderType == schema.Buy:
+		entry.EntryType = schema.GovernmentBonus
+		entry.AmountGBP = money.RoundPence(order.PurchasedValueGBP * lifetimeISABonusRate)
+	case order.WithdrawalReason == nil || !order.WithdrawalReason.Authorised():
+		entry.EntryType = schema.WithdrawalCharge
+		entry.AmountGBP = -money.RoundPence(order.PurchasedValueGBP * lifetimeISAWithdrawalChargeRate)
+	default:
+		return nil
+	}
+
+	return tx.Table(tableLifetimeISALedger).Create(&entry).Error
+}
+
+func (s *Store) CreateLifetimeISA(ctx context.Context, account *schema.LifetimeISAAccounts) (*LifetimeISA, error) {
+	result := s.conn(ctx).Table(tableLifetimeISAAccounts).Clauses(clause.OnConflict{DoNothing: true}).Create(account)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, ErrCreatingLifetimeISA)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.Wrap(ErrLifetimeISAAlreadyOpen, ErrCreatingLifetimeISA)
+	}
+
+	return &LifetimeISA{
+		CustomerID: account.CustomerID,
+		OpenedAt:   account.OpenedAt,
+	}, nil
+}
+
+func (s *Store) GetLifetimeISA(ctx context.Context, customerID int) (*LifetimeISA, error) {
+	var account LifetimeISA
+	err := s.conn(ctx).Table(tableLifetimeISAAccounts).Where("customer_id = ?", customerID).Take(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(ErrLifetimeISANotFound, ErrGettingLifetimeISA)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingLifetimeISA)
+	}
+
+	return &account, nil
+}
+
+// GetLifetimeISALedger returns the bonuses and charges applied to the customer's lifetime ISA, oldest first.
+func (s *Store) GetLifetimeISALedger(ctx context.Context, customerID int) ([]LedgerEntry, error) {
+	var entries []LedgerEntry
+	err := s.conn(ctx).Table(tableLifetimeISALedger).Where("customer_id = ?", customerID).Order("created_at, entry_id").Find(&entries).Error
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingLifetimeISALedger)
+	}
+
+	return entries, nil
+}
+
+func (s *Store) CreateTransfer(ctx context.Context, transfer *schema.Transfers) (*Transfer, error) {
+	if err := s.conn(ctx).Table(tableTransfers).Create(transfer).Error; err != nil {
+		return nil, errors.Wrap(err, ErrCreatingTransfer)
+	}
+
+	return toTransfer(transfer), nil
+}
+
+// GetTransfers returns every transfer the customer has requested, oldest first.
+func (s *Store) GetTransfers(ctx context.Context, customerID int) ([]Transfer, error) {
+	var transfers []Transfer
+	err := s.conn(ctx).Table(tableTransfers).Where("customer_id = ?", customerID).Order("requested_at, transfer_id").Find(&transfers).Error
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGettingTransfers)
+	}
+
+	return transfers, nil
+}
+
+// UpdateTransferStatus moves a customer's transfer to the next status, refusing any transition the transfer lifecycle
+// doesn't allow. Transfers belonging to another customer are reported as not found.
+func (s *Store) UpdateTransferStatus(ctx context.Context, customerID int, transferID uint, next schema.TransferStatus, at time.Time) (*Transfer, error) {
+	var transfer schema.Transfers
+	err := s.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(tableTransfers).Clauses(clause.Locking{Strength: "UPDATE"}).Where("transfer_id = ? AND customer_id = ?", transferID, customerID).Take(&transfer).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTransferNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if !transfer.Status.CanTransitionTo(next) {
+			return errors.Wrapf(ErrInvalidTransferTransition, "%s to %s", transfer.Status, next)
+		}
+
+		transfer.Status = next
+		transfer.UpdatedAt = at
+		updates := map[string]interface{}{"status": next, "updated_at": at}
+		if next == schema.TransferCompleted {
+			transfer.CompletedAt = &at
+			updates["completed_at"] = at
+		}
+
+		return tx.Table(tableTransfers).Where("transfer_id = ?", transferID).Updates(updates).Error
+	})
 	if err != nil {
-		return 0, errors.Wrap(err, ErrGettingAmountSpentCurrentTaxYear)
+		return nil, errors.Wrap(err, ErrUpdatingTransferStatus)
+	}
+
+	return toTransfer(&transfer), nil
+}
+
+// toCustomer maps a persisted customers row onto the model returned to callers of the store.
+func toCustomer(customer *schema.Customers) *Customer {
+	var employeeReference, defaultFundCode string
+	var riskProfile schema.RiskScore
+	if customer.RiskProfile != nil {
+		riskProfile = *customer.RiskProfile
 	}
+	if customer.EmployeeReference != nil {
+		employeeReference = *customer.EmployeeReference
+	}
+	if customer.DefaultFundCode != nil {
+		defaultFundCode = *customer.DefaultFundCode
+	}
+
+	return &Customer{
+		CustomerID:        customer.CustomerID,
+		CustomerType:      customer.CustomerType,
+		EmployerID:        customer.EmployerID,
+		EmployeeReference: employeeReference,
+		DefaultFundCode:   defaultFundCode,
+		RiskProfile:       riskProfile,
+		RiskProfiledAt:    customer.RiskProfiledAt,
+		DateOfBirth:       customer.DateOfBirth,
+		Residency:         customer.Residency,
+		Status:            customer.Status,
+		CreatedAt:         customer.CreatedAt,
+	}
+}
+
+// toTransfer maps a persisted transfers row onto the model returned to callers of the store.
+func toTransfer(transfer *schema.Transfers) *Transfer {
+	return &Transfer{
+		TransferID:             transfer.TransferID,
+		CustomerID:             transfer.CustomerID,
+		Direction:              transfer.Direction,
+		ISAType:                transfer.ISAType,
+		Method:                 transfer.Method,
+		Provider:               transfer.Provider,
+		CurrentYearAmountGBP:   transfer.CurrentYearAmountGBP,
+		PreviousYearsAmountGBP: transfer.PreviousYearsAmountGBP,
+		Status:                 transfer.Status,
+		RequestedAt:            transfer.RequestedAt,
+		UpdatedAt:              transfer.UpdatedAt,
+		CompletedAt:            transfer.CompletedAt,
+	}
+}
 
-	if !allowance.Valid {
-		allowance.Float64 = 0
+// toOrder maps a persisted orders row onto the model returned to callers of the store.
+func toOrder(order *schema.Orders) *Order {
+	return &Order{
+		OrderID:           order.OrderID,
+		CustomerID:        order.CustomerID,
+		Name:              order.Name,
+		Code:              order.Code,
+		PurchaseTime:      order.OrderTime,
+		OrderType:         order.OrderType,
+		SharesPurchased:   order.Shares,
+		AmountGBP:         order.PurchasedValueGBP,
+		Status:            order.Status,
+		ExecutionTime:     order.ExecutionTime,
+		ExecutionPriceGBP: order.ExecutionPriceGBP,
+		IdempotencyKey:    order.IdempotencyKey,
+		RequestHash:       order.RequestHash,
+		ISAType:           order.ISAType,
+		WithdrawalReason:  order.WithdrawalReason,
+		BookCostGBP:       order.BookCostGBP,
+		RealisedGainGBP:   order.RealisedGainGBP,
+		RiskOverride:      order.RiskOverride,
+		RiskProfile:       order.RiskProfile,
 	}
-	return allowance.Float64, nil
 }
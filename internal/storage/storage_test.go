@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"github.com/jautyw/isa-investment-funds/config"
+	"github.com/jautyw/isa-investment-funds/internal/clock"
 	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/service"
 	"github.com/jautyw/isa-investment-funds/internal/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go"
@@ -12,6 +14,7 @@ import (
 	pg "gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"log"
+	"sync"
 	"testing"
 	"time"
 )
@@ -51,7 +54,7 @@ func setupDB(ctx context.Context) (*gorm.DB, func(), testcontainers.Container) {
 		log.Fatalf("Failed to connect to the database: %s", err)
 	}
 
-	err = db.AutoMigrate(&schema.Funds{}, &schema.Orders{}) // Example model
+	err = db.AutoMigrate(&schema.Customers{}, &schema.Employers{}, &schema.EmployerFunds{}, &schema.Funds{}, &schema.FundPrices{}, &schema.Orders{}, &schema.LifetimeISAAccounts{}, &schema.LifetimeISALedger{}, &schema.Transfers{}, &schema.Benchmarks{}, &schema.BenchmarkLevels{}) // Example model
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %s", err)
 	}
@@ -67,17 +70,62 @@ func setupDB(ctx context.Context) (*gorm.DB, func(), testcontainers.Container) {
 }
 
 func cleanDB(db *gorm.DB) error {
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "customers")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "customers", err)
+	}
+
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "employers")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "employers", err)
+	}
+
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "employer_funds")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "employer_funds", err)
+	}
+
 	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "funds")).Error; err != nil {
 		return fmt.Errorf("failed to clear table %s: %w", "funds", err)
 	}
 
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "fund_prices")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "fund_prices", err)
+	}
+
 	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "orders")).Error; err != nil {
 		return fmt.Errorf("failed to clear table %s: %w", "orders", err)
 	}
 
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "lifetime_isa_accounts")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "lifetime_isa_accounts", err)
+	}
+
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "lifetime_isa_ledgers")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "lifetime_isa_ledgers", err)
+	}
+
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "transfers")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "transfers", err)
+	}
+
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "benchmarks")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "benchmarks", err)
+	}
+
+	if err := db.Exec(fmt.Sprintf("DELETE FROM %s", "benchmark_levels")).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %w", "benchmark_levels", err)
+	}
+
 	return nil
 }
 
+// sumAmounts totals the value of the orders
+func sumAmounts(orders []storage.Order) float64 {
+	var total float64
+	for _, o := range orders {
+		total += o.AmountGBP
+	}
+	return total
+}
+
 func TestStore_GetFunds(t *testing.T) {
 	ctx := context.Background()
 	db, teardown, _ := setupDB(ctx)
@@ -91,30 +139,116 @@ func TestStore_GetFunds(t *testing.T) {
 		Name:         "ESG Global All Cap UCITS ETF",
 		Description:  "Some fund",
 		Code:         "V3AM",
-		AmountGBP:    4.92,
 		CustomerType: schema.Retail,
 		RiskScore:    schema.Medium,
-		LastUpdated:  time.Date(time.Now().Year()-1, 1, 0, 0, 0, 0, 0, time.Local),
+	}
+	lastUpdated := time.Date(time.Now().Year()-1, 1, 1, 0, 0, 0, 0, time.UTC)
+	prices := []schema.FundPrices{
+		{FundID: 1, ValuationPoint: lastUpdated.AddDate(0, 0, -1), PriceGBP: 4.85},
+		{FundID: 1, ValuationPoint: lastUpdated, PriceGBP: 4.92},
 	}
 
 	s := storage.NewStore(db)
 	err = db.Create(&fund).Error
 	assert.NoError(t, err)
+	err = db.Create(&prices).Error
+	assert.NoError(t, err)
 
-	funds, err := s.GetFunds(ctx, "retail")
+	funds, err := s.GetFunds(ctx, "retail", storage.FundQuery{})
 	assert.NoError(t, err)
-	assert.Equal(t, &storage.Funds{Funds: []storage.Fund{
+	assert.Equal(t, &storage.Funds{Total: 1, Funds: []storage.Fund{
 		{
+			ID:          fund.ID,
 			Name:        fund.Name,
 			Description: fund.Description,
 			Code:        fund.Code,
-			AmountGBP:   fund.AmountGBP,
+			AmountGBP:   4.92,
 			RiskScore:   fund.RiskScore,
-			LastUpdated: fund.LastUpdated,
+			LastUpdated: lastUpdated,
 		},
 	}}, funds)
 }
 
+func TestStore_GetFundsQuery(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	funds := []schema.Funds{
+		{ID: 1, Name: "ESG Global All Cap UCITS ETF", Code: "V3AM", CustomerType: schema.Retail, RiskScore: schema.Medium},
+		{ID: 2, Name: "ESG Global All Cap UCITS ETF - (USD) Accumulating", Code: "V3AB", CustomerType: schema.Retail, RiskScore: schema.Medium},
+		{ID: 3, Name: "Asia Pacific 100%", Code: "APAC", CustomerType: schema.Retail, RiskScore: schema.High},
+		{ID: 4, Name: "UK Gilts", Code: "GILT", CustomerType: schema.Retail, RiskScore: schema.Low},
+		{ID: 5, Name: "Short Term Money Market", Code: "MMKT", CustomerType: schema.Retail, RiskScore: schema.Low},
+		{ID: 6, Name: "ESG Global All Cap UCITS ETF", Code: "V3AM", CustomerType: schema.Workplace, RiskScore: schema.Medium},
+	}
+	err = db.Create(&funds).Error
+	assert.NoError(t, err)
+
+	// The money market fund hasn't been priced, and GILT ties with V3AM on price
+	now := time.Now()
+	err = db.Create(&[]schema.FundPrices{
+		{FundID: 1, ValuationPoint: now, PriceGBP: 4.92},
+		{FundID: 2, ValuationPoint: now.Add(-time.Hour), PriceGBP: 100},
+		{FundID: 2, ValuationPoint: now, PriceGBP: 5.10},
+		{FundID: 3, ValuationPoint: now, PriceGBP: 12.5},
+		{FundID: 4, ValuationPoint: now, PriceGBP: 4.92},
+		{FundID: 6, ValuationPoint: now, PriceGBP: 1},
+	}).Error
+	assert.NoError(t, err)
+
+	s := storage.NewStore(db)
+
+	codes := func(funds *storage.Funds) []string {
+		var c []string
+		for _, f := range funds.Funds {
+			c = append(c, f.Code)
+		}
+		return c
+	}
+
+	low, err := s.GetFunds(ctx, "retail", storage.FundQuery{RiskScore: schema.Low})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"GILT", "MMKT"}, codes(low))
+	assert.Equal(t, int64(2), low.Total)
+
+	// Search ignores case, and its wildcards only match themselves
+	esg, err := s.GetFunds(ctx, "retail", storage.FundQuery{Search: "esg global"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"V3AM", "V3AB"}, codes(esg))
+	percent, err := s.GetFunds(ctx, "retail", storage.FundQuery{Search: "%"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"APAC"}, codes(percent))
+
+	// Prices are filtered on the latest price, and unpriced funds are priced at zero
+	minPrice, maxPrice := 4.92, 6.0
+	priced, err := s.GetFunds(ctx, "retail", storage.FundQuery{MinPriceGBP: &minPrice, MaxPriceGBP: &maxPrice, SortBy: storage.SortFundsByPrice})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"V3AM", "GILT", "V3AB"}, codes(priced))
+
+	byName, err := s.GetFunds(ctx, "retail", storage.FundQuery{SortBy: storage.SortFundsByName, Descending: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"GILT", "MMKT", "V3AB", "V3AM", "APAC"}, codes(byName))
+
+	// Paging by price seeks past the last fund of each page, ties broken by ID, with the total counted across pages
+	var paged []string
+	query := storage.FundQuery{SortBy: storage.SortFundsByPrice, Descending: true, Limit: 2}
+	for pages := 0; pages < 5; pages++ {
+		page, err := s.GetFunds(ctx, "retail", query)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), page.Total)
+		paged = append(paged, codes(page)...)
+		if page.Next == nil {
+			break
+		}
+		query.After = page.Next
+	}
+	assert.Equal(t, []string{"APAC", "V3AB", "GILT", "V3AM", "MMKT"}, paged)
+}
+
 func TestStore_GetInvestmentOverview(t *testing.T) {
 	ctx := context.Background()
 	db, teardown, _ := setupDB(ctx)
@@ -127,6 +261,7 @@ func TestStore_GetInvestmentOverview(t *testing.T) {
 		OrderID:           1,
 		OrderType:         schema.Buy,
 		CustomerID:        11,
+		FundID:            1,
 		Name:              "ESG Global All Cap UCITS ETF",
 		Description:       "Some fund",
 		Code:              "V3AM",
@@ -134,10 +269,17 @@ func TestStore_GetInvestmentOverview(t *testing.T) {
 		PurchasedValueGBP: 200,
 		OrderTime:         time.Date(time.Now().Year()-1, 1, 0, 0, 0, 0, 0, time.Local),
 	}
+	// The holding is valued at the latest price
+	prices := []schema.FundPrices{
+		{FundID: 1, ValuationPoint: time.Now().Add(-48 * time.Hour), PriceGBP: 40},
+		{FundID: 1, ValuationPoint: time.Now().Add(-24 * time.Hour), PriceGBP: 55},
+	}
 
 	s := storage.NewStore(db)
 	err = db.Create(&order).Error
 	assert.NoError(t, err)
+	err = db.Create(&prices).Error
+	assert.NoError(t, err)
 
 	investments, err := s.GetInvestmentOverview(ctx, 11)
 	assert.NoError(t, err)
@@ -148,10 +290,12 @@ func TestStore_GetInvestmentOverview(t *testing.T) {
 			Code:          "V3AM",
 			NetShares:     4,
 			NetInvestment: 200,
+			BookCostGBP:   200,
+			PriceGBP:      55,
 		}}, investments)
 }
 
-func TestStore_GetAmountSpentCurrentTaxYear(t *testing.T) {
+func TestStore_GetCurrentTaxYearOrders(t *testing.T) {
 	ctx := context.Background()
 	db, teardown, _ := setupDB(ctx)
 	defer teardown()
@@ -168,19 +312,20 @@ func TestStore_GetAmountSpentCurrentTaxYear(t *testing.T) {
 		Code:              "V3AM",
 		Shares:            4,
 		PurchasedValueGBP: 200,
-		OrderTime:         time.Date(time.Now().Year(), 1, 0, 0, 0, 0, 0, time.Local),
+		OrderTime:         time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
 	}
 
-	s := storage.NewStore(db)
+	// 2024/25 runs from 6 April 2024 to 5 April 2025
+	s := storage.NewStore(db, storage.WithClock(clock.Fixed(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))))
 	err = db.Create(&order).Error
 	assert.NoError(t, err)
 
-	allowance, err := s.GetAmountSpentCurrentTaxYear(ctx, 11)
+	allowance, err := s.GetCurrentTaxYearOrders(ctx, 11)
 	assert.NoError(t, err)
-	assert.Equal(t, float64(200), allowance)
+	assert.Equal(t, float64(200), sumAmounts(allowance))
 }
 
-func TestStore_GetAmountSpentCurrentTaxYearFullAllowance(t *testing.T) {
+func TestStore_GetCurrentTaxYearOrdersPreviousYear(t *testing.T) {
 	ctx := context.Background()
 	db, teardown, _ := setupDB(ctx)
 	defer teardown()
@@ -198,14 +343,991 @@ func TestStore_GetAmountSpentCurrentTaxYearFullAllowance(t *testing.T) {
 		Shares:            4,
 		PurchasedValueGBP: 200,
 		// Order made before the current tax year so allowance should be 0
-		OrderTime: time.Date(time.Now().Year()-1, 1, 0, 0, 0, 0, 0, time.Local),
+		OrderTime: time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC),
+	}
+
+	s := storage.NewStore(db, storage.WithClock(clock.Fixed(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))))
+	err = db.Create(&order).Error
+	assert.NoError(t, err)
+
+	allowance, err := s.GetCurrentTaxYearOrders(ctx, 11)
+	assert.NoError(t, err)
+	assert.Empty(t, allowance)
+}
+
+func TestStore_GetCurrentTaxYearOrdersWithdrawals(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	lastYear := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	thisYear := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	orders := []schema.Orders{
+		{OrderID: 1, OrderType: schema.Buy, CustomerID: 11, Name: "Fund A", Code: "A", Shares: 100, PurchasedValueGBP: 500, OrderTime: lastYear, Status: schema.Executed, ExecutionTime: &lastYear},
+		// Placed last year but withdrawn this year, so it can be replaced this year
+		{OrderID: 2, OrderType: schema.Sell, CustomerID: 11, Name: "Fund A", Code: "A", Shares: 10, PurchasedValueGBP: 50, OrderTime: lastYear, Status: schema.Executed, ExecutionTime: &thisYear},
+		// Not withdrawn until it executes
+		{OrderID: 3, OrderType: schema.Sell, CustomerID: 11, Name: "Fund A", Code: "A", Shares: 10, PurchasedValueGBP: 50, OrderTime: thisYear, Status: schema.Pending},
+		{OrderID: 4, OrderType: schema.Buy, CustomerID: 11, Name: "Fund A", Code: "A", PurchasedValueGBP: 1000, OrderTime: thisYear, Status: schema.Pending, ISAType: schema.Lifetime},
+	}
+
+	s := storage.NewStore(db, storage.WithClock(clock.Fixed(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))))
+	err = db.Create(&orders).Error
+	assert.NoError(t, err)
+
+	taxYearOrders, err := s.GetCurrentTaxYearOrders(ctx, 11)
+	assert.NoError(t, err)
+	assert.Len(t, taxYearOrders, 2)
+	assert.Equal(t, uint(2), taxYearOrders[0].OrderID)
+	assert.Equal(t, uint(4), taxYearOrders[1].OrderID)
+	assert.Equal(t, schema.Lifetime, taxYearOrders[1].ISAType)
+}
+
+func TestStore_GetFund(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	fund := schema.Funds{
+		ID:           1,
+		Name:         "ESG Global All Cap UCITS ETF",
+		Description:  "Some fund",
+		Code:         "V3AM",
+		CustomerType: schema.Retail,
+		RiskScore:    schema.Medium,
+	}
+
+	s := storage.NewStore(db)
+	err = db.Create(&fund).Error
+	assert.NoError(t, err)
+
+	f, err := s.GetFund(ctx, "V3AM", "retail")
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), f.ID)
+	assert.Equal(t, "V3AM", f.Code)
+	// Funds that have never been priced can't be traded
+	assert.Equal(t, float64(0), f.AmountGBP)
+
+	_, err = s.GetFund(ctx, "V3AM", "workplace")
+	assert.ErrorIs(t, err, storage.ErrFundNotFound)
+}
+
+func TestStore_GetEmployerFunds(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	funds := []schema.Funds{
+		{ID: 1, Name: "ESG Global All Cap UCITS ETF", Code: "V3AM", CustomerType: schema.Retail, RiskScore: schema.Medium},
+		{ID: 3, Name: "ESG Global All Cap UCITS ETF", Code: "V3AM", CustomerType: schema.Workplace, RiskScore: schema.Medium},
+		{ID: 4, Name: "ESG Global All Cap UCITS ETF - (USD) Accumulating", Code: "V3AB", CustomerType: schema.Workplace, RiskScore: schema.Medium},
+	}
+	err = db.Create(&funds).Error
+	assert.NoError(t, err)
+
+	err = db.Create(&[]schema.FundPrices{{FundID: 3, ValuationPoint: time.Now(), PriceGBP: 4.92}}).Error
+	assert.NoError(t, err)
+
+	// Each employer approves its own funds at its own charge, a retail fund on the list is never offered
+	err = db.Create(&[]schema.EmployerFunds{
+		{EmployerID: 1, FundID: 1, AnnualChargePercent: 0.1},
+		{EmployerID: 1, FundID: 3, AnnualChargePercent: 0.15},
+		{EmployerID: 2, FundID: 3, AnnualChargePercent: 0.2},
+		{EmployerID: 2, FundID: 4, AnnualChargePercent: 0.2},
+	}).Error
+	assert.NoError(t, err)
+
+	s := storage.NewStore(db)
+
+	catalogue, err := s.GetEmployerFunds(ctx, 1, storage.FundQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, catalogue.Funds, 1)
+	assert.Equal(t, uint(3), catalogue.Funds[0].ID)
+	assert.Equal(t, 4.92, catalogue.Funds[0].AmountGBP)
+	assert.Equal(t, 0.15, *catalogue.Funds[0].AnnualChargePercent)
+
+	catalogue, err = s.GetEmployerFunds(ctx, 2, storage.FundQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, catalogue.Funds, 2)
+	assert.Equal(t, 0.2, *catalogue.Funds[0].AnnualChargePercent)
+
+	// The catalogue is searched like any other listing
+	catalogue, err = s.GetEmployerFunds(ctx, 2, storage.FundQuery{Search: "usd"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), catalogue.Total)
+	assert.Equal(t, uint(4), catalogue.Funds[0].ID)
+
+	fund, err := s.GetEmployerFund(ctx, "V3AB", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(4), fund.ID)
+
+	_, err = s.GetEmployerFund(ctx, "V3AB", 1)
+	assert.ErrorIs(t, err, storage.ErrFundNotFound)
+
+	// Retail funds carry no charge
+	retail, err := s.GetFund(ctx, "V3AM", "retail")
+	assert.NoError(t, err)
+	assert.Nil(t, retail.AnnualChargePercent)
+}
+
+func TestStore_GetEmployees(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	s := storage.NewStore(db)
+
+	_, err = s.GetEmployer(ctx, 1)
+	assert.ErrorIs(t, err, storage.ErrEmployerNotFound)
+
+	err = db.Create(&[]schema.Employers{{ID: 1, Name: "Acme Ltd"}, {ID: 2, Name: "Globex"}}).Error
+	assert.NoError(t, err)
+
+	employer, err := s.GetEmployer(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme Ltd", employer.Name)
+
+	acme, globex := uint(1), uint(2)
+	reference, fund := "EMP001", "V3AM"
+	customers := []schema.Customers{
+		{CustomerID: 2, CustomerType: schema.Workplace, EmployerID: &acme, EmployeeReference: &reference, DefaultFundCode: &fund, Residency: schema.UKResident, Status: schema.CustomerActive},
+		{CustomerID: 3, CustomerType: schema.Workplace, EmployerID: &globex, EmployeeReference: &reference, Residency: schema.UKResident, Status: schema.CustomerActive},
+		{CustomerID: 4, CustomerType: schema.Retail, Residency: schema.UKResident, Status: schema.CustomerActive},
+	}
+	err = db.Create(&customers).Error
+	assert.NoError(t, err)
+
+	// Employee references only need to be unique within an employer
+	employees, err := s.GetEmployees(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, employees, 1)
+	assert.Equal(t, uint(2), employees[0].CustomerID)
+	assert.Equal(t, "EMP001", employees[0].EmployeeReference)
+	assert.Equal(t, "V3AM", employees[0].DefaultFundCode)
+
+	_, err = s.CreateCustomer(ctx, &schema.Customers{CustomerID: 5, CustomerType: schema.Workplace, EmployerID: &acme, EmployeeReference: &reference, Residency: schema.UKResident, Status: schema.CustomerActive})
+	assert.Error(t, err)
+
+	employees, err = s.GetEmployees(ctx, 2)
+	assert.NoError(t, err)
+	assert.Len(t, employees, 1)
+	assert.Empty(t, employees[0].DefaultFundCode)
+}
+
+func TestStore_GetFundPriceAsOf(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	monday := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	prices := []schema.FundPrices{
+		{FundID: 1, ValuationPoint: monday, PriceGBP: 4.90},
+		{FundID: 1, ValuationPoint: monday.AddDate(0, 0, 1), PriceGBP: 4.95},
+		{FundID: 2, ValuationPoint: monday.AddDate(0, 0, 2), PriceGBP: 10},
 	}
+	err = db.Create(&prices).Error
+	assert.NoError(t, err)
+
+	s := storage.NewStore(db)
+
+	price, err := s.GetFundPriceAsOf(ctx, 1, monday)
+	assert.NoError(t, err)
+	assert.Equal(t, 4.90, price.PriceGBP)
+
+	// Between valuation points the fund is worth what it was last priced at
+	price, err = s.GetFundPriceAsOf(ctx, 1, monday.AddDate(0, 0, 5))
+	assert.NoError(t, err)
+	assert.Equal(t, 4.95, price.PriceGBP)
+	assert.True(t, monday.AddDate(0, 0, 1).Equal(price.ValuationPoint))
+
+	_, err = s.GetFundPriceAsOf(ctx, 1, monday.Add(-time.Minute))
+	assert.ErrorIs(t, err, storage.ErrFundPriceNotFound)
+}
+
+func TestStore_GetFundPriceHistory(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	monday := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	funds := []schema.Funds{
+		{ID: 1, Name: "Fund A", Code: "A", CustomerType: schema.Retail, RiskScore: schema.Medium},
+		{ID: 2, Name: "Fund B", Code: "B", CustomerType: schema.Retail, RiskScore: schema.Low},
+	}
+	prices := []schema.FundPrices{
+		{FundID: 1, ValuationPoint: monday.AddDate(0, 0, 1), PriceGBP: 4.95},
+		{FundID: 1, ValuationPoint: monday, PriceGBP: 4.90},
+		{FundID: 2, ValuationPoint: monday, PriceGBP: 10},
+	}
+	order := schema.Orders{OrderID: 1, OrderType: schema.Buy, CustomerID: 11, FundID: 1, Name: "Fund A", Code: "A", PurchasedValueGBP: 100, OrderTime: monday, Status: schema.Pending, ISAType: schema.StocksAndShares}
+
+	err = db.Create(&funds).Error
+	assert.NoError(t, err)
+	err = db.Create(&prices).Error
+	assert.NoError(t, err)
+	err = db.Create(&order).Error
+	assert.NoError(t, err)
 
 	s := storage.NewStore(db)
+
+	// Only the fund the customer has ordered is included
+	history, err := s.GetFundPriceHistory(ctx, 11)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, "A", history[0].Code)
+	assert.Equal(t, 4.90, history[0].PriceGBP)
+	assert.Equal(t, 4.95, history[1].PriceGBP)
+
+	history, err = s.GetFundPriceHistory(ctx, 12)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestStore_Benchmarks(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	monday := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	funds := []schema.Funds{
+		{ID: 1, Name: "Fund A", Code: "A", CustomerType: schema.Retail, RiskScore: schema.Medium},
+		{ID: 2, Name: "Fund A", Code: "A", CustomerType: schema.Workplace, RiskScore: schema.Medium},
+	}
+	order := schema.Orders{OrderID: 1, OrderType: schema.Buy, CustomerID: 11, FundID: 1, Name: "Fund A", Code: "A", PurchasedValueGBP: 100, OrderTime: monday, Status: schema.Pending, ISAType: schema.StocksAndShares}
+
+	err = db.Create(&funds).Error
+	assert.NoError(t, err)
 	err = db.Create(&order).Error
 	assert.NoError(t, err)
 
-	allowance, err := s.GetAmountSpentCurrentTaxYear(ctx, 11)
+	s := storage.NewStore(db)
+
+	benchmark, err := s.RegisterBenchmark(ctx, &schema.Benchmarks{Code: "IDX", Name: "Index"}, []schema.BenchmarkLevels{
+		{Date: monday, Level: 1000},
+		{Date: monday.AddDate(0, 0, 1), Level: 1010},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Index", benchmark.Name)
+
+	// Registering the code again renames it and corrects the level already held
+	again, err := s.RegisterBenchmark(ctx, &schema.Benchmarks{Code: "IDX", Name: "The Index"}, []schema.BenchmarkLevels{
+		{Date: monday.AddDate(0, 0, 1), Level: 1020},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, benchmark.ID, again.ID)
+
+	err = s.SetFundBenchmark(ctx, "A", "IDX")
+	assert.NoError(t, err)
+
+	err = s.SetFundBenchmark(ctx, "B", "IDX")
+	assert.ErrorIs(t, err, storage.ErrFundNotFound)
+
+	err = s.SetFundBenchmark(ctx, "A", "NOPE")
+	assert.ErrorIs(t, err, storage.ErrBenchmarkNotFound)
+
+	// Both listings of the fund share the benchmark, its levels are only returned once
+	history, err := s.GetBenchmarkHistory(ctx, 11)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, "A", history[0].FundCode)
+	assert.Equal(t, "IDX", history[0].BenchmarkCode)
+	assert.Equal(t, "The Index", history[0].BenchmarkName)
+	assert.Equal(t, 1000.0, history[0].Level)
+	assert.Equal(t, 1020.0, history[1].Level)
+
+	history, err = s.GetBenchmarkHistory(ctx, 12)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestStore_UpsertFundPrices(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	funds := []schema.Funds{
+		{ID: 1, Name: "Fund A", Code: "A", CustomerType: schema.Retail, RiskScore: schema.Medium},
+		{ID: 2, Name: "Fund A", Code: "A", CustomerType: schema.Workplace, RiskScore: schema.Medium},
+		{ID: 3, Name: "Fund B", Code: "B", CustomerType: schema.Retail, RiskScore: schema.Low},
+	}
+	err = db.Create(&funds).Error
+	assert.NoError(t, err)
+
+	s := storage.NewStore(db)
+
+	ids, err := s.GetFundIDsByCode(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]uint{"A": {1, 2}, "B": {3}}, ids)
+
+	valuationPoint := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	err = s.UpsertFundPrices(ctx, []schema.FundPrices{{FundID: 1, ValuationPoint: valuationPoint, PriceGBP: 4.90}})
+	assert.NoError(t, err)
+
+	// Loading the same valuation point again corrects the price
+	err = s.UpsertFundPrices(ctx, []schema.FundPrices{{FundID: 1, ValuationPoint: valuationPoint, PriceGBP: 4.95}})
+	assert.NoError(t, err)
+
+	price, err := s.GetFundPriceAsOf(ctx, 1, valuationPoint)
+	assert.NoError(t, err)
+	assert.Equal(t, 4.95, price.PriceGBP)
+}
+
+func TestStore_CreateOrder(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	s := storage.NewStore(db)
+	order, err := s.CreateOrder(ctx, &schema.Orders{
+		OrderType:         schema.Buy,
+		CustomerID:        11,
+		FundID:            1,
+		Name:              "ESG Global All Cap UCITS ETF",
+		Description:       "Some fund",
+		Code:              "V3AM",
+		Shares:            4,
+		PurchasedValueGBP: 200,
+		OrderTime:         time.Now(),
+	})
+	assert.NoError(t, err)
+	assert.NotZero(t, order.OrderID)
+	assert.Equal(t, uint(11), order.CustomerID)
+	assert.Equal(t, float64(200), order.AmountGBP)
+
+	investments, err := s.GetInvestmentOverview(ctx, 11)
+	assert.NoError(t, err)
+	assert.Len(t, investments, 1)
+}
+
+func TestStore_GetInvestmentOverviewIgnoresPendingOrders(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	order := schema.Orders{
+		OrderID:           1,
+		OrderType:         schema.Buy,
+		CustomerID:        11,
+		Name:              "ESG Global All Cap UCITS ETF",
+		Description:       "Some fund",
+		Code:              "V3AM",
+		Shares:            4,
+		PurchasedValueGBP: 200,
+		OrderTime:         time.Now(),
+		Status:            schema.Pending,
+	}
+
+	s := storage.NewStore(db)
+	err = db.Create(&order).Error
+	assert.NoError(t, err)
+
+	investments, err := s.GetInvestmentOverview(ctx, 11)
+	assert.NoError(t, err)
+	assert.Empty(t, investments)
+
+	// Pending buys still commit the customer's allowance
+	allowance, err := s.GetCurrentTaxYearOrders(ctx, 11)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(200), sumAmounts(allowance))
+}
+
+func TestStore_UpdateOrderStatus(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	order := schema.Orders{
+		OrderID:           1,
+		OrderType:         schema.Buy,
+		CustomerID:        11,
+		Name:              "ESG Global All Cap UCITS ETF",
+		Description:       "Some fund",
+		Code:              "V3AM",
+		Shares:            4,
+		PurchasedValueGBP: 200,
+		OrderTime:         time.Now(),
+		Status:            schema.Pending,
+	}
+
+	s := storage.NewStore(db)
+	err = db.Create(&order).Error
+	assert.NoError(t, err)
+
+	// The order belongs to customer 11 so customer 12 can't see it
+	_, err = s.UpdateOrderStatus(ctx, 12, 1, schema.Cancelled)
+	assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+
+	updated, err := s.UpdateOrderStatus(ctx, 11, 1, schema.Cancelled)
+	assert.NoError(t, err)
+	assert.Equal(t, schema.Cancelled, updated.Status)
+
+	// Cancelled is terminal so the order can no longer be executed
+	_, err = s.UpdateOrderStatus(ctx, 11, 1, schema.Executed)
+	assert.ErrorIs(t, err, storage.ErrInvalidOrderTransition)
+
+	_, err = s.UpdateOrderStatus(ctx, 11, 2, schema.Cancelled)
+	assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+}
+
+func TestStore_ExecutePendingOrders(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	orderTime := time.Now().Add(-time.Hour)
+
+	fund := schema.Funds{
+		ID:           1,
+		Name:         "ESG Global All Cap UCITS ETF",
+		Description:  "Some fund",
+		Code:         "V3AM",
+		CustomerType: schema.Retail,
+		RiskScore:    schema.Medium,
+	}
+	// Orders are executed at the first valuation point after they were placed, not the latest one
+	prices := []schema.FundPrices{
+		{FundID: 1, ValuationPoint: orderTime.Add(-time.Minute), PriceGBP: 4},
+		{FundID: 1, ValuationPoint: orderTime.Add(time.Minute), PriceGBP: 5},
+		{FundID: 1, ValuationPoint: orderTime.Add(30 * time.Minute), PriceGBP: 6},
+	}
+
+	orders := []schema.Orders{
+		{
+			OrderID:           1,
+			OrderType:         schema.Buy,
+			CustomerID:        11,
+			FundID:            1,
+			Name:              "ESG Global All Cap UCITS ETF",
+			Code:              "V3AM",
+			PurchasedValueGBP: 200,
+			OrderTime:         orderTime,
+			Status:            schema.Pending,
+		},
+		{
+			OrderID:    2,
+			OrderType:  schema.Sell,
+			CustomerID: 11,
+			FundID:     1,
+			Name:       "ESG Global All Cap UCITS ETF",
+			Code:       "V3AM",
+			Shares:     10,
+			OrderTime:  orderTime,
+			Status:     schema.Pending,
+		},
+		{
+			// Placed after the latest valuation point so it waits for the next price
+			OrderID:           3,
+			OrderType:         schema.Buy,
+			CustomerID:        11,
+			FundID:            1,
+			Name:              "ESG Global All Cap UCITS ETF",
+			Code:              "V3AM",
+			PurchasedValueGBP: 100,
+			OrderTime:         time.Now().Add(time.Hour),
+			Status:            schema.Pending,
+		},
+	}
+
+	s := storage.NewStore(db)
+	err = db.Create(&fund).Error
+	assert.NoError(t, err)
+	err = db.Create(&prices).Error
+	assert.NoError(t, err)
+	err = db.Create(&orders).Error
+	assert.NoError(t, err)
+
+	settled, err := s.ExecutePendingOrders(ctx, 10, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, settled, 2)
+	assert.Equal(t, schema.Executed, settled[0].Status)
+	assert.Equal(t, float64(40), settled[0].SharesPurchased)
+	assert.Equal(t, float64(50), settled[1].AmountGBP)
+
+	// Nothing is left to execute until the fund is priced again
+	settled, err = s.ExecutePendingOrders(ctx, 10, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, settled)
+}
+
+func TestStore_ExecutePendingOrdersRealisedGain(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	boughtAt := time.Now().Add(-48 * time.Hour)
+	orderTime := time.Now().Add(-time.Hour)
+
+	fund := schema.Funds{ID: 1, Name: "Fund A", Code: "A", CustomerType: schema.Retail, RiskScore: schema.Medium}
+	price := schema.FundPrices{FundID: 1, ValuationPoint: time.Now(), PriceGBP: 6}
+	orders := []schema.Orders{
+		// 200 shares have been bought at an average of £5
+		{OrderID: 1, OrderType: schema.Buy, CustomerID: 11, FundID: 1, Name: "Fund A", Code: "A", Shares: 100, PurchasedValueGBP: 400, OrderTime: boughtAt, Status: schema.Executed, ExecutionTime: &boughtAt, ISAType: schema.StocksAndShares},
+		{OrderID: 2, OrderType: schema.Buy, CustomerID: 11, FundID: 1, Name: "Fund A", Code: "A", Shares: 100, PurchasedValueGBP: 600, OrderTime: boughtAt, Status: schema.Executed, ExecutionTime: &boughtAt, ISAType: schema.StocksAndShares},
+		{OrderID: 3, OrderType: schema.Sell, CustomerID: 11, FundID: 1, Name: "Fund A", Code: "A", Shares: 50, OrderTime: orderTime, Status: schema.Pending, ISAType: schema.StocksAndShares},
+	}
+
+	s := storage.NewStore(db)
+	err = db.Create(&fund).Error
+	assert.NoError(t, err)
+	err = db.Create(&price).Error
+	assert.NoError(t, err)
+	err = db.Create(&orders).Error
+	assert.NoError(t, err)
+
+	settled, err := s.ExecutePendingOrders(ctx, 10, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, settled, 1)
+	assert.Equal(t, 250.0, *settled[0].BookCostGBP)
+	assert.Equal(t, 50.0, *settled[0].RealisedGainGBP)
+
+	// The rest of the holding keeps its average cost rather than being reduced by the sale proceeds
+	investments, err := s.GetInvestmentOverview(ctx, 11)
+	assert.NoError(t, err)
+	assert.Len(t, investments, 1)
+	assert.Equal(t, 750.0, investments[0].BookCostGBP)
+	assert.Equal(t, 700.0, investments[0].NetInvestment)
+	assert.Equal(t, 50.0, investments[0].RealisedGainGBP)
+}
+
+func TestStore_ExecutePendingOrdersRejectsOversoldSells(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	boughtAt := time.Now().Add(-48 * time.Hour)
+	orderTime := time.Now().Add(-time.Hour)
+
+	fund := schema.Funds{ID: 1, Name: "Fund A", Code: "A", CustomerType: schema.Retail, RiskScore: schema.Medium}
+	price := schema.FundPrices{FundID: 1, ValuationPoint: time.Now(), PriceGBP: 6}
+	orders := []schema.Orders{
+		{OrderID: 1, OrderType: schema.Buy, CustomerID: 11, FundID: 1, Name: "Fund A", Code: "A", Shares: 100, PurchasedValueGBP: 500, OrderTime: boughtAt, Status: schema.Executed, ExecutionTime: &boughtAt, ISAType: schema.StocksAndShares},
+		// Both sells were let through, together they would sell more than is held
+		{OrderID: 2, OrderType: schema.Sell, CustomerID: 11, FundID: 1, Name: "Fund A", Code: "A", Shares: 80, OrderTime: orderTime, Status: schema.Pending, ISAType: schema.StocksAndShares},
+		{OrderID: 3, OrderType: schema.Sell, CustomerID: 11, FundID: 1, Name: "Fund A", Code: "A", Shares: 80, OrderTime: orderTime, Status: schema.Pending, ISAType: schema.StocksAndShares},
+	}
+
+	s := storage.NewStore(db)
+	err = db.Create(&fund).Error
+	assert.NoError(t, err)
+	err = db.Create(&price).Error
+	assert.NoError(t, err)
+	err = db.Create(&orders).Error
+	assert.NoError(t, err)
+
+	// Both pending sells are counted against the holding when another is placed
+	available, err := s.GetAvailableShares(ctx, 11, "A", schema.StocksAndShares)
+	assert.NoError(t, err)
+	assert.Equal(t, -60.0, available)
+
+	settled, err := s.ExecutePendingOrders(ctx, 10, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, settled, 2)
+	assert.Equal(t, schema.Executed, settled[0].Status)
+	assert.Equal(t, schema.Rejected, settled[1].Status)
+
+	available, err = s.GetAvailableShares(ctx, 11, "A", schema.StocksAndShares)
+	assert.NoError(t, err)
+	assert.Equal(t, 20.0, available)
+}
+
+func TestStore_ExecutePendingOrdersLifetimeISALedger(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	orderTime := time.Now().Add(-time.Hour)
+	unauthorised := schema.Unauthorised
+	firstHome := schema.FirstHome
+
+	fund := schema.Funds{ID: 1, Name: "Fund A", Code: "A", CustomerType: schema.Retail, RiskScore: schema.Medium}
+	price := schema.FundPrices{FundID: 1, ValuationPoint: time.Now(), PriceGBP: 5}
+	orders := []schema.Orders{
+		{OrderID: 1, OrderType: schema.Buy, CustomerID: 11, FundID: 1, Name: "Fund A", Code: "A", PurchasedValueGBP: 1000, OrderTime: orderTime, Status: schema.Pending, ISAType: schema.Lifetime},
+		{OrderID: 2, OrderType: schema.Sell, CustomerID: 11, FundID: 1, Name: "Fund A", Code: "A", Shares: 20, OrderTime: orderTime, Status: schema.Pending, ISAType: schema.Lifetime, WithdrawalReason: &unauthorised},
+		// Authorised withdrawals aren't charged
+		{OrderID: 3, OrderType: schema.Sell, CustomerID: 11, FundID: 1, Name: "Fund A", Code: "A", Shares: 20, OrderTime: orderTime, Status: schema.Pending, ISAType: schema.Lifetime, WithdrawalReason: &firstHome},
+		// Other wrappers have no bonus
+		{OrderID: 4, OrderType: schema.Buy, CustomerID: 11, FundID: 1, Name: "Fund A", Code: "A", PurchasedValueGBP: 1000, OrderTime: orderTime, Status: schema.Pending, ISAType: schema.StocksAndShares},
+	}
+
+	s := storage.NewStore(db)
+	err = db.Create(&fund).Error
+	assert.NoError(t, err)
+	err = db.Create(&price).Error
+	assert.NoError(t, err)
+	err = db.Create(&orders).Error
+	assert.NoError(t, err)
+
+	settled, err := s.ExecutePendingOrders(ctx, 10, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, settled, 4)
+
+	ledger, err := s.GetLifetimeISALedger(ctx, 11)
+	assert.NoError(t, err)
+	assert.Len(t, ledger, 2)
+
+	amounts := map[schema.LedgerEntryType]float64{}
+	for _, e := range ledger {
+		amounts[e.EntryType] = e.AmountGBP
+	}
+	assert.Equal(t, float64(250), amounts[schema.GovernmentBonus])
+	assert.Equal(t, float64(-25), amounts[schema.WithdrawalCharge])
+}
+
+func TestStore_Customers(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	s := storage.NewStore(db)
+	dateOfBirth := time.Date(1990, 6, 15, 0, 0, 0, 0, time.UTC)
+
+	_, err = s.GetCustomer(ctx, 11)
+	assert.ErrorIs(t, err, storage.ErrCustomerNotFound)
+
+	_, err = s.CreateCustomer(ctx, &schema.Customers{CustomerID: 11, CustomerType: schema.Workplace, DateOfBirth: dateOfBirth, Residency: schema.NonUKResident, Status: schema.CustomerSuspended, CreatedAt: time.Now()})
+	assert.NoError(t, err)
+
+	customer, err := s.GetCustomer(ctx, 11)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(11), customer.CustomerID)
+	assert.Equal(t, schema.Workplace, customer.CustomerType)
+	assert.Equal(t, schema.NonUKResident, customer.Residency)
+	assert.Equal(t, schema.CustomerSuspended, customer.Status)
+	assert.True(t, dateOfBirth.Equal(customer.DateOfBirth))
+}
+
+func TestStore_SetRiskProfile(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	s := storage.NewStore(db)
+	profiledAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	_, err = s.SetRiskProfile(ctx, 11, schema.Low, profiledAt)
+	assert.ErrorIs(t, err, storage.ErrCustomerNotFound)
+
+	_, err = s.CreateCustomer(ctx, &schema.Customers{CustomerID: 11, CustomerType: schema.Retail, DateOfBirth: time.Date(1990, 6, 15, 0, 0, 0, 0, time.UTC), Residency: schema.UKResident, Status: schema.CustomerActive, CreatedAt: time.Now()})
+	assert.NoError(t, err)
+
+	customer, err := s.GetCustomer(ctx, 11)
+	assert.NoError(t, err)
+	assert.Empty(t, customer.RiskProfile)
+	assert.Nil(t, customer.RiskProfiledAt)
+
+	customer, err = s.SetRiskProfile(ctx, 11, schema.Medium, profiledAt)
+	assert.NoError(t, err)
+	assert.Equal(t, schema.Medium, customer.RiskProfile)
+	assert.True(t, profiledAt.Equal(*customer.RiskProfiledAt))
+}
+
+func TestStore_CreateLifetimeISA(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	s := storage.NewStore(db)
+	openedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	_, err = s.GetLifetimeISA(ctx, 11)
+	assert.ErrorIs(t, err, storage.ErrLifetimeISANotFound)
+
+	account, err := s.CreateLifetimeISA(ctx, &schema.LifetimeISAAccounts{CustomerID: 11, OpenedAt: openedAt})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(11), account.CustomerID)
+
+	_, err = s.CreateLifetimeISA(ctx, &schema.LifetimeISAAccounts{CustomerID: 11, OpenedAt: time.Now()})
+	assert.ErrorIs(t, err, storage.ErrLifetimeISAAlreadyOpen)
+
+	account, err = s.GetLifetimeISA(ctx, 11)
+	assert.NoError(t, err)
+	assert.True(t, openedAt.Equal(account.OpenedAt))
+}
+
+func TestStore_Transfers(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	s := storage.NewStore(db)
+	requestedAt := time.Now().UTC().Truncate(time.Second)
+
+	transfer, err := s.CreateTransfer(ctx, &schema.Transfers{
+		CustomerID:             11,
+		Direction:              schema.TransferIn,
+		ISAType:                schema.StocksAndShares,
+		Method:                 schema.InSpecieTransfer,
+		Provider:               "Other Provider",
+		CurrentYearAmountGBP:   2000,
+		PreviousYearsAmountGBP: 18000,
+		Status:                 schema.TransferRequested,
+		RequestedAt:            requestedAt,
+		UpdatedAt:              requestedAt,
+	})
+	assert.NoError(t, err)
+	assert.NotZero(t, transfer.TransferID)
+
+	// Transfers can't skip straight from requested to completed
+	_, err = s.UpdateTransferStatus(ctx, 11, transfer.TransferID, schema.TransferCompleted, requestedAt)
+	assert.ErrorIs(t, err, storage.ErrInvalidTransferTransition)
+
+	// Nor can a customer update another customer's transfer
+	_, err = s.UpdateTransferStatus(ctx, 12, transfer.TransferID, schema.TransferInProgress, requestedAt)
+	assert.ErrorIs(t, err, storage.ErrTransferNotFound)
+
+	_, err = s.UpdateTransferStatus(ctx, 11, transfer.TransferID, schema.TransferInProgress, requestedAt)
+	assert.NoError(t, err)
+
+	completedAt := requestedAt.Add(time.Hour)
+	transfer, err = s.UpdateTransferStatus(ctx, 11, transfer.TransferID, schema.TransferCompleted, completedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, schema.TransferCompleted, transfer.Status)
+	assert.True(t, completedAt.Equal(*transfer.CompletedAt))
+
+	transfers, err := s.GetTransfers(ctx, 11)
+	assert.NoError(t, err)
+	assert.Len(t, transfers, 1)
+	assert.Equal(t, schema.TransferCompleted, transfers[0].Status)
+}
+
+func TestStore_CreateOrderDuplicateIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	key := "retry-123"
+	newOrder := func() *schema.Orders {
+		return &schema.Orders{
+			OrderType:         schema.Buy,
+			CustomerID:        11,
+			FundID:            1,
+			Name:              "ESG Global All Cap UCITS ETF",
+			Code:              "V3AM",
+			PurchasedValueGBP: 200,
+			OrderTime:         time.Now(),
+			Status:            schema.Pending,
+			IdempotencyKey:    &key,
+			RequestHash:       "abc",
+		}
+	}
+
+	s := storage.NewStore(db)
+	created, err := s.CreateOrder(ctx, newOrder())
+	assert.NoError(t, err)
+
+	_, err = s.CreateOrder(ctx, newOrder())
+	assert.ErrorIs(t, err, storage.ErrDuplicateIdempotencyKey)
+
+	existing, err := s.GetOrderByIdempotencyKey(ctx, 11, key)
+	assert.NoError(t, err)
+	assert.Equal(t, created.OrderID, existing.OrderID)
+	assert.Equal(t, "abc", existing.RequestHash)
+
+	// Keys are scoped to the customer
+	_, err = s.GetOrderByIdempotencyKey(ctx, 12, key)
+	assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+}
+
+func TestStore_GetHeldFundCodes(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	orders := []schema.Orders{
+		// Fully sold so no longer held
+		{OrderID: 1, OrderType: schema.Buy, CustomerID: 11, Name: "Fund A", Code: "A", Shares: 10, PurchasedValueGBP: 50, OrderTime: time.Now(), Status: schema.Executed},
+		{OrderID: 2, OrderType: schema.Sell, CustomerID: 11, Name: "Fund A", Code: "A", Shares: 10, PurchasedValueGBP: 50, OrderTime: time.Now(), Status: schema.Executed},
+		// Waiting to be bought
+		{OrderID: 3, OrderType: schema.Buy, CustomerID: 11, Name: "Fund B", Code: "B", PurchasedValueGBP: 50, OrderTime: time.Now(), Status: schema.Pending},
+		// Cancelled so never held
+		{OrderID: 4, OrderType: schema.Buy, CustomerID: 11, Name: "Fund C", Code: "C", PurchasedValueGBP: 50, OrderTime: time.Now(), Status: schema.Cancelled},
+	}
+
+	s := storage.NewStore(db)
+	err = db.Create(&orders).Error
+	assert.NoError(t, err)
+
+	codes, err := s.GetHeldFundCodes(ctx, 11)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"B"}, codes)
+}
+
+func TestStore_GetOrders(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	orders := []schema.Orders{
+		{OrderID: 1, OrderType: schema.Buy, CustomerID: 12, Name: "Fund A", Code: "A", PurchasedValueGBP: 50, OrderTime: time.Now(), Status: schema.Pending},
+		{OrderID: 2, OrderType: schema.Buy, CustomerID: 12, Name: "Fund A", Code: "A", Shares: 10, PurchasedValueGBP: 50, OrderTime: time.Now().AddDate(-1, 0, 0), Status: schema.Executed},
+		// Another customer's order
+		{OrderID: 3, OrderType: schema.Buy, CustomerID: 13, Name: "Fund A", Code: "A", PurchasedValueGBP: 50, OrderTime: time.Now(), Status: schema.Pending},
+	}
+
+	s := storage.NewStore(db)
+	err = db.Create(&orders).Error
+	assert.NoError(t, err)
+
+	got, err := s.GetOrders(ctx, 12)
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, uint(2), got[0].OrderID)
+	assert.Equal(t, uint(1), got[1].OrderID)
+}
+
+func TestStore_WithCustomerLockConcurrentBuyOrders(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	fund := schema.Funds{
+		ID:           1,
+		Name:         "ESG Global All Cap UCITS ETF",
+		Description:  "Some fund",
+		Code:         "V3AM",
+		CustomerType: schema.Retail,
+		RiskScore:    schema.Medium,
+	}
+	err = db.Create(&fund).Error
+	assert.NoError(t, err)
+	err = db.Create(&schema.FundPrices{FundID: 1, ValuationPoint: time.Now(), PriceGBP: 4.92}).Error
+	assert.NoError(t, err)
+
+	// The real service is used so that the allowance check and insert are exercised exactly as in production
+	s := storage.NewStore(db)
+	svc := service.NewService(s)
+
+	_, err = s.CreateCustomer(ctx, &schema.Customers{CustomerID: 11, CustomerType: schema.Retail, DateOfBirth: time.Date(1990, 6, 15, 0, 0, 0, 0, time.UTC), Residency: schema.UKResident, Status: schema.CustomerActive, CreatedAt: time.Now()})
+	assert.NoError(t, err)
+
+	// Customers without a record are refused before anything is locked
+	_, err = svc.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 12, Code: "V3AM", AmountGBP: 5000})
+	assert.ErrorIs(t, err, service.ErrCustomerNotFound)
+
+	// Ten simultaneous £5,000 orders against a £20,000 allowance, only four can ever be accepted
+	var wg sync.WaitGroup
+	results := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{CustomerID: 11, Code: "V3AM", AmountGBP: 5000})
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var accepted, rejected int
+	for err := range results {
+		if err == nil {
+			accepted++
+			continue
+		}
+		assert.ErrorIs(t, err, service.ErrISAAllowanceExceeded)
+		rejected++
+	}
+	assert.Equal(t, 4, accepted)
+	assert.Equal(t, 6, rejected)
+
+	spent, err := s.GetCurrentTaxYearOrders(ctx, 11)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(20000), sumAmounts(spent))
+}
+
+func TestStore_GetCurrentTaxYearOrdersRollsOver(t *testing.T) {
+	ctx := context.Background()
+	db, teardown, _ := setupDB(ctx)
+	defer teardown()
+
+	err := cleanDB(db)
+	assert.NoError(t, err)
+
+	orders := []schema.Orders{
+		{OrderID: 1, OrderType: schema.Buy, CustomerID: 11, Name: "Fund A", Code: "A", PurchasedValueGBP: 200, OrderTime: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{OrderID: 2, OrderType: schema.Buy, CustomerID: 11, Name: "Fund A", Code: "A", PurchasedValueGBP: 300, OrderTime: time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC)},
+	}
+	err = db.Create(&orders).Error
+	assert.NoError(t, err)
+
+	// In May only the current tax year is counted, not the one before it
+	s := storage.NewStore(db, storage.WithClock(clock.Fixed(time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC))))
+	spent, err := s.GetCurrentTaxYearOrders(ctx, 11)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(300), sumAmounts(spent))
+
+	s = storage.NewStore(db, storage.WithClock(clock.Fixed(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))))
+	spent, err = s.GetCurrentTaxYearOrders(ctx, 11)
 	assert.NoError(t, err)
-	assert.Equal(t, float64(0), allowance)
+	assert.Equal(t, float64(200), sumAmounts(spent))
 }
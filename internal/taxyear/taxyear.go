@@ -0,0 +1,63 @@
+package taxyear
+
+import (
+	"fmt"
+	"time"
+
+	// Embed the timezone database, London is loaded when the package initialises and not every host has one
+	_ "time/tzdata"
+)
+
+// London is the UK timezone, which the tax year boundaries are set in and funds are valued in
+var London = mustLoadLocation("Europe/London")
+
+// Year represents a UK tax year, which runs from 6 April to 5 April inclusive
+type Year struct {
+	// Start is midnight on 6 April in UK time
+	Start time.Time
+	// End is midnight on the following 6 April in UK time, so it is the first instant after the year
+	End time.Time
+}
+
+// For returns the tax year that t falls in
+func For(t time.Time) Year {
+	local := t.In(London)
+
+	startYear := local.Year()
+	if local.Before(time.Date(startYear, time.April, 6, 0, 0, 0, 0, London)) {
+		startYear--
+	}
+
+	return starting(startYear)
+}
+
+// starting returns the tax year beginning on 6 April of the given calendar year
+func starting(year int) Year {
+	return Year{
+		Start: time.Date(year, time.April, 6, 0, 0, 0, 0, London),
+		End:   time.Date(year+1, time.April, 6, 0, 0, 0, 0, London),
+	}
+}
+
+// Contains reports whether t falls within the tax year
+func (y Year) Contains(t time.Time) bool {
+	return !t.Before(y.Start) && t.Before(y.End)
+}
+
+// Next returns the tax year after this one
+func (y Year) Next() Year {
+	return starting(y.Start.Year() + 1)
+}
+
+// String returns the conventional label for the tax year, e.g. "2024/25"
+func (y Year) String() string {
+	return fmt.Sprintf("%d/%02d", y.Start.Year(), (y.Start.Year()+1)%100)
+}
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load location %s: %v", name, err))
+	}
+	return loc
+}
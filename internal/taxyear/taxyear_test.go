@@ -0,0 +1,49 @@
+package taxyear_test
+
+import (
+	"github.com/jautyw/isa-investment-funds/internal/taxyear"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFor(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		at            time.Time
+		expectedLabel string
+	}{
+		{name: "in May", at: time.Date(2025, 5, 10, 12, 0, 0, 0, time.UTC), expectedLabel: "2025/26"},
+		{name: "in January", at: time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC), expectedLabel: "2024/25"},
+		{name: "last moment of 5 April", at: time.Date(2025, 4, 5, 23, 59, 59, 0, london), expectedLabel: "2024/25"},
+		{name: "first moment of 6 April", at: time.Date(2025, 4, 6, 0, 0, 0, 0, london), expectedLabel: "2025/26"},
+		// 6 April is in BST so the year starts at 23:00 UTC on 5 April
+		{name: "6 April in UK time but 5 April in UTC", at: time.Date(2025, 4, 5, 23, 30, 0, 0, time.UTC), expectedLabel: "2025/26"},
+		{name: "turn of the century", at: time.Date(2099, 12, 1, 0, 0, 0, 0, time.UTC), expectedLabel: "2099/00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			year := taxyear.For(tt.at)
+			assert.Equal(t, tt.expectedLabel, year.String())
+			assert.True(t, year.Contains(tt.at))
+		})
+	}
+}
+
+func TestYear_Boundaries(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	assert.NoError(t, err)
+
+	year := taxyear.For(time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC))
+	assert.True(t, year.Start.Equal(time.Date(2025, 4, 6, 0, 0, 0, 0, london)))
+	assert.True(t, year.End.Equal(time.Date(2026, 4, 6, 0, 0, 0, 0, london)))
+	assert.False(t, year.Contains(year.End))
+	assert.True(t, year.Contains(year.Start))
+
+	assert.Equal(t, "2026/27", year.Next().String())
+	assert.True(t, year.Next().Start.Equal(year.End))
+}
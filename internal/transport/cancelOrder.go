@@ -0,0 +1,61 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("CancelOrder request made")
+
+	vars := mux.Vars(r)
+	customerID, exists := vars["customer_id"]
+	if !exists || customerID == "" {
+		h.Logger.Error("customer_id is missing")
+		http.Error(w, "customer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	customerIDint, err := strconv.Atoi(customerID)
+	if err != nil || customerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s customer_id is invalid", customerID))
+		http.Error(w, fmt.Sprintf("%s customer_id is invalid", customerID), http.StatusBadRequest)
+		return
+	}
+
+	orderID, exists := vars["order_id"]
+	if !exists || orderID == "" {
+		h.Logger.Error("order_id is missing")
+		http.Error(w, "order_id is required", http.StatusBadRequest)
+		return
+	}
+
+	orderIDuint, err := strconv.ParseUint(orderID, 10, 64)
+	if err != nil || orderIDuint == 0 {
+		h.Logger.Error(fmt.Sprintf("%s order_id is invalid", orderID))
+		http.Error(w, fmt.Sprintf("%s order_id is invalid", orderID), http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.Service.CancelOrder(ctx, customerIDint, uint(orderIDuint))
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrCancellingOrder).Error())
+		http.Error(w, errors.Wrap(err, ErrCancellingOrder).Error(), statusFromError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(toOrderResponse(order)); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrCancellingOrder).Error())
+		http.Error(w, errors.Wrap(err, ErrCancellingOrder).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("CancelOrder returned successfully")
+}
@@ -0,0 +1,79 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+// taxYearDateLayout is used for the first and last day of each tax year in the response
+const taxYearDateLayout = "2006-01-02"
+
+func (h *Handler) GetAllowanceHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("GetAllowanceHistory request made")
+
+	vars := mux.Vars(r)
+	customerID, exists := vars["customer_id"]
+	if !exists || customerID == "" {
+		h.Logger.Error("customer_id is missing")
+		http.Error(w, "customer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	customerIDint, err := strconv.Atoi(customerID)
+	if err != nil || customerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s customer_id is invalid", customerID))
+		http.Error(w, fmt.Sprintf("%s customer_id is invalid", customerID), http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.Service.GetAllowanceHistory(ctx, customerIDint)
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrGettingAllowanceHistory).Error())
+		http.Error(w, errors.Wrap(err, ErrGettingAllowanceHistory).Error(), statusFromError(err))
+		return
+	}
+
+	taxYears := make([]TaxYearAllowance, len(history.TaxYears))
+	for i, y := range history.TaxYears {
+		taxYears[i] = TaxYearAllowance{
+			TaxYear:               y.TaxYear,
+			StartDate:             y.Start.Format(taxYearDateLayout),
+			EndDate:               y.End.AddDate(0, 0, -1).Format(taxYearDateLayout),
+			SubscriptionsGBP:      y.SubscriptionsGBP,
+			WithdrawalsGBP:        y.WithdrawalsGBP,
+			ReplacedGBP:           y.ReplacedGBP,
+			TransferredInGBP:      y.TransferredInGBP,
+			RemainingAllowanceGBP: y.RemainingAllowanceGBP,
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(GetAllowanceHistoryResponse{TaxYears: taxYears}); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrGettingAllowanceHistory).Error())
+		http.Error(w, errors.Wrap(err, ErrGettingAllowanceHistory).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("GetAllowanceHistory returned successfully")
+}
+
+type GetAllowanceHistoryResponse struct {
+	TaxYears []TaxYearAllowance `json:"taxYears"`
+}
+
+type TaxYearAllowance struct {
+	TaxYear               string  `json:"taxYear"`
+	StartDate             string  `json:"startDate"`
+	EndDate               string  `json:"endDate"`
+	SubscriptionsGBP      float64 `json:"subscriptionsGBP"`
+	WithdrawalsGBP        float64 `json:"withdrawalsGBP"`
+	ReplacedGBP           float64 `json:"replacedGBP"`
+	TransferredInGBP      float64 `json:"transferredInGBP"`
+	RemainingAllowanceGBP float64 `json:"remainingAllowanceGBP"`
+}
@@ -2,12 +2,21 @@ package transport
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/gorilla/mux"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/service"
 	"github.com/pkg/errors"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 )
 
+// GetFunds lists a page of the funds offered to the customer, filtered by the riskScore, search, minPrice and maxPrice
+// query parameters and sorted by name, code or price in the asc or desc order asked for. The total number of funds
+// matching the filters is returned in the X-Total-Count header, and the cursor of the next page, if there is one, in
+// the X-Next-Cursor header.
 func (h *Handler) GetFunds(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	w.Header().Add("Content-Type", "application/json")
@@ -15,17 +24,31 @@ func (h *Handler) GetFunds(w http.ResponseWriter, r *http.Request) {
 	h.Logger.Info("GetFunds request made")
 
 	vars := mux.Vars(r)
-	customerType := vars["customer_type"]
-	if customerType == "" {
-		h.Logger.Error(customerType + "is empty")
-		http.Error(w, customerType+"is empty", http.StatusBadRequest)
+	customerID, exists := vars["customer_id"]
+	if !exists || customerID == "" {
+		h.Logger.Error("customer_id is missing")
+		http.Error(w, "customer_id is required", http.StatusBadRequest)
 		return
 	}
 
-	getFunds, err := h.Service.GetFunds(ctx, customerType)
+	customerIDint, err := strconv.Atoi(customerID)
+	if err != nil || customerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s customer_id is invalid", customerID))
+		http.Error(w, fmt.Sprintf("%s customer_id is invalid", customerID), http.StatusBadRequest)
+		return
+	}
+
+	query, err := fundQuery(r.URL.Query())
 	if err != nil {
 		h.Logger.Error(errors.Wrap(err, ErrGettingFunds).Error())
-		http.Error(w, errors.Wrap(err, ErrGettingFunds).Error(), http.StatusInternalServerError)
+		http.Error(w, errors.Wrap(err, ErrGettingFunds).Error(), http.StatusBadRequest)
+		return
+	}
+
+	getFunds, err := h.Service.GetFunds(ctx, customerIDint, query)
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrGettingFunds).Error())
+		http.Error(w, errors.Wrap(err, ErrGettingFunds).Error(), statusFromError(err))
 		return
 	}
 
@@ -33,12 +56,13 @@ func (h *Handler) GetFunds(w http.ResponseWriter, r *http.Request) {
 	funds := make([]Fund, len(getFunds.Funds))
 	for i, f := range getFunds.Funds {
 		funds[i] = Fund{
-			Name:        f.Name,
-			Description: f.Description,
-			Code:        f.Code,
-			AmountGBP:   f.AmountGBP,
-			RiskScore:   f.RiskScore,
-			LastUpdated: f.LastUpdated,
+			Name:                f.Name,
+			Description:         f.Description,
+			Code:                f.Code,
+			AmountGBP:           f.AmountGBP,
+			RiskScore:           f.RiskScore,
+			LastUpdated:         f.LastUpdated,
+			AnnualChargePercent: f.AnnualChargePercent,
 		}
 	}
 
@@ -51,6 +75,11 @@ func (h *Handler) GetFunds(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set(totalCountHeader, strconv.FormatInt(getFunds.Total, 10))
+	if getFunds.NextCursor != "" {
+		w.Header().Set(nextCursorHeader, getFunds.NextCursor)
+	}
+
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write(resBytes); err != nil {
 		h.Logger.Error(errors.Wrap(err, ErrGettingFunds).Error())
@@ -61,15 +90,68 @@ func (h *Handler) GetFunds(w http.ResponseWriter, r *http.Request) {
 	h.Logger.Info("GetFunds returned successfully")
 }
 
+// fundQuery reads the filters, sort and page of a fund listing from the query parameters.
+func fundQuery(params url.Values) (service.FundQuery, error) {
+	query := service.FundQuery{
+		RiskScore: schema.RiskScore(params.Get("riskScore")),
+		Search:    params.Get("search"),
+		SortBy:    service.FundSort(params.Get("sort")),
+		Cursor:    params.Get("cursor"),
+	}
+
+	var err error
+	if query.MinPriceGBP, err = priceParam(params, "minPrice"); err != nil {
+		return service.FundQuery{}, err
+	}
+	if query.MaxPriceGBP, err = priceParam(params, "maxPrice"); err != nil {
+		return service.FundQuery{}, err
+	}
+
+	switch order := params.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return service.FundQuery{}, errors.New(fmt.Sprintf("%s order is invalid, must be asc or desc", order))
+	}
+
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return service.FundQuery{}, errors.New(fmt.Sprintf("%s limit is invalid", value))
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+// priceParam reads a price from the query parameters, nil if it isn't given.
+func priceParam(params url.Values, param string) (*float64, error) {
+	value := params.Get(param)
+	if value == "" {
+		return nil, nil
+	}
+
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s %s is invalid", value, param))
+	}
+
+	return &price, nil
+}
+
 type GetFundsResponse struct {
 	Funds []Fund `json:"funds"`
 }
 
+// Fund only carries the annual charge for funds from an employer's workplace catalogue
 type Fund struct {
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Code        string    `json:"code"`
-	AmountGBP   float64   `json:"amountGBP"`
-	RiskScore   string    `json:"riskScore"`
-	LastUpdated time.Time `json:"lastUpdated"`
+	Name                string    `json:"name"`
+	Description         string    `json:"description"`
+	Code                string    `json:"code"`
+	AmountGBP           float64   `json:"amountGBP"`
+	RiskScore           string    `json:"riskScore"`
+	LastUpdated         time.Time `json:"lastUpdated"`
+	AnnualChargePercent *float64  `json:"annualChargePercent,omitempty"`
 }
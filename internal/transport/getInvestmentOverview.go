@@ -7,6 +7,7 @@ import (
 	"github.com/pkg/errors"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 func (h *Handler) GetInvestmentOverview(w http.ResponseWriter, r *http.Request) {
@@ -33,24 +34,65 @@ func (h *Handler) GetInvestmentOverview(w http.ResponseWriter, r *http.Request)
 	overview, err := h.Service.GetInvestmentOverview(ctx, customerIDint)
 	if err != nil {
 		h.Logger.Error(errors.Wrap(err, ErrGettingInvestmentOverview).Error())
-		http.Error(w, errors.Wrap(err, ErrGettingInvestmentOverview).Error(), http.StatusInternalServerError)
+		http.Error(w, errors.Wrap(err, ErrGettingInvestmentOverview).Error(), statusFromError(err))
 		return
 	}
 
 	investments := make([]Investment, len(overview.Investments))
 	for i, o := range overview.Investments {
 		investments[i] = Investment{
-			Name:          o.Name,
-			Description:   o.Description,
-			Code:          o.Code,
-			NetShares:     o.NetShares,
-			NetInvestment: o.NetInvestment,
+			Name:                  o.Name,
+			Description:           o.Description,
+			Code:                  o.Code,
+			ISAType:               string(o.ISAType),
+			NetShares:             o.NetShares,
+			NetInvestment:         o.NetInvestment,
+			BookCostGBP:           o.BookCostGBP,
+			AverageCostGBP:        o.AverageCostGBP,
+			RealisedGainGBP:       o.RealisedGainGBP,
+			PriceGBP:              o.PriceGBP,
+			CurrentValueGBP:       o.CurrentValueGBP,
+			UnrealisedGainGBP:     o.UnrealisedGainGBP,
+			UnrealisedGainPercent: o.UnrealisedGainPercent,
+		}
+	}
+
+	allowances := make([]ISAAllowance, len(overview.Allowances))
+	for i, a := range overview.Allowances {
+		allowances[i] = ISAAllowance{
+			ISAType:          string(a.ISAType),
+			SubscribedGBP:    a.SubscribedGBP,
+			WithdrawnGBP:     a.WithdrawnGBP,
+			ReplacedGBP:      a.ReplacedGBP,
+			TransferredInGBP: a.TransferredInGBP,
+			RemainingGBP:     a.RemainingGBP,
 		}
 	}
 
 	response := GetInvestmentOverviewResponse{
 		Investments:                investments,
+		TotalValueGBP:              overview.TotalValueGBP,
 		IsaAllowanceCurrentTaxYear: overview.IsaAllowanceCurrentTaxYear,
+		Allowances:                 allowances,
+	}
+
+	if lisa := overview.LifetimeISA; lisa != nil {
+		ledger := make([]LedgerEntry, len(lisa.Ledger))
+		for i, e := range lisa.Ledger {
+			ledger[i] = LedgerEntry{
+				OrderID:   e.OrderID,
+				EntryType: string(e.EntryType),
+				AmountGBP: e.AmountGBP,
+				CreatedAt: e.CreatedAt,
+			}
+		}
+
+		response.LifetimeISA = &LifetimeISASummary{
+			OpenedAt:             lisa.OpenedAt,
+			BonusGBP:             lisa.BonusGBP,
+			WithdrawalChargesGBP: lisa.WithdrawalChargesGBP,
+			Ledger:               ledger,
+		}
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -63,14 +105,49 @@ func (h *Handler) GetInvestmentOverview(w http.ResponseWriter, r *http.Request)
 }
 
 type GetInvestmentOverviewResponse struct {
-	Investments                []Investment `json:"investments"`
-	IsaAllowanceCurrentTaxYear float64      `json:"isaAllowanceCurrentTaxYear"`
+	Investments                []Investment   `json:"investments"`
+	TotalValueGBP              float64        `json:"totalValueGBP"`
+	IsaAllowanceCurrentTaxYear float64        `json:"isaAllowanceCurrentTaxYear"`
+	Allowances                 []ISAAllowance `json:"allowances"`
+	// LifetimeISA is left out for customers who haven't opened a lifetime ISA
+	LifetimeISA *LifetimeISASummary `json:"lifetimeISA,omitempty"`
 }
 
 type Investment struct {
-	Name          string  `json:"name"`
-	Description   string  `json:"description"`
-	Code          string  `json:"code"`
-	NetShares     float64 `json:"netShares"`
-	NetInvestment float64 `json:"netInvestment"`
+	Name                  string  `json:"name"`
+	Description           string  `json:"description"`
+	Code                  string  `json:"code"`
+	ISAType               string  `json:"isaType"`
+	NetShares             float64 `json:"netShares"`
+	NetInvestment         float64 `json:"netInvestment"`
+	BookCostGBP           float64 `json:"bookCostGBP"`
+	AverageCostGBP        float64 `json:"averageCostGBP"`
+	RealisedGainGBP       float64 `json:"realisedGainGBP"`
+	PriceGBP              float64 `json:"priceGBP"`
+	CurrentValueGBP       float64 `json:"currentValueGBP"`
+	UnrealisedGainGBP     float64 `json:"unrealisedGainGBP"`
+	UnrealisedGainPercent float64 `json:"unrealisedGainPercent"`
+}
+
+type ISAAllowance struct {
+	ISAType          string  `json:"isaType"`
+	SubscribedGBP    float64 `json:"subscribedGBP"`
+	WithdrawnGBP     float64 `json:"withdrawnGBP"`
+	ReplacedGBP      float64 `json:"replacedGBP"`
+	TransferredInGBP float64 `json:"transferredInGBP"`
+	RemainingGBP     float64 `json:"remainingGBP"`
+}
+
+type LifetimeISASummary struct {
+	OpenedAt             time.Time     `json:"openedAt"`
+	BonusGBP             float64       `json:"bonusGBP"`
+	WithdrawalChargesGBP float64       `json:"withdrawalChargesGBP"`
+	Ledger               []LedgerEntry `json:"ledger"`
+}
+
+type LedgerEntry struct {
+	OrderID   uint      `json:"orderId"`
+	EntryType string    `json:"entryType"`
+	AmountGBP float64   `json:"amountGBP"`
+	CreatedAt time.Time `json:"createdAt"`
 }
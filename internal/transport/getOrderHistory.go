@@ -0,0 +1,56 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) GetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("GetOrderHistory request made")
+
+	vars := mux.Vars(r)
+	customerID, exists := vars["customer_id"]
+	if !exists || customerID == "" {
+		h.Logger.Error("customer_id is missing")
+		http.Error(w, "customer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	customerIDint, err := strconv.Atoi(customerID)
+	if err != nil || customerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s customer_id is invalid", customerID))
+		http.Error(w, fmt.Sprintf("%s customer_id is invalid", customerID), http.StatusBadRequest)
+		return
+	}
+
+	orders, err := h.Service.GetOrderHistory(ctx, customerIDint)
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrGettingOrderHistory).Error())
+		http.Error(w, errors.Wrap(err, ErrGettingOrderHistory).Error(), statusFromError(err))
+		return
+	}
+
+	response := GetOrderHistoryResponse{Orders: make([]OrderResponse, len(orders))}
+	for i := range orders {
+		response.Orders[i] = toOrderResponse(&orders[i])
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrGettingOrderHistory).Error())
+		http.Error(w, errors.Wrap(err, ErrGettingOrderHistory).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("GetOrderHistory returned successfully")
+}
+
+type GetOrderHistoryResponse struct {
+	Orders []OrderResponse `json:"orders"`
+}
@@ -0,0 +1,126 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/jautyw/isa-investment-funds/internal/performance"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+// performanceDateLayout is used for the opening day and each day of the series in the response
+const performanceDateLayout = "2006-01-02"
+
+func (h *Handler) GetPerformance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("GetPerformance request made")
+
+	vars := mux.Vars(r)
+	customerID, exists := vars["customer_id"]
+	if !exists || customerID == "" {
+		h.Logger.Error("customer_id is missing")
+		http.Error(w, "customer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	customerIDint, err := strconv.Atoi(customerID)
+	if err != nil || customerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s customer_id is invalid", customerID))
+		http.Error(w, fmt.Sprintf("%s customer_id is invalid", customerID), http.StatusBadRequest)
+		return
+	}
+
+	// Performance is measured since inception unless a shorter period is asked for
+	period := performance.Period(r.URL.Query().Get("period"))
+	if period == "" {
+		period = performance.SinceInception
+	}
+
+	perf, err := h.Service.GetPerformance(ctx, customerIDint, period)
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrGettingPerformance).Error())
+		http.Error(w, errors.Wrap(err, ErrGettingPerformance).Error(), statusFromError(err))
+		return
+	}
+
+	response := GetPerformanceResponse{
+		Period:                     string(perf.Period),
+		OpeningDate:                perf.OpeningDate.Format(performanceDateLayout),
+		OpeningValueGBP:            perf.OpeningValueGBP,
+		ClosingValueGBP:            perf.ClosingValueGBP,
+		InvestedGBP:                perf.InvestedGBP,
+		WithdrawnGBP:               perf.WithdrawnGBP,
+		GainGBP:                    perf.GainGBP,
+		TimeWeightedReturnPercent:  perf.TimeWeightedReturnPercent,
+		MoneyWeightedReturnPercent: perf.MoneyWeightedReturnPercent,
+		BenchmarkReturnPercent:     perf.BenchmarkReturnPercent,
+		RelativeReturnPercent:      perf.RelativeReturnPercent,
+		Funds:                      make([]FundPerformance, len(perf.Funds)),
+		Series:                     make([]PerformancePoint, len(perf.Series)),
+	}
+	for i, f := range perf.Funds {
+		response.Funds[i] = FundPerformance{
+			Code:                   f.Code,
+			Name:                   f.Name,
+			ReturnPercent:          f.ReturnPercent,
+			BenchmarkCode:          f.BenchmarkCode,
+			BenchmarkName:          f.BenchmarkName,
+			BenchmarkReturnPercent: f.BenchmarkReturnPercent,
+			RelativeReturnPercent:  f.RelativeReturnPercent,
+		}
+	}
+	for i, p := range perf.Series {
+		response.Series[i] = PerformancePoint{
+			Date:         p.Date.Format(performanceDateLayout),
+			ValueGBP:     p.ValueGBP,
+			InvestedGBP:  p.InvestedGBP,
+			WithdrawnGBP: p.WithdrawnGBP,
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrGettingPerformance).Error())
+		http.Error(w, errors.Wrap(err, ErrGettingPerformance).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("GetPerformance returned successfully")
+}
+
+type GetPerformanceResponse struct {
+	Period                     string             `json:"period"`
+	OpeningDate                string             `json:"openingDate"`
+	OpeningValueGBP            float64            `json:"openingValueGBP"`
+	ClosingValueGBP            float64            `json:"closingValueGBP"`
+	InvestedGBP                float64            `json:"investedGBP"`
+	WithdrawnGBP               float64            `json:"withdrawnGBP"`
+	GainGBP                    float64            `json:"gainGBP"`
+	TimeWeightedReturnPercent  float64            `json:"timeWeightedReturnPercent"`
+	MoneyWeightedReturnPercent *float64           `json:"moneyWeightedReturnPercent,omitempty"`
+	BenchmarkReturnPercent     *float64           `json:"benchmarkReturnPercent,omitempty"`
+	RelativeReturnPercent      *float64           `json:"relativeReturnPercent,omitempty"`
+	Funds                      []FundPerformance  `json:"funds"`
+	Series                     []PerformancePoint `json:"series"`
+}
+
+// FundPerformance leaves out the benchmark when the fund doesn't have one
+type FundPerformance struct {
+	Code                   string   `json:"code"`
+	Name                   string   `json:"name"`
+	ReturnPercent          *float64 `json:"returnPercent,omitempty"`
+	BenchmarkCode          string   `json:"benchmarkCode,omitempty"`
+	BenchmarkName          string   `json:"benchmarkName,omitempty"`
+	BenchmarkReturnPercent *float64 `json:"benchmarkReturnPercent,omitempty"`
+	RelativeReturnPercent  *float64 `json:"relativeReturnPercent,omitempty"`
+}
+
+type PerformancePoint struct {
+	Date         string  `json:"date"`
+	ValueGBP     float64 `json:"valueGBP"`
+	InvestedGBP  float64 `json:"investedGBP"`
+	WithdrawnGBP float64 `json:"withdrawnGBP"`
+}
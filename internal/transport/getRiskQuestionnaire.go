@@ -0,0 +1,45 @@
+package transport
+
+import (
+	"encoding/json"
+	"github.com/jautyw/isa-investment-funds/internal/riskprofile"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// GetRiskQuestionnaire returns the questions a customer answers to be given a risk profile.
+func (h *Handler) GetRiskQuestionnaire(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("GetRiskQuestionnaire request made")
+
+	response := GetRiskQuestionnaireResponse{
+		Questions: make([]RiskQuestion, len(riskprofile.Questions)),
+	}
+	for i, q := range riskprofile.Questions {
+		response.Questions[i] = RiskQuestion{
+			ID:      q.ID,
+			Text:    q.Text,
+			Options: q.Options,
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrGettingRiskQuestionnaire).Error())
+		http.Error(w, errors.Wrap(err, ErrGettingRiskQuestionnaire).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("GetRiskQuestionnaire returned successfully")
+}
+
+type GetRiskQuestionnaireResponse struct {
+	Questions []RiskQuestion `json:"questions"`
+}
+
+// RiskQuestion is answered with the number of the chosen option, counting from 1
+type RiskQuestion struct {
+	ID      string   `json:"id"`
+	Text    string   `json:"text"`
+	Options []string `json:"options"`
+}
@@ -0,0 +1,56 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) GetTransfers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("GetTransfers request made")
+
+	vars := mux.Vars(r)
+	customerID, exists := vars["customer_id"]
+	if !exists || customerID == "" {
+		h.Logger.Error("customer_id is missing")
+		http.Error(w, "customer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	customerIDint, err := strconv.Atoi(customerID)
+	if err != nil || customerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s customer_id is invalid", customerID))
+		http.Error(w, fmt.Sprintf("%s customer_id is invalid", customerID), http.StatusBadRequest)
+		return
+	}
+
+	transfers, err := h.Service.GetTransfers(ctx, customerIDint)
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrGettingTransfers).Error())
+		http.Error(w, errors.Wrap(err, ErrGettingTransfers).Error(), statusFromError(err))
+		return
+	}
+
+	response := GetTransfersResponse{Transfers: make([]TransferResponse, len(transfers))}
+	for i := range transfers {
+		response.Transfers[i] = toTransferResponse(&transfers[i])
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrGettingTransfers).Error())
+		http.Error(w, errors.Wrap(err, ErrGettingTransfers).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("GetTransfers returned successfully")
+}
+
+type GetTransfersResponse struct {
+	Transfers []TransferResponse `json:"transfers"`
+}
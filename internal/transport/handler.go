@@ -3,41 +3,187 @@ package transport
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
 	"github.com/gorilla/mux"
+	"github.com/jautyw/isa-investment-funds/internal/payroll"
+	"github.com/jautyw/isa-investment-funds/internal/performance"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
 	"github.com/jautyw/isa-investment-funds/internal/service"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"io"
 	"log"
 	"net/http"
 )
 
 // Handler represents a class that communicates with the service layer
 type Handler struct {
-	Service Service
-	Logger  *zap.Logger
+	Service    Service
+	Logger     *zap.Logger
+	adminToken string
+}
+
+// Option configures optional behaviour of the Handler
+type Option func(*Handler)
+
+// WithAdminToken sets the token operators send in the Admin-Token header to use the admin endpoints. Without one the
+// admin endpoints refuse every request.
+func WithAdminToken(token string) Option {
+	return func(h *Handler) {
+		h.adminToken = token
+	}
 }
 
 // NewHandler will instantiate a new instance of Service
-func NewHandler(s Service, l *zap.Logger) *Handler {
-	return &Handler{
+func NewHandler(s Service, l *zap.Logger, opts ...Option) *Handler {
+	h := &Handler{
 		Service: s,
 		Logger:  l,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // Service represents a type that can be used to call the service
 type Service interface {
-	GetFunds(ctx context.Context, customerType string) (*service.Funds, error)
+	GetFunds(ctx context.Context, customerID int, query service.FundQuery) (*service.Funds, error)
 	GetInvestmentOverview(ctx context.Context, customerID int) (*service.Overview, error)
+	PlaceBuyOrder(ctx context.Context, req service.PlaceBuyOrderRequest) (*service.Order, error)
+	PlaceSellOrder(ctx context.Context, req service.PlaceSellOrderRequest) (*service.Order, error)
+	CancelOrder(ctx context.Context, customerID int, orderID uint) (*service.Order, error)
+	GetAllowanceHistory(ctx context.Context, customerID int) (*service.AllowanceHistory, error)
+	OpenLifetimeISA(ctx context.Context, customerID int) (*service.LifetimeISA, error)
+	RequestTransfer(ctx context.Context, req service.RequestTransferRequest) (*service.Transfer, error)
+	GetTransfers(ctx context.Context, customerID int) ([]service.Transfer, error)
+	UpdateTransferStatus(ctx context.Context, customerID int, transferID uint, status schema.TransferStatus) (*service.Transfer, error)
+	GetOrderHistory(ctx context.Context, customerID int) ([]service.Order, error)
+	GetPerformance(ctx context.Context, customerID int, period performance.Period) (*service.Performance, error)
+	RegisterBenchmark(ctx context.Context, req service.RegisterBenchmarkRequest) (*service.Benchmark, error)
+	SetFundBenchmark(ctx context.Context, fundCode string, benchmarkCode string) error
+	ImportPayroll(ctx context.Context, employerID int, file io.Reader) (*payroll.Report, error)
+	SubmitRiskQuestionnaire(ctx context.Context, customerID int, answers map[string]int) (*service.RiskProfile, error)
 }
 
 // HandleRequests refers to a collection of endpoints within the service
 func (h *Handler) HandleRequests(m *mux.Router) {
-	m.HandleFunc("/getFunds/{customer_type}", h.GetFunds).Methods(http.MethodGet)
-	m.HandleFunc("/getInvestmentOverview/{customer_id}", h.GetInvestmentOverview).Methods(http.MethodGet)
+	h.Routes(m)
 	log.Fatal(http.ListenAndServe(":8080", m))
 }
 
+// Routes registers the endpoints on the router. Endpoints for operators, which move transfers along, manage
+// benchmarks and load payroll files, sit under /admin and are only served to requests carrying the admin token.
+func (h *Handler) Routes(m *mux.Router) {
+	m.HandleFunc("/getFunds/{customer_id}", h.GetFunds).Methods(http.MethodGet)
+	m.HandleFunc("/getInvestmentOverview/{customer_id}", h.GetInvestmentOverview).Methods(http.MethodGet)
+	m.HandleFunc("/placeBuyOrder/{customer_id}", h.PlaceBuyOrder).Methods(http.MethodPost)
+	m.HandleFunc("/placeSellOrder/{customer_id}", h.PlaceSellOrder).Methods(http.MethodPost)
+	m.HandleFunc("/cancelOrder/{customer_id}/{order_id}", h.CancelOrder).Methods(http.MethodPost)
+	m.HandleFunc("/getAllowanceHistory/{customer_id}", h.GetAllowanceHistory).Methods(http.MethodGet)
+	m.HandleFunc("/openLifetimeISA/{customer_id}", h.OpenLifetimeISA).Methods(http.MethodPost)
+	m.HandleFunc("/requestTransfer/{customer_id}", h.RequestTransfer).Methods(http.MethodPost)
+	m.HandleFunc("/getTransfers/{customer_id}", h.GetTransfers).Methods(http.MethodGet)
+	m.HandleFunc("/getOrderHistory/{customer_id}", h.GetOrderHistory).Methods(http.MethodGet)
+	m.HandleFunc("/getPerformance/{customer_id}", h.GetPerformance).Methods(http.MethodGet)
+	m.HandleFunc("/getRiskQuestionnaire", h.GetRiskQuestionnaire).Methods(http.MethodGet)
+	m.HandleFunc("/submitRiskQuestionnaire/{customer_id}", h.SubmitRiskQuestionnaire).Methods(http.MethodPost)
+
+	admin := m.PathPrefix("/admin").Subrouter()
+	admin.Use(h.requireAdmin)
+	admin.HandleFunc("/updateTransferStatus/{customer_id}/{transfer_id}", h.UpdateTransferStatus).Methods(http.MethodPost)
+	admin.HandleFunc("/registerBenchmark", h.RegisterBenchmark).Methods(http.MethodPost)
+	admin.HandleFunc("/setFundBenchmark/{code}", h.SetFundBenchmark).Methods(http.MethodPost)
+	admin.HandleFunc("/importPayroll/{employer_id}", h.ImportPayroll).Methods(http.MethodPost)
+}
+
+// requireAdmin refuses requests that don't carry the admin token, so that customers can't reach the admin endpoints.
+func (h *Handler) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(adminTokenHeader)
+		if h.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			h.Logger.Error(ErrAdminUnauthorised)
+			http.Error(w, ErrAdminUnauthorised, http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 const (
 	ErrGettingFunds              = "/getFunds error"
 	ErrGettingInvestmentOverview = "/getInvestmentOverview error"
+	ErrPlacingBuyOrder           = "/placeBuyOrder error"
+	ErrPlacingSellOrder          = "/placeSellOrder error"
+	ErrCancellingOrder           = "/cancelOrder error"
+	ErrGettingAllowanceHistory   = "/getAllowanceHistory error"
+	ErrOpeningLifetimeISA        = "/openLifetimeISA error"
+	ErrRequestingTransfer        = "/requestTransfer error"
+	ErrGettingTransfers          = "/getTransfers error"
+	ErrUpdatingTransferStatus    = "/updateTransferStatus error"
+	ErrGettingOrderHistory       = "/getOrderHistory error"
+	ErrGettingPerformance        = "/getPerformance error"
+	ErrRegisteringBenchmark      = "/registerBenchmark error"
+	ErrSettingFundBenchmark      = "/setFundBenchmark error"
+	ErrImportingPayroll          = "/importPayroll error"
+	ErrGettingRiskQuestionnaire  = "/getRiskQuestionnaire error"
+	ErrSubmittingQuestionnaire   = "/submitRiskQuestionnaire error"
+	ErrAdminUnauthorised         = "admin token is missing or invalid"
+
+	// adminTokenHeader carries the token that authorises requests to the admin endpoints
+	adminTokenHeader = "Admin-Token"
+
+	// idempotencyKeyHeader lets clients safely retry order submissions
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader is set on responses that replay an order created by an earlier request
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+
+	// totalCountHeader and nextCursorHeader page a listing while leaving its body as the list
+	totalCountHeader = "X-Total-Count"
+	nextCursorHeader = "X-Next-Cursor"
 )
+
+// statusFromError maps the errors returned by the service onto the HTTP status reported to the client.
+func statusFromError(err error) int {
+	var multipleProductsErr *service.MultipleProductsError
+
+	switch {
+	case errors.Is(err, service.ErrInvalidOrderAmount), errors.Is(err, service.ErrInvalidISAType),
+		errors.Is(err, service.ErrInvalidWithdrawalReason),
+		errors.Is(err, service.ErrInvalidTransfer), errors.Is(err, service.ErrInvalidPeriod),
+		errors.Is(err, service.ErrInvalidBenchmark), errors.Is(err, service.ErrInvalidPayrollFile),
+		errors.Is(err, service.ErrInvalidRiskAnswers), errors.Is(err, service.ErrInvalidFundQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrCustomerNotFound), errors.Is(err, service.ErrFundNotFound),
+		errors.Is(err, service.ErrOrderNotFound), errors.Is(err, service.ErrTransferNotFound),
+		errors.Is(err, service.ErrBenchmarkNotFound), errors.Is(err, service.ErrEmployerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrOrderNotCancellable), errors.Is(err, service.ErrLifetimeISAAlreadyOpen),
+		errors.Is(err, service.ErrTransferStatusConflict):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrISAAllowanceExceeded), errors.Is(err, service.ErrInsufficientShares),
+		errors.Is(err, service.ErrIdempotencyKeyReused), errors.Is(err, service.ErrLifetimeISANotOpen),
+		errors.Is(err, service.ErrLifetimeISAAgeIneligible), errors.Is(err, service.ErrCustomerNotEligible),
+		errors.Is(err, service.ErrCustomerTypeNotOffered), errors.Is(err, service.ErrFundUnsuitable),
+		errors.As(err, &multipleProductsErr):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// requestHash fingerprints a decoded request body so that a retry matches the original regardless of formatting.
+func requestHash(request interface{}) (string, error) {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -1,9 +1,13 @@
 package transport_test
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
+	"github.com/jautyw/isa-investment-funds/internal/payroll"
+	"github.com/jautyw/isa-investment-funds/internal/performance"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
 	"github.com/jautyw/isa-investment-funds/internal/service"
 	"github.com/jautyw/isa-investment-funds/internal/transport"
 	mocks "github.com/jautyw/isa-investment-funds/internal/transport/mocks"
@@ -13,6 +17,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -26,6 +31,62 @@ func TestHandler_NewHandler(t *testing.T) {
 	assert.NotNil(t, h)
 }
 
+func TestHandler_AdminRoutes(t *testing.T) {
+	tests := map[string]struct {
+		token  string
+		status int
+	}{
+		"no token":    {status: http.StatusUnauthorized},
+		"wrong token": {token: "guess", status: http.StatusUnauthorized},
+		"admin token": {token: "secret", status: http.StatusOK},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ms := mocks.NewMockService(ctrl)
+			h := transport.NewHandler(ms, zap.NewNop(), transport.WithAdminToken("secret"))
+			m := mux.NewRouter()
+			h.Routes(m)
+
+			if tt.status == http.StatusOK {
+				ms.EXPECT().UpdateTransferStatus(gomock.Any(), 10000, uint(1), schema.TransferCompleted).Return(&service.Transfer{TransferID: 1, Status: schema.TransferCompleted}, nil).Times(1)
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/admin/updateTransferStatus/10000/1", strings.NewReader(`{"status":"completed"}`))
+			if tt.token != "" {
+				r.Header.Set("Admin-Token", tt.token)
+			}
+
+			m.ServeHTTP(w, r)
+			assert.Equal(t, tt.status, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestHandler_AdminRoutesNotPublic(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Without an admin token configured the admin endpoints refuse everyone, and they aren't served outside /admin
+	h := transport.NewHandler(mocks.NewMockService(ctrl), zap.NewNop())
+	m := mux.NewRouter()
+	h.Routes(m)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/admin/importPayroll/1", strings.NewReader(""))
+	m.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/updateTransferStatus/10000/1", strings.NewReader(`{"status":"completed"}`))
+	m.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+}
+
 func TestHandler_GetFunds(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -74,11 +135,11 @@ func TestHandler_GetFunds(t *testing.T) {
 		},
 	}
 
-	ms.EXPECT().GetFunds(gomock.Any(), "retail").Return(expectedFunds, nil).Times(1)
+	ms.EXPECT().GetFunds(gomock.Any(), 10000, service.FundQuery{}).Return(expectedFunds, nil).Times(1)
 
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/getFunds/retail", nil)
-	r = mux.SetURLVars(r, map[string]string{"customer_type": "retail"})
+	r := httptest.NewRequest(http.MethodGet, "/getFunds/10000", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
 
 	h.GetFunds(w, r)
 	res := w.Result()
@@ -94,6 +155,69 @@ func TestHandler_GetFunds(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestHandler_GetFundsQuery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	h := transport.NewHandler(ms, zap.NewNop())
+
+	minPrice, maxPrice := 1.5, 10.0
+	ms.EXPECT().GetFunds(gomock.Any(), 10000, service.FundQuery{
+		RiskScore:   schema.Medium,
+		Search:      "esg",
+		MinPriceGBP: &minPrice,
+		MaxPriceGBP: &maxPrice,
+		SortBy:      service.SortFundsByPrice,
+		Descending:  true,
+		Cursor:      "abc",
+		Limit:       1,
+	}).Return(&service.Funds{Funds: []service.Fund{{Code: "V3AM"}}, Total: 2, NextCursor: "def"}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/getFunds/10000?riskScore=medium&search=esg&minPrice=1.5&maxPrice=10&sort=price&order=desc&cursor=abc&limit=1", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.GetFunds(w, r)
+	res := w.Result()
+
+	var response transport.GetFundsResponse
+	err := json.NewDecoder(res.Body).Decode(&response.Funds)
+	assert.NoError(t, err)
+	assert.Equal(t, []transport.Fund{{Code: "V3AM"}}, response.Funds)
+	assert.Equal(t, "2", res.Header.Get("X-Total-Count"))
+	assert.Equal(t, "def", res.Header.Get("X-Next-Cursor"))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_GetFundsInvalidQuery(t *testing.T) {
+	tests := map[string]string{
+		"price isn't a number": "minPrice=cheap",
+		"unknown order":        "order=up",
+		"limit isn't a number": "limit=all",
+	}
+
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ms := mocks.NewMockService(ctrl)
+			h := transport.NewHandler(ms, zap.NewNop())
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/getFunds/10000?"+query, nil)
+			r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+			h.GetFunds(w, r)
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		})
+	}
+}
+
 func TestHandler_GetFundsInternalServerError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -103,13 +227,13 @@ func TestHandler_GetFundsInternalServerError(t *testing.T) {
 	h := transport.NewHandler(ms, l)
 
 	expectedErrorMsg := transport.ErrGettingFunds
-	ms.EXPECT().GetFunds(gomock.Any(), "retail").Return(&service.Funds{
+	ms.EXPECT().GetFunds(gomock.Any(), 10000, gomock.Any()).Return(&service.Funds{
 		Funds: []service.Fund{{}},
 	}, errors.New(expectedErrorMsg)).Times(1)
 
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/getFunds/retail", nil)
-	r = mux.SetURLVars(r, map[string]string{"customer_type": "retail"})
+	r := httptest.NewRequest(http.MethodGet, "/getFunds/10000", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
 
 	h.GetFunds(w, r)
 	res := w.Result()
@@ -125,6 +249,52 @@ func TestHandler_GetFundsInternalServerError(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestHandler_GetFundsCustomerErrors(t *testing.T) {
+	tests := map[string]struct {
+		err    error
+		status int
+	}{
+		"unknown customer":     {err: service.ErrCustomerNotFound, status: http.StatusNotFound},
+		"customer not offered": {err: service.ErrCustomerTypeNotOffered, status: http.StatusUnprocessableEntity},
+		"invalid query":        {err: service.ErrInvalidFundQuery, status: http.StatusBadRequest},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ms := mocks.NewMockService(ctrl)
+			h := transport.NewHandler(ms, zap.NewNop())
+
+			ms.EXPECT().GetFunds(gomock.Any(), 404, gomock.Any()).Return(nil, tt.err).Times(1)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/getFunds/404", nil)
+			r = mux.SetURLVars(r, map[string]string{"customer_id": "404"})
+
+			h.GetFunds(w, r)
+			assert.Equal(t, tt.status, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestHandler_GetFundsBadRequestError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	h := transport.NewHandler(ms, zap.NewNop())
+
+	// Funds used to be looked up by customer type, which is now taken from the customer's record
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/getFunds/retail", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "retail"})
+
+	h.GetFunds(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
 func TestHandler_GetInvestmentOverview(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -136,11 +306,18 @@ func TestHandler_GetInvestmentOverview(t *testing.T) {
 	expectedFunds := &service.Overview{
 		Investments: []service.InvestmentSummary{
 			{
-				Name:          "ESG Global All Cap UCITS ETF",
-				Description:   "Some desc",
-				Code:          "V3AM",
-				NetShares:     5,
-				NetInvestment: 24.6,
+				Name:                  "ESG Global All Cap UCITS ETF",
+				Description:           "Some desc",
+				Code:                  "V3AM",
+				ISAType:               schema.StocksAndShares,
+				NetShares:             5,
+				NetInvestment:         24.6,
+				BookCostGBP:           24.6,
+				AverageCostGBP:        4.92,
+				PriceGBP:              5.5,
+				CurrentValueGBP:       27.5,
+				UnrealisedGainGBP:     2.9,
+				UnrealisedGainPercent: 11.79,
 			},
 			{
 				Name:          "ESG Global All Cap UCITS ETF - (USD) Accumulating",
@@ -150,17 +327,29 @@ func TestHandler_GetInvestmentOverview(t *testing.T) {
 				NetInvestment: 49.2,
 			},
 		},
+		TotalValueGBP:              27.5,
 		IsaAllowanceCurrentTaxYear: 19926.2,
+		Allowances: []service.ISAAllowance{
+			{ISAType: schema.StocksAndShares, SubscribedGBP: 1073.8, WithdrawnGBP: 1000, ReplacedGBP: 1000, RemainingGBP: 19926.2},
+			{ISAType: schema.Lifetime, RemainingGBP: 4000},
+		},
 	}
 
 	transportInvestments := transport.GetInvestmentOverviewResponse{
 		Investments: []transport.Investment{
 			{
-				Name:          "ESG Global All Cap UCITS ETF",
-				Description:   "Some desc",
-				Code:          "V3AM",
-				NetShares:     5,
-				NetInvestment: 24.6,
+				Name:                  "ESG Global All Cap UCITS ETF",
+				Description:           "Some desc",
+				Code:                  "V3AM",
+				ISAType:               "stocks_and_shares",
+				NetShares:             5,
+				NetInvestment:         24.6,
+				BookCostGBP:           24.6,
+				AverageCostGBP:        4.92,
+				PriceGBP:              5.5,
+				CurrentValueGBP:       27.5,
+				UnrealisedGainGBP:     2.9,
+				UnrealisedGainPercent: 11.79,
 			},
 			{
 				Name:          "ESG Global All Cap UCITS ETF - (USD) Accumulating",
@@ -170,7 +359,12 @@ func TestHandler_GetInvestmentOverview(t *testing.T) {
 				NetInvestment: 49.2,
 			},
 		},
+		TotalValueGBP:              27.5,
 		IsaAllowanceCurrentTaxYear: 19926.2,
+		Allowances: []transport.ISAAllowance{
+			{ISAType: "stocks_and_shares", SubscribedGBP: 1073.8, WithdrawnGBP: 1000, ReplacedGBP: 1000, RemainingGBP: 19926.2},
+			{ISAType: "lifetime", RemainingGBP: 4000},
+		},
 	}
 
 	ms.EXPECT().GetInvestmentOverview(gomock.Any(), 10000).Return(expectedFunds, nil).Times(1)
@@ -246,3 +440,1183 @@ func TestHandler_GetInvestmentOverviewInternalServerError(t *testing.T) {
 	err = res.Body.Close()
 	assert.NoError(t, err)
 }
+
+func TestHandler_GetInvestmentOverviewNotFoundError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	h := transport.NewHandler(ms, zap.NewNop())
+
+	ms.EXPECT().GetInvestmentOverview(gomock.Any(), 404).Return(nil, service.ErrCustomerNotFound).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/getInvestmentOverview/404", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "404"})
+
+	h.GetInvestmentOverview(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+}
+
+func TestHandler_PlaceBuyOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	serviceOrder := &service.Order{
+		OrderID:         7,
+		CustomerID:      10000,
+		OrderType:       "buy",
+		Name:            "ESG Global All Cap UCITS ETF",
+		Code:            "V3AM",
+		PurchaseTime:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		SharesPurchased: 100,
+		AmountGBP:       492,
+	}
+
+	expectedResponse := transport.OrderResponse{
+		OrderID:         7,
+		CustomerID:      10000,
+		OrderType:       "buy",
+		Name:            "ESG Global All Cap UCITS ETF",
+		Code:            "V3AM",
+		PurchaseTime:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		SharesPurchased: 100,
+		AmountGBP:       492,
+	}
+
+	ms.EXPECT().PlaceBuyOrder(gomock.Any(), service.PlaceBuyOrderRequest{
+		CustomerID: 10000,
+		Code:       "V3AM",
+		AmountGBP:  492,
+	}).Return(serviceOrder, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/placeBuyOrder/10000", strings.NewReader(`{"code":"V3AM","amountGBP":492}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.PlaceBuyOrder(w, r)
+	res := w.Result()
+
+	var response transport.OrderResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedResponse, response)
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_PlaceBuyOrderBadRequestError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/placeBuyOrder/10000", strings.NewReader(`{"amountGBP":492}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.PlaceBuyOrder(w, r)
+	res := w.Result()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	actualResponse := string(bodyBytes)
+	assert.Contains(t, actualResponse, "code is required")
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_PlaceBuyOrderInvalidISATypeError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().PlaceBuyOrder(gomock.Any(), service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AM", ISAType: "pension", AmountGBP: 492}).Return(nil, errors.Wrap(service.ErrInvalidISAType, service.ErrPlacingBuyOrder)).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/placeBuyOrder/10000", strings.NewReader(`{"code":"V3AM","isaType":"pension","amountGBP":492}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.PlaceBuyOrder(w, r)
+	res := w.Result()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	actualResponse := string(bodyBytes)
+	assert.Contains(t, actualResponse, "invalid ISA type")
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_PlaceBuyOrderAllowanceExceededError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().PlaceBuyOrder(gomock.Any(), gomock.Any()).Return(nil, errors.Wrap(service.ErrISAAllowanceExceeded, service.ErrPlacingBuyOrder)).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/placeBuyOrder/10000", strings.NewReader(`{"code":"V3AM","amountGBP":25000}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.PlaceBuyOrder(w, r)
+	res := w.Result()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	actualResponse := string(bodyBytes)
+	assert.Contains(t, actualResponse, transport.ErrPlacingBuyOrder)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_PlaceSellOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().PlaceSellOrder(gomock.Any(), service.PlaceSellOrderRequest{
+		CustomerID: 10000,
+		Code:       "V3AM",
+		Shares:     20,
+	}).Return(&service.Order{OrderID: 8, CustomerID: 10000, OrderType: "sell", Code: "V3AM", SharesPurchased: 20, AmountGBP: 98.4}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/placeSellOrder/10000", strings.NewReader(`{"code":"V3AM","shares":20}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.PlaceSellOrder(w, r)
+	res := w.Result()
+
+	var response transport.OrderResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.OrderResponse{OrderID: 8, CustomerID: 10000, OrderType: "sell", Code: "V3AM", SharesPurchased: 20, AmountGBP: 98.4}, response)
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_PlaceSellOrderInsufficientSharesError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().PlaceSellOrder(gomock.Any(), gomock.Any()).Return(nil, errors.Wrap(service.ErrInsufficientShares, service.ErrPlacingSellOrder)).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/placeSellOrder/10000", strings.NewReader(`{"code":"V3AM","shares":1000}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.PlaceSellOrder(w, r)
+	res := w.Result()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	actualResponse := string(bodyBytes)
+	assert.Contains(t, actualResponse, transport.ErrPlacingSellOrder)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_CancelOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().CancelOrder(gomock.Any(), 10000, uint(7)).Return(&service.Order{OrderID: 7, CustomerID: 10000, OrderType: "buy", Status: "cancelled"}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/cancelOrder/10000/7", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000", "order_id": "7"})
+
+	h.CancelOrder(w, r)
+	res := w.Result()
+
+	var response transport.OrderResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.OrderResponse{OrderID: 7, CustomerID: 10000, OrderType: "buy", Status: "cancelled"}, response)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_CancelOrderConflictError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().CancelOrder(gomock.Any(), 10000, uint(7)).Return(nil, errors.Wrap(service.ErrOrderNotCancellable, service.ErrCancellingOrder)).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/cancelOrder/10000/7", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000", "order_id": "7"})
+
+	h.CancelOrder(w, r)
+	res := w.Result()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	actualResponse := string(bodyBytes)
+	assert.Contains(t, actualResponse, "can't be cancelled")
+	assert.Equal(t, http.StatusConflict, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_CancelOrderBadRequestError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/cancelOrder/10000/hi", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000", "order_id": "hi"})
+
+	h.CancelOrder(w, r)
+	res := w.Result()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	actualResponse := string(bodyBytes)
+	assert.Contains(t, actualResponse, "order_id is invalid")
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_PlaceBuyOrderIdempotentReplay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	var hashes []string
+	ms.EXPECT().PlaceBuyOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, req service.PlaceBuyOrderRequest) (*service.Order, error) {
+		assert.Equal(t, "retry-123", req.IdempotencyKey)
+		hashes = append(hashes, req.RequestHash)
+		return &service.Order{OrderID: 7, CustomerID: 10000, OrderType: "buy", Code: "V3AM", AmountGBP: 492, Replayed: len(hashes) > 1}, nil
+	}).Times(2)
+
+	// The same request body formatted differently must produce the same fingerprint
+	for i, body := range []string{`{"code":"V3AM","amountGBP":492}`, `{ "amountGBP": 492, "code": "V3AM" }`} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/placeBuyOrder/10000", strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", "retry-123")
+		r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+		h.PlaceBuyOrder(w, r)
+		res := w.Result()
+
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		if i == 1 {
+			assert.Equal(t, "true", res.Header.Get("Idempotent-Replayed"))
+		} else {
+			assert.Empty(t, res.Header.Get("Idempotent-Replayed"))
+		}
+
+		err := res.Body.Close()
+		assert.NoError(t, err)
+	}
+
+	assert.Len(t, hashes, 2)
+	assert.NotEmpty(t, hashes[0])
+	assert.Equal(t, hashes[0], hashes[1])
+}
+
+func TestHandler_PlaceBuyOrderIdempotencyKeyReusedError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().PlaceBuyOrder(gomock.Any(), gomock.Any()).Return(nil, errors.Wrap(service.ErrIdempotencyKeyReused, service.ErrPlacingBuyOrder)).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/placeBuyOrder/10000", strings.NewReader(`{"code":"V3AM","amountGBP":100}`))
+	r.Header.Set("Idempotency-Key", "retry-123")
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.PlaceBuyOrder(w, r)
+	res := w.Result()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	actualResponse := string(bodyBytes)
+	assert.Contains(t, actualResponse, "idempotency key has already been used")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_PlaceBuyOrderMultipleProductsError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().PlaceBuyOrder(gomock.Any(), gomock.Any()).Return(nil, errors.Wrap(&service.MultipleProductsError{HeldCode: "V3AM", RequestedCode: "V3AB"}, service.ErrPlacingBuyOrder)).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/placeBuyOrder/10000", strings.NewReader(`{"code":"V3AB","amountGBP":100}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.PlaceBuyOrder(w, r)
+	res := w.Result()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	actualResponse := string(bodyBytes)
+	assert.Contains(t, actualResponse, "only a single product is allowed")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_GetAllowanceHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	london, err := time.LoadLocation("Europe/London")
+	assert.NoError(t, err)
+
+	ms.EXPECT().GetAllowanceHistory(gomock.Any(), 10000).Return(&service.AllowanceHistory{
+		TaxYears: []service.TaxYearAllowance{{
+			TaxYear:               "2024/25",
+			Start:                 time.Date(2024, 4, 6, 0, 0, 0, 0, london),
+			End:                   time.Date(2025, 4, 6, 0, 0, 0, 0, london),
+			SubscriptionsGBP:      5000,
+			WithdrawalsGBP:        1000,
+			ReplacedGBP:           500,
+			RemainingAllowanceGBP: 15500,
+		}},
+	}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/getAllowanceHistory/10000", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.GetAllowanceHistory(w, r)
+	res := w.Result()
+
+	var response transport.GetAllowanceHistoryResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.GetAllowanceHistoryResponse{TaxYears: []transport.TaxYearAllowance{{
+		TaxYear:               "2024/25",
+		StartDate:             "2024-04-06",
+		EndDate:               "2025-04-05",
+		SubscriptionsGBP:      5000,
+		WithdrawalsGBP:        1000,
+		ReplacedGBP:           500,
+		RemainingAllowanceGBP: 15500,
+	}}}, response)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_GetAllowanceHistoryInternalServerError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().GetAllowanceHistory(gomock.Any(), 10000).Return(nil, errors.New(service.ErrGettingHistory)).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/getAllowanceHistory/10000", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.GetAllowanceHistory(w, r)
+	res := w.Result()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	actualResponse := string(bodyBytes)
+	assert.Contains(t, actualResponse, service.ErrGettingHistory)
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_OpenLifetimeISA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	dateOfBirth := time.Date(1995, 6, 1, 0, 0, 0, 0, time.UTC)
+	openedAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	ms.EXPECT().OpenLifetimeISA(gomock.Any(), 10000).Return(&service.LifetimeISA{CustomerID: 10000, DateOfBirth: dateOfBirth, OpenedAt: openedAt}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/openLifetimeISA/10000", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.OpenLifetimeISA(w, r)
+	res := w.Result()
+
+	var response transport.OpenLifetimeISAResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.OpenLifetimeISAResponse{CustomerID: 10000, DateOfBirth: "1995-06-01", OpenedAt: openedAt}, response)
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_OpenLifetimeISAAgeIneligibleError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().OpenLifetimeISA(gomock.Any(), 10000).Return(nil, errors.Wrap(service.ErrLifetimeISAAgeIneligible, service.ErrOpeningLifetimeISA)).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/openLifetimeISA/10000", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.OpenLifetimeISA(w, r)
+	res := w.Result()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	actualResponse := string(bodyBytes)
+	assert.Contains(t, actualResponse, service.ErrLifetimeISAAgeIneligible.Error())
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_RequestTransfer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	requestedAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	ms.EXPECT().RequestTransfer(gomock.Any(), service.RequestTransferRequest{
+		CustomerID:             10000,
+		Direction:              schema.TransferIn,
+		ISAType:                schema.Cash,
+		Method:                 schema.CashTransfer,
+		Provider:               "Other Provider",
+		CurrentYearAmountGBP:   2000,
+		PreviousYearsAmountGBP: 8000,
+	}).Return(&service.Transfer{
+		TransferID:             3,
+		CustomerID:             10000,
+		Direction:              schema.TransferIn,
+		ISAType:                schema.Cash,
+		Method:                 schema.CashTransfer,
+		Provider:               "Other Provider",
+		CurrentYearAmountGBP:   2000,
+		PreviousYearsAmountGBP: 8000,
+		Status:                 schema.TransferRequested,
+		RequestedAt:            requestedAt,
+		UpdatedAt:              requestedAt,
+	}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/requestTransfer/10000", strings.NewReader(`{"direction":"in","isaType":"cash","method":"cash","provider":"Other Provider","currentYearAmountGBP":2000,"previousYearsAmountGBP":8000}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.RequestTransfer(w, r)
+	res := w.Result()
+
+	var response transport.TransferResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.TransferResponse{
+		TransferID:             3,
+		CustomerID:             10000,
+		Direction:              "in",
+		ISAType:                "cash",
+		Method:                 "cash",
+		Provider:               "Other Provider",
+		CurrentYearAmountGBP:   2000,
+		PreviousYearsAmountGBP: 8000,
+		Status:                 "requested",
+		RequestedAt:            requestedAt,
+		UpdatedAt:              requestedAt,
+	}, response)
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_RequestTransferAllowanceExceededError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().RequestTransfer(gomock.Any(), gomock.Any()).Return(nil, errors.Wrap(service.ErrISAAllowanceExceeded, service.ErrRequestingTransfer)).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/requestTransfer/10000", strings.NewReader(`{"direction":"in","method":"cash","provider":"Other Provider","currentYearAmountGBP":25000}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.RequestTransfer(w, r)
+	res := w.Result()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	actualResponse := string(bodyBytes)
+	assert.Contains(t, actualResponse, "exceeds remaining ISA allowance")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_RequestTransferBadRequestError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().RequestTransfer(gomock.Any(), gomock.Any()).Return(nil, errors.Wrap(service.ErrInvalidTransfer, service.ErrRequestingTransfer)).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/requestTransfer/10000", strings.NewReader(`{"direction":"sideways","method":"cash","provider":"Other Provider","currentYearAmountGBP":100}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.RequestTransfer(w, r)
+	res := w.Result()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	actualResponse := string(bodyBytes)
+	assert.Contains(t, actualResponse, "invalid transfer")
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_GetTransfers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	requestedAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	completedAt := time.Date(2025, 5, 20, 12, 0, 0, 0, time.UTC)
+
+	ms.EXPECT().GetTransfers(gomock.Any(), 10000).Return([]service.Transfer{
+		{TransferID: 1, CustomerID: 10000, Direction: schema.TransferOut, ISAType: schema.StocksAndShares, Method: schema.InSpecieTransfer, Provider: "Other Provider", PreviousYearsAmountGBP: 12000, Status: schema.TransferCompleted, RequestedAt: requestedAt, UpdatedAt: completedAt, CompletedAt: &completedAt},
+	}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/getTransfers/10000", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.GetTransfers(w, r)
+	res := w.Result()
+
+	var response transport.GetTransfersResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.GetTransfersResponse{Transfers: []transport.TransferResponse{
+		{TransferID: 1, CustomerID: 10000, Direction: "out", ISAType: "stocks_and_shares", Method: "in_specie", Provider: "Other Provider", PreviousYearsAmountGBP: 12000, Status: "completed", RequestedAt: requestedAt, UpdatedAt: completedAt, CompletedAt: &completedAt},
+	}}, response)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_UpdateTransferStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().UpdateTransferStatus(gomock.Any(), 10000, uint(4), schema.TransferInProgress).Return(&service.Transfer{TransferID: 4, CustomerID: 10000, Status: schema.TransferInProgress}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/updateTransferStatus/10000/4", strings.NewReader(`{"status":"in_progress"}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000", "transfer_id": "4"})
+
+	h.UpdateTransferStatus(w, r)
+	res := w.Result()
+
+	var response transport.TransferResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.TransferResponse{TransferID: 4, CustomerID: 10000, Status: "in_progress"}, response)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_UpdateTransferStatusErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	tests := map[string]struct {
+		err    error
+		status int
+	}{
+		"not found": {err: service.ErrTransferNotFound, status: http.StatusNotFound},
+		"conflict":  {err: service.ErrTransferStatusConflict, status: http.StatusConflict},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ms.EXPECT().UpdateTransferStatus(gomock.Any(), 10000, uint(4), schema.TransferCompleted).Return(nil, errors.Wrap(tt.err, service.ErrUpdatingTransfer)).Times(1)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/updateTransferStatus/10000/4", strings.NewReader(`{"status":"completed"}`))
+			r = mux.SetURLVars(r, map[string]string{"customer_id": "10000", "transfer_id": "4"})
+
+			h.UpdateTransferStatus(w, r)
+			assert.Equal(t, tt.status, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestHandler_GetOrderHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	bookCost, gain := 400.0, 100.0
+
+	ms.EXPECT().GetOrderHistory(gomock.Any(), 10000).Return([]service.Order{
+		{OrderID: 2, CustomerID: 10000, OrderType: schema.Sell, Code: "V3AM", AmountGBP: 500, Status: schema.Executed, BookCostGBP: &bookCost, RealisedGainGBP: &gain},
+		{OrderID: 1, CustomerID: 10000, OrderType: schema.Buy, Code: "V3AM", AmountGBP: 800, Status: schema.Executed},
+	}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/getOrderHistory/10000", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.GetOrderHistory(w, r)
+	res := w.Result()
+
+	var response transport.GetOrderHistoryResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.GetOrderHistoryResponse{Orders: []transport.OrderResponse{
+		{OrderID: 2, CustomerID: 10000, OrderType: "sell", Code: "V3AM", AmountGBP: 500, Status: "executed", BookCostGBP: &bookCost, RealisedGainGBP: &gain},
+		{OrderID: 1, CustomerID: 10000, OrderType: "buy", Code: "V3AM", AmountGBP: 800, Status: "executed"},
+	}}, response)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_GetOrderHistoryInternalServerError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().GetOrderHistory(gomock.Any(), 10000).Return(nil, errors.New(service.ErrGettingOrderHistory)).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/getOrderHistory/10000", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.GetOrderHistory(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestHandler_GetPerformance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	day := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	mwr, benchmarkReturn, relativeReturn := 18.74, 5.0, 5.0
+
+	ms.EXPECT().GetPerformance(gomock.Any(), 10000, performance.OneMonth).Return(&service.Performance{
+		Period:                     performance.OneMonth,
+		OpeningDate:                day.AddDate(0, 0, -1),
+		ClosingValueGBP:            440,
+		InvestedGBP:                400,
+		GainGBP:                    40,
+		TimeWeightedReturnPercent:  10,
+		MoneyWeightedReturnPercent: &mwr,
+		BenchmarkReturnPercent:     &benchmarkReturn,
+		RelativeReturnPercent:      &relativeReturn,
+		Funds: []service.FundPerformance{
+			{Code: "V3AM", Name: "ESG Global All Cap UCITS ETF", BenchmarkCode: "FTGAC", BenchmarkName: "FTSE Global All Cap Index", BenchmarkReturnPercent: &benchmarkReturn},
+		},
+		Series: []performance.Point{
+			{Date: day, ValueGBP: 400, InvestedGBP: 400},
+			{Date: day.AddDate(0, 0, 1), ValueGBP: 440},
+		},
+	}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/getPerformance/10000?period=1M", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.GetPerformance(w, r)
+	res := w.Result()
+
+	var response transport.GetPerformanceResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.GetPerformanceResponse{
+		Period:                     "1M",
+		OpeningDate:                "2025-03-02",
+		ClosingValueGBP:            440,
+		InvestedGBP:                400,
+		GainGBP:                    40,
+		TimeWeightedReturnPercent:  10,
+		MoneyWeightedReturnPercent: &mwr,
+		BenchmarkReturnPercent:     &benchmarkReturn,
+		RelativeReturnPercent:      &relativeReturn,
+		Funds: []transport.FundPerformance{
+			{Code: "V3AM", Name: "ESG Global All Cap UCITS ETF", BenchmarkCode: "FTGAC", BenchmarkName: "FTSE Global All Cap Index", BenchmarkReturnPercent: &benchmarkReturn},
+		},
+		Series: []transport.PerformancePoint{
+			{Date: "2025-03-03", ValueGBP: 400, InvestedGBP: 400},
+			{Date: "2025-03-04", ValueGBP: 440},
+		},
+	}, response)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_GetPerformanceSinceInceptionByDefault(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().GetPerformance(gomock.Any(), 10000, performance.SinceInception).Return(&service.Performance{Period: performance.SinceInception}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/getPerformance/10000", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.GetPerformance(w, r)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestHandler_GetPerformanceInvalidPeriod(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().GetPerformance(gomock.Any(), 10000, performance.Period("5Y")).Return(nil, service.ErrInvalidPeriod).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/getPerformance/10000?period=5Y", nil)
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.GetPerformance(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestHandler_RegisterBenchmark(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().RegisterBenchmark(gomock.Any(), service.RegisterBenchmarkRequest{
+		Code:   "FTGAC",
+		Name:   "FTSE Global All Cap Index",
+		Levels: []service.BenchmarkLevel{{Date: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), Level: 1000}},
+	}).Return(&service.Benchmark{Code: "FTGAC", Name: "FTSE Global All Cap Index"}, nil).Times(1)
+
+	body := `{"code":"FTGAC","name":"FTSE Global All Cap Index","levels":[{"date":"2025-03-03","level":1000}]}`
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/registerBenchmark", strings.NewReader(body))
+
+	h.RegisterBenchmark(w, r)
+	res := w.Result()
+
+	var response transport.BenchmarkResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.BenchmarkResponse{Code: "FTGAC", Name: "FTSE Global All Cap Index"}, response)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_RegisterBenchmarkBadRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/registerBenchmark", strings.NewReader(`{"code":"FTGAC","name":"FTSE","levels":[{"date":"03/03/2025","level":1000}]}`))
+	h.RegisterBenchmark(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	ms.EXPECT().RegisterBenchmark(gomock.Any(), gomock.Any()).Return(nil, service.ErrInvalidBenchmark).Times(1)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/registerBenchmark", strings.NewReader(`{"code":"FTGAC"}`))
+	h.RegisterBenchmark(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestHandler_SetFundBenchmark(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().SetFundBenchmark(gomock.Any(), "V3AM", "FTGAC").Return(nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/setFundBenchmark/V3AM", strings.NewReader(`{"benchmarkCode":"FTGAC"}`))
+	r = mux.SetURLVars(r, map[string]string{"code": "V3AM"})
+
+	h.SetFundBenchmark(w, r)
+	res := w.Result()
+
+	var response transport.SetFundBenchmarkResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.SetFundBenchmarkResponse{Code: "V3AM", BenchmarkCode: "FTGAC"}, response)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_SetFundBenchmarkNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	ms.EXPECT().SetFundBenchmark(gomock.Any(), "V3AM", "XXXX").Return(service.ErrBenchmarkNotFound).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/setFundBenchmark/V3AM", strings.NewReader(`{"benchmarkCode":"XXXX"}`))
+	r = mux.SetURLVars(r, map[string]string{"code": "V3AM"})
+
+	h.SetFundBenchmark(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+}
+
+func TestHandler_ImportPayroll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	l := zap.NewNop()
+	h := transport.NewHandler(ms, l)
+
+	file := "EMP001,100,2025-03-28\nEMP002,100,2025-03-28\n"
+	ms.EXPECT().ImportPayroll(gomock.Any(), 1, gomock.Any()).DoAndReturn(func(_ context.Context, _ int, r io.Reader) (*payroll.Report, error) {
+		b, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, file, string(b))
+		return &payroll.Report{RowsRead: 2, Ordered: 1, Flagged: []payroll.Flag{
+			{Line: 2, EmployeeReference: "EMP002", Amount: "100.00", PayDate: "2025-03-28", Reason: payroll.ReasonAllowanceExceeded},
+		}}, nil
+	}).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/importPayroll/1", strings.NewReader(file))
+	r = mux.SetURLVars(r, map[string]string{"employer_id": "1"})
+
+	h.ImportPayroll(w, r)
+	res := w.Result()
+
+	var response transport.ImportPayrollResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.ImportPayrollResponse{RowsRead: 2, Ordered: 1, Flagged: []transport.PayrollFlag{
+		{Line: 2, EmployeeReference: "EMP002", Amount: "100.00", PayDate: "2025-03-28", Reason: payroll.ReasonAllowanceExceeded},
+	}}, response)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_ImportPayrollPartialReport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	h := transport.NewHandler(ms, zap.NewNop())
+
+	ms.EXPECT().ImportPayroll(gomock.Any(), 1, gomock.Any()).Return(&payroll.Report{RowsRead: 2, Ordered: 1, StoppedAtLine: 2}, errors.New("db unavailable")).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/importPayroll/1", strings.NewReader("EMP001,100,2025-03-28\nEMP002,100,2025-03-28\n"))
+	r = mux.SetURLVars(r, map[string]string{"employer_id": "1"})
+
+	h.ImportPayroll(w, r)
+	res := w.Result()
+
+	// The row that was ordered before the failure is still reported
+	var response transport.ImportPayrollResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, response.Ordered)
+	assert.Equal(t, 2, response.StoppedAtLine)
+	assert.Contains(t, response.Error, "db unavailable")
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_ImportPayrollErrors(t *testing.T) {
+	tests := map[string]struct {
+		err    error
+		status int
+	}{
+		"unknown employer": {err: service.ErrEmployerNotFound, status: http.StatusNotFound},
+		"not a CSV":        {err: service.ErrInvalidPayrollFile, status: http.StatusBadRequest},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ms := mocks.NewMockService(ctrl)
+			h := transport.NewHandler(ms, zap.NewNop())
+
+			ms.EXPECT().ImportPayroll(gomock.Any(), 404, gomock.Any()).Return(nil, tt.err).Times(1)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/importPayroll/404", strings.NewReader(""))
+			r = mux.SetURLVars(r, map[string]string{"employer_id": "404"})
+
+			h.ImportPayroll(w, r)
+			assert.Equal(t, tt.status, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestHandler_GetRiskQuestionnaire(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	h := transport.NewHandler(ms, zap.NewNop())
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/getRiskQuestionnaire", nil)
+
+	h.GetRiskQuestionnaire(w, r)
+	res := w.Result()
+
+	var response transport.GetRiskQuestionnaireResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Questions, 5)
+	assert.Equal(t, "horizon", response.Questions[0].ID)
+	assert.Len(t, response.Questions[0].Options, 4)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_SubmitRiskQuestionnaire(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	h := transport.NewHandler(ms, zap.NewNop())
+
+	profiledAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	answers := map[string]int{"horizon": 4, "fall": 3, "experience": 2, "reliance": 3, "goal": 3}
+	ms.EXPECT().SubmitRiskQuestionnaire(gomock.Any(), 10000, answers).Return(&service.RiskProfile{CustomerID: 10000, Profile: schema.Medium, ProfiledAt: profiledAt}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/submitRiskQuestionnaire/10000", strings.NewReader(`{"answers":{"horizon":4,"fall":3,"experience":2,"reliance":3,"goal":3}}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.SubmitRiskQuestionnaire(w, r)
+	res := w.Result()
+
+	var response transport.RiskProfileResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.RiskProfileResponse{CustomerID: 10000, RiskProfile: "medium", ProfiledAt: profiledAt}, response)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
+
+func TestHandler_SubmitRiskQuestionnaireInvalidAnswers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	h := transport.NewHandler(ms, zap.NewNop())
+
+	ms.EXPECT().SubmitRiskQuestionnaire(gomock.Any(), 10000, map[string]int{"horizon": 9}).Return(nil, errors.Wrap(service.ErrInvalidRiskAnswers, "horizon must be answered from 1 to 4")).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/submitRiskQuestionnaire/10000", strings.NewReader(`{"answers":{"horizon":9}}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.SubmitRiskQuestionnaire(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestHandler_PlaceBuyOrderFundUnsuitable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := mocks.NewMockService(ctrl)
+	h := transport.NewHandler(ms, zap.NewNop())
+
+	ms.EXPECT().PlaceBuyOrder(gomock.Any(), service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AB", AmountGBP: 100}).Return(nil, service.ErrFundUnsuitable).Times(1)
+	ms.EXPECT().PlaceBuyOrder(gomock.Any(), service.PlaceBuyOrderRequest{CustomerID: 10000, Code: "V3AB", AmountGBP: 100, AcknowledgeRisk: true}).Return(&service.Order{OrderID: 9, CustomerID: 10000, OrderType: schema.Buy, Code: "V3AB", RiskOverride: true}, nil).Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/placeBuyOrder/10000", strings.NewReader(`{"code":"V3AB","amountGBP":100}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.PlaceBuyOrder(w, r)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/placeBuyOrder/10000", strings.NewReader(`{"code":"V3AB","amountGBP":100,"acknowledgeRisk":true}`))
+	r = mux.SetURLVars(r, map[string]string{"customer_id": "10000"})
+
+	h.PlaceBuyOrder(w, r)
+	res := w.Result()
+
+	var response transport.OrderResponse
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, transport.OrderResponse{OrderID: 9, CustomerID: 10000, OrderType: "buy", Code: "V3AB", RiskOverride: true}, response)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+	err = res.Body.Close()
+	assert.NoError(t, err)
+}
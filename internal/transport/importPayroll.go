@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+// maxPayrollFileBytes caps the size of a payroll file, which is read into memory
+const maxPayrollFileBytes = 10 << 20
+
+// ImportPayroll takes an employer's payroll file as a CSV body and reports which contributions were invested.
+func (h *Handler) ImportPayroll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("ImportPayroll request made")
+
+	vars := mux.Vars(r)
+	employerID, exists := vars["employer_id"]
+	if !exists || employerID == "" {
+		h.Logger.Error("employer_id is missing")
+		http.Error(w, "employer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	employerIDint, err := strconv.Atoi(employerID)
+	if err != nil || employerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s employer_id is invalid", employerID))
+		http.Error(w, fmt.Sprintf("%s employer_id is invalid", employerID), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.Service.ImportPayroll(ctx, employerIDint, http.MaxBytesReader(w, r.Body, maxPayrollFileBytes))
+	if err != nil && report == nil {
+		h.Logger.Error(errors.Wrap(err, ErrImportingPayroll).Error())
+		http.Error(w, errors.Wrap(err, ErrImportingPayroll).Error(), statusFromError(err))
+		return
+	}
+
+	response := ImportPayrollResponse{
+		RowsRead:      report.RowsRead,
+		Ordered:       report.Ordered,
+		Replayed:      report.Replayed,
+		Flagged:       make([]PayrollFlag, len(report.Flagged)),
+		StoppedAtLine: report.StoppedAtLine,
+	}
+	for i, f := range report.Flagged {
+		response.Flagged[i] = PayrollFlag{
+			Line:              f.Line,
+			EmployeeReference: f.EmployeeReference,
+			Amount:            f.Amount,
+			PayDate:           f.PayDate,
+			Reason:            f.Reason,
+		}
+	}
+
+	// An import that failed partway through has still invested the rows before it, so they are reported with the error.
+	status := http.StatusOK
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrImportingPayroll).Error())
+		response.Error = errors.Wrap(err, ErrImportingPayroll).Error()
+		status = statusFromError(err)
+	}
+
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrImportingPayroll).Error())
+		http.Error(w, errors.Wrap(err, ErrImportingPayroll).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("ImportPayroll returned successfully")
+}
+
+type ImportPayrollResponse struct {
+	RowsRead      int           `json:"rowsRead"`
+	Ordered       int           `json:"ordered"`
+	Replayed      int           `json:"replayed"`
+	Flagged       []PayrollFlag `json:"flagged"`
+	StoppedAtLine int           `json:"stoppedAtLine,omitempty"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// PayrollFlag is a row of the file that wasn't invested, with its fields as they appeared in the file
+type PayrollFlag struct {
+	Line              int    `json:"line"`
+	EmployeeReference string `json:"employeeReference"`
+	Amount            string `json:"amount"`
+	PayDate           string `json:"payDate"`
+	Reason            string `json:"reason"`
+}
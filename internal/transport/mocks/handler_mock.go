@@ -6,9 +6,13 @@ package transport
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	payroll "github.com/jautyw/isa-investment-funds/internal/payroll"
+	performance "github.com/jautyw/isa-investment-funds/internal/performance"
+	schema "github.com/jautyw/isa-investment-funds/internal/schema"
 	service "github.com/jautyw/isa-investment-funds/internal/service"
 )
 
@@ -35,19 +39,49 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 	return m.recorder
 }
 
+// CancelOrder mocks base method.
+func (m *MockService) CancelOrder(arg0 context.Context, arg1 int, arg2 uint) (*service.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelOrder", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*service.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelOrder indicates an expected call of CancelOrder.
+func (mr *MockServiceMockRecorder) CancelOrder(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelOrder", reflect.TypeOf((*MockService)(nil).CancelOrder), arg0, arg1, arg2)
+}
+
+// GetAllowanceHistory mocks base method.
+func (m *MockService) GetAllowanceHistory(arg0 context.Context, arg1 int) (*service.AllowanceHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllowanceHistory", arg0, arg1)
+	ret0, _ := ret[0].(*service.AllowanceHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllowanceHistory indicates an expected call of GetAllowanceHistory.
+func (mr *MockServiceMockRecorder) GetAllowanceHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllowanceHistory", reflect.TypeOf((*MockService)(nil).GetAllowanceHistory), arg0, arg1)
+}
+
 // GetFunds mocks base method.
-func (m *MockService) GetFunds(arg0 context.Context, arg1 string) (*service.Funds, error) {
+func (m *MockService) GetFunds(arg0 context.Context, arg1 int, arg2 service.FundQuery) (*service.Funds, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetFunds", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetFunds", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*service.Funds)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetFunds indicates an expected call of GetFunds.
-func (mr *MockServiceMockRecorder) GetFunds(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) GetFunds(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFunds", reflect.TypeOf((*MockService)(nil).GetFunds), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFunds", reflect.TypeOf((*MockService)(nil).GetFunds), arg0, arg1, arg2)
 }
 
 // GetInvestmentOverview mocks base method.
@@ -64,3 +98,182 @@ func (mr *MockServiceMockRecorder) GetInvestmentOverview(arg0, arg1 interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInvestmentOverview", reflect.TypeOf((*MockService)(nil).GetInvestmentOverview), arg0, arg1)
 }
+
+// GetOrderHistory mocks base method.
+func (m *MockService) GetOrderHistory(arg0 context.Context, arg1 int) ([]service.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderHistory", arg0, arg1)
+	ret0, _ := ret[0].([]service.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderHistory indicates an expected call of GetOrderHistory.
+func (mr *MockServiceMockRecorder) GetOrderHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderHistory", reflect.TypeOf((*MockService)(nil).GetOrderHistory), arg0, arg1)
+}
+
+// GetPerformance mocks base method.
+func (m *MockService) GetPerformance(arg0 context.Context, arg1 int, arg2 performance.Period) (*service.Performance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPerformance", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*service.Performance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPerformance indicates an expected call of GetPerformance.
+func (mr *MockServiceMockRecorder) GetPerformance(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPerformance", reflect.TypeOf((*MockService)(nil).GetPerformance), arg0, arg1, arg2)
+}
+
+// GetTransfers mocks base method.
+func (m *MockService) GetTransfers(arg0 context.Context, arg1 int) ([]service.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfers", arg0, arg1)
+	ret0, _ := ret[0].([]service.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfers indicates an expected call of GetTransfers.
+func (mr *MockServiceMockRecorder) GetTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfers", reflect.TypeOf((*MockService)(nil).GetTransfers), arg0, arg1)
+}
+
+// ImportPayroll mocks base method.
+func (m *MockService) ImportPayroll(arg0 context.Context, arg1 int, arg2 io.Reader) (*payroll.Report, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportPayroll", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*payroll.Report)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportPayroll indicates an expected call of ImportPayroll.
+func (mr *MockServiceMockRecorder) ImportPayroll(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportPayroll", reflect.TypeOf((*MockService)(nil).ImportPayroll), arg0, arg1, arg2)
+}
+
+// OpenLifetimeISA mocks base method.
+func (m *MockService) OpenLifetimeISA(arg0 context.Context, arg1 int) (*service.LifetimeISA, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OpenLifetimeISA", arg0, arg1)
+	ret0, _ := ret[0].(*service.LifetimeISA)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OpenLifetimeISA indicates an expected call of OpenLifetimeISA.
+func (mr *MockServiceMockRecorder) OpenLifetimeISA(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenLifetimeISA", reflect.TypeOf((*MockService)(nil).OpenLifetimeISA), arg0, arg1)
+}
+
+// PlaceBuyOrder mocks base method.
+func (m *MockService) PlaceBuyOrder(arg0 context.Context, arg1 service.PlaceBuyOrderRequest) (*service.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PlaceBuyOrder", arg0, arg1)
+	ret0, _ := ret[0].(*service.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PlaceBuyOrder indicates an expected call of PlaceBuyOrder.
+func (mr *MockServiceMockRecorder) PlaceBuyOrder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PlaceBuyOrder", reflect.TypeOf((*MockService)(nil).PlaceBuyOrder), arg0, arg1)
+}
+
+// PlaceSellOrder mocks base method.
+func (m *MockService) PlaceSellOrder(arg0 context.Context, arg1 service.PlaceSellOrderRequest) (*service.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PlaceSellOrder", arg0, arg1)
+	ret0, _ := ret[0].(*service.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PlaceSellOrder indicates an expected call of PlaceSellOrder.
+func (mr *MockServiceMockRecorder) PlaceSellOrder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PlaceSellOrder", reflect.TypeOf((*MockService)(nil).PlaceSellOrder), arg0, arg1)
+}
+
+// RegisterBenchmark mocks base method.
+func (m *MockService) RegisterBenchmark(arg0 context.Context, arg1 service.RegisterBenchmarkRequest) (*service.Benchmark, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterBenchmark", arg0, arg1)
+	ret0, _ := ret[0].(*service.Benchmark)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegisterBenchmark indicates an expected call of RegisterBenchmark.
+func (mr *MockServiceMockRecorder) RegisterBenchmark(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterBenchmark", reflect.TypeOf((*MockService)(nil).RegisterBenchmark), arg0, arg1)
+}
+
+// RequestTransfer mocks base method.
+func (m *MockService) RequestTransfer(arg0 context.Context, arg1 service.RequestTransferRequest) (*service.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestTransfer", arg0, arg1)
+	ret0, _ := ret[0].(*service.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestTransfer indicates an expected call of RequestTransfer.
+func (mr *MockServiceMockRecorder) RequestTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestTransfer", reflect.TypeOf((*MockService)(nil).RequestTransfer), arg0, arg1)
+}
+
+// SetFundBenchmark mocks base method.
+func (m *MockService) SetFundBenchmark(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetFundBenchmark", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetFundBenchmark indicates an expected call of SetFundBenchmark.
+func (mr *MockServiceMockRecorder) SetFundBenchmark(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetFundBenchmark", reflect.TypeOf((*MockService)(nil).SetFundBenchmark), arg0, arg1, arg2)
+}
+
+// SubmitRiskQuestionnaire mocks base method.
+func (m *MockService) SubmitRiskQuestionnaire(arg0 context.Context, arg1 int, arg2 map[string]int) (*service.RiskProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubmitRiskQuestionnaire", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*service.RiskProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubmitRiskQuestionnaire indicates an expected call of SubmitRiskQuestionnaire.
+func (mr *MockServiceMockRecorder) SubmitRiskQuestionnaire(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubmitRiskQuestionnaire", reflect.TypeOf((*MockService)(nil).SubmitRiskQuestionnaire), arg0, arg1, arg2)
+}
+
+// UpdateTransferStatus mocks base method.
+func (m *MockService) UpdateTransferStatus(arg0 context.Context, arg1 int, arg2 uint, arg3 schema.TransferStatus) (*service.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTransferStatus", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*service.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTransferStatus indicates an expected call of UpdateTransferStatus.
+func (mr *MockServiceMockRecorder) UpdateTransferStatus(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTransferStatus", reflect.TypeOf((*MockService)(nil).UpdateTransferStatus), arg0, arg1, arg2, arg3)
+}
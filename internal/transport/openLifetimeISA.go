@@ -0,0 +1,64 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// dateOfBirthLayout is the format dates of birth are returned in
+const dateOfBirthLayout = "2006-01-02"
+
+// OpenLifetimeISA opens a lifetime ISA for the customer, whose age is taken from the date of birth on their record.
+func (h *Handler) OpenLifetimeISA(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("OpenLifetimeISA request made")
+
+	vars := mux.Vars(r)
+	customerID, exists := vars["customer_id"]
+	if !exists || customerID == "" {
+		h.Logger.Error("customer_id is missing")
+		http.Error(w, "customer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	customerIDint, err := strconv.Atoi(customerID)
+	if err != nil || customerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s customer_id is invalid", customerID))
+		http.Error(w, fmt.Sprintf("%s customer_id is invalid", customerID), http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.Service.OpenLifetimeISA(ctx, customerIDint)
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrOpeningLifetimeISA).Error())
+		http.Error(w, errors.Wrap(err, ErrOpeningLifetimeISA).Error(), statusFromError(err))
+		return
+	}
+
+	response := OpenLifetimeISAResponse{
+		CustomerID:  account.CustomerID,
+		DateOfBirth: account.DateOfBirth.Format(dateOfBirthLayout),
+		OpenedAt:    account.OpenedAt,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrOpeningLifetimeISA).Error())
+		http.Error(w, errors.Wrap(err, ErrOpeningLifetimeISA).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("OpenLifetimeISA returned successfully")
+}
+
+type OpenLifetimeISAResponse struct {
+	CustomerID  uint      `json:"customerId"`
+	DateOfBirth string    `json:"dateOfBirth"`
+	OpenedAt    time.Time `json:"openedAt"`
+}
@@ -0,0 +1,142 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/service"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (h *Handler) PlaceBuyOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("PlaceBuyOrder request made")
+
+	vars := mux.Vars(r)
+	customerID, exists := vars["customer_id"]
+	if !exists || customerID == "" {
+		h.Logger.Error("customer_id is missing")
+		http.Error(w, "customer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	customerIDint, err := strconv.Atoi(customerID)
+	if err != nil || customerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s customer_id is invalid", customerID))
+		http.Error(w, fmt.Sprintf("%s customer_id is invalid", customerID), http.StatusBadRequest)
+		return
+	}
+
+	var request PlaceBuyOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrPlacingBuyOrder).Error())
+		http.Error(w, errors.Wrap(err, ErrPlacingBuyOrder).Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Code == "" {
+		h.Logger.Error("code is missing")
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	idempotencyKey := r.Header.Get(idempotencyKeyHeader)
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		h.Logger.Error(fmt.Sprintf("%s header is too long", idempotencyKeyHeader))
+		http.Error(w, fmt.Sprintf("%s header must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength), http.StatusBadRequest)
+		return
+	}
+
+	var hash string
+	if idempotencyKey != "" {
+		hash, err = requestHash(request)
+		if err != nil {
+			h.Logger.Error(errors.Wrap(err, ErrPlacingBuyOrder).Error())
+			http.Error(w, errors.Wrap(err, ErrPlacingBuyOrder).Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	order, err := h.Service.PlaceBuyOrder(ctx, service.PlaceBuyOrderRequest{
+		CustomerID:      customerIDint,
+		Code:            request.Code,
+		ISAType:         schema.ISAType(request.ISAType),
+		AmountGBP:       request.AmountGBP,
+		AcknowledgeRisk: request.AcknowledgeRisk,
+		IdempotencyKey:  idempotencyKey,
+		RequestHash:     hash,
+	})
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrPlacingBuyOrder).Error())
+		http.Error(w, errors.Wrap(err, ErrPlacingBuyOrder).Error(), statusFromError(err))
+		return
+	}
+
+	if order.Replayed {
+		w.Header().Set(idempotentReplayedHeader, "true")
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(toOrderResponse(order)); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrPlacingBuyOrder).Error())
+		http.Error(w, errors.Wrap(err, ErrPlacingBuyOrder).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("PlaceBuyOrder returned successfully")
+}
+
+// toOrderResponse maps an order returned by the service onto the response body shared by the order endpoints.
+func toOrderResponse(o *service.Order) OrderResponse {
+	return OrderResponse{
+		OrderID:           o.OrderID,
+		CustomerID:        o.CustomerID,
+		OrderType:         string(o.OrderType),
+		Name:              o.Name,
+		Code:              o.Code,
+		ISAType:           string(o.ISAType),
+		PurchaseTime:      o.PurchaseTime,
+		SharesPurchased:   o.SharesPurchased,
+		AmountGBP:         o.AmountGBP,
+		Status:            string(o.Status),
+		ExecutionTime:     o.ExecutionTime,
+		ExecutionPriceGBP: o.ExecutionPriceGBP,
+		WithdrawalReason:  (*string)(o.WithdrawalReason),
+		BookCostGBP:       o.BookCostGBP,
+		RealisedGainGBP:   o.RealisedGainGBP,
+		RiskOverride:      o.RiskOverride,
+	}
+}
+
+// PlaceBuyOrderRequest buys into the stocks and shares ISA unless another ISAType is given. AcknowledgeRisk must be set
+// to buy a fund that is riskier than the customer's risk profile.
+type PlaceBuyOrderRequest struct {
+	Code            string  `json:"code"`
+	ISAType         string  `json:"isaType,omitempty"`
+	AmountGBP       float64 `json:"amountGBP"`
+	AcknowledgeRisk bool    `json:"acknowledgeRisk,omitempty"`
+}
+
+type OrderResponse struct {
+	OrderID           uint       `json:"orderId"`
+	CustomerID        uint       `json:"customerId"`
+	OrderType         string     `json:"orderType"`
+	Name              string     `json:"name"`
+	Code              string     `json:"code"`
+	ISAType           string     `json:"isaType"`
+	PurchaseTime      time.Time  `json:"purchaseTime"`
+	SharesPurchased   float64    `json:"sharesPurchased"`
+	AmountGBP         float64    `json:"amountGBP"`
+	Status            string     `json:"status"`
+	ExecutionTime     *time.Time `json:"executionTime,omitempty"`
+	ExecutionPriceGBP *float64   `json:"executionPriceGBP,omitempty"`
+	WithdrawalReason  *string    `json:"withdrawalReason,omitempty"`
+	BookCostGBP       *float64   `json:"bookCostGBP,omitempty"`
+	RealisedGainGBP   *float64   `json:"realisedGainGBP,omitempty"`
+	RiskOverride      bool       `json:"riskOverride,omitempty"`
+}
@@ -0,0 +1,100 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/service"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) PlaceSellOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("PlaceSellOrder request made")
+
+	vars := mux.Vars(r)
+	customerID, exists := vars["customer_id"]
+	if !exists || customerID == "" {
+		h.Logger.Error("customer_id is missing")
+		http.Error(w, "customer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	customerIDint, err := strconv.Atoi(customerID)
+	if err != nil || customerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s customer_id is invalid", customerID))
+		http.Error(w, fmt.Sprintf("%s customer_id is invalid", customerID), http.StatusBadRequest)
+		return
+	}
+
+	var request PlaceSellOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrPlacingSellOrder).Error())
+		http.Error(w, errors.Wrap(err, ErrPlacingSellOrder).Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Code == "" {
+		h.Logger.Error("code is missing")
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	idempotencyKey := r.Header.Get(idempotencyKeyHeader)
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		h.Logger.Error(fmt.Sprintf("%s header is too long", idempotencyKeyHeader))
+		http.Error(w, fmt.Sprintf("%s header must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength), http.StatusBadRequest)
+		return
+	}
+
+	var hash string
+	if idempotencyKey != "" {
+		hash, err = requestHash(request)
+		if err != nil {
+			h.Logger.Error(errors.Wrap(err, ErrPlacingSellOrder).Error())
+			http.Error(w, errors.Wrap(err, ErrPlacingSellOrder).Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	order, err := h.Service.PlaceSellOrder(ctx, service.PlaceSellOrderRequest{
+		CustomerID:       customerIDint,
+		Code:             request.Code,
+		ISAType:          schema.ISAType(request.ISAType),
+		Shares:           request.Shares,
+		WithdrawalReason: schema.WithdrawalReason(request.WithdrawalReason),
+		IdempotencyKey:   idempotencyKey,
+		RequestHash:      hash,
+	})
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrPlacingSellOrder).Error())
+		http.Error(w, errors.Wrap(err, ErrPlacingSellOrder).Error(), statusFromError(err))
+		return
+	}
+
+	if order.Replayed {
+		w.Header().Set(idempotentReplayedHeader, "true")
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(toOrderResponse(order)); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrPlacingSellOrder).Error())
+		http.Error(w, errors.Wrap(err, ErrPlacingSellOrder).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("PlaceSellOrder returned successfully")
+}
+
+// PlaceSellOrderRequest sells from the stocks and shares ISA unless another ISAType is given. It deliberately has no
+// price field, proceeds are always priced from the fund. WithdrawalReason can only be given for lifetime ISAs.
+type PlaceSellOrderRequest struct {
+	Code             string  `json:"code"`
+	ISAType          string  `json:"isaType,omitempty"`
+	Shares           float64 `json:"shares"`
+	WithdrawalReason string  `json:"withdrawalReason,omitempty"`
+}
@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jautyw/isa-investment-funds/internal/service"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+// benchmarkDateLayout is the format the date of each benchmark level is sent in
+const benchmarkDateLayout = "2006-01-02"
+
+func (h *Handler) RegisterBenchmark(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("RegisterBenchmark request made")
+
+	var request RegisterBenchmarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrRegisteringBenchmark).Error())
+		http.Error(w, errors.Wrap(err, ErrRegisteringBenchmark).Error(), http.StatusBadRequest)
+		return
+	}
+
+	levels := make([]service.BenchmarkLevel, len(request.Levels))
+	for i, l := range request.Levels {
+		date, err := time.Parse(benchmarkDateLayout, l.Date)
+		if err != nil {
+			h.Logger.Error(fmt.Sprintf("%s date is invalid", l.Date))
+			http.Error(w, fmt.Sprintf("%s date is invalid, expected YYYY-MM-DD", l.Date), http.StatusBadRequest)
+			return
+		}
+		levels[i] = service.BenchmarkLevel{Date: date, Level: l.Level}
+	}
+
+	benchmark, err := h.Service.RegisterBenchmark(ctx, service.RegisterBenchmarkRequest{
+		Code:   request.Code,
+		Name:   request.Name,
+		Levels: levels,
+	})
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrRegisteringBenchmark).Error())
+		http.Error(w, errors.Wrap(err, ErrRegisteringBenchmark).Error(), statusFromError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(BenchmarkResponse{Code: benchmark.Code, Name: benchmark.Name}); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrRegisteringBenchmark).Error())
+		http.Error(w, errors.Wrap(err, ErrRegisteringBenchmark).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("RegisterBenchmark returned successfully")
+}
+
+// RegisterBenchmarkRequest renames the benchmark if the code is already registered, and levels replace any already
+// held for the same dates
+type RegisterBenchmarkRequest struct {
+	Code   string                  `json:"code"`
+	Name   string                  `json:"name"`
+	Levels []BenchmarkLevelRequest `json:"levels"`
+}
+
+type BenchmarkLevelRequest struct {
+	Date  string  `json:"date"`
+	Level float64 `json:"level"`
+}
+
+type BenchmarkResponse struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
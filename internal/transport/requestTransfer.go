@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/jautyw/isa-investment-funds/internal/service"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (h *Handler) RequestTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("RequestTransfer request made")
+
+	vars := mux.Vars(r)
+	customerID, exists := vars["customer_id"]
+	if !exists || customerID == "" {
+		h.Logger.Error("customer_id is missing")
+		http.Error(w, "customer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	customerIDint, err := strconv.Atoi(customerID)
+	if err != nil || customerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s customer_id is invalid", customerID))
+		http.Error(w, fmt.Sprintf("%s customer_id is invalid", customerID), http.StatusBadRequest)
+		return
+	}
+
+	var request RequestTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrRequestingTransfer).Error())
+		http.Error(w, errors.Wrap(err, ErrRequestingTransfer).Error(), http.StatusBadRequest)
+		return
+	}
+
+	transfer, err := h.Service.RequestTransfer(ctx, service.RequestTransferRequest{
+		CustomerID:             customerIDint,
+		Direction:              schema.TransferDirection(request.Direction),
+		ISAType:                schema.ISAType(request.ISAType),
+		Method:                 schema.TransferMethod(request.Method),
+		Provider:               request.Provider,
+		CurrentYearAmountGBP:   request.CurrentYearAmountGBP,
+		PreviousYearsAmountGBP: request.PreviousYearsAmountGBP,
+	})
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrRequestingTransfer).Error())
+		http.Error(w, errors.Wrap(err, ErrRequestingTransfer).Error(), statusFromError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(toTransferResponse(transfer)); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrRequestingTransfer).Error())
+		http.Error(w, errors.Wrap(err, ErrRequestingTransfer).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("RequestTransfer returned successfully")
+}
+
+func toTransferResponse(t *service.Transfer) TransferResponse {
+	return TransferResponse{
+		TransferID:             t.TransferID,
+		CustomerID:             t.CustomerID,
+		Direction:              string(t.Direction),
+		ISAType:                string(t.ISAType),
+		Method:                 string(t.Method),
+		Provider:               t.Provider,
+		CurrentYearAmountGBP:   t.CurrentYearAmountGBP,
+		PreviousYearsAmountGBP: t.PreviousYearsAmountGBP,
+		Status:                 string(t.Status),
+		RequestedAt:            t.RequestedAt,
+		UpdatedAt:              t.UpdatedAt,
+		CompletedAt:            t.CompletedAt,
+	}
+}
+
+// RequestTransferRequest is for the stocks and shares ISA when isaType is omitted
+type RequestTransferRequest struct {
+	Direction              string  `json:"direction"`
+	ISAType                string  `json:"isaType,omitempty"`
+	Method                 string  `json:"method"`
+	Provider               string  `json:"provider"`
+	CurrentYearAmountGBP   float64 `json:"currentYearAmountGBP"`
+	PreviousYearsAmountGBP float64 `json:"previousYearsAmountGBP"`
+}
+
+type TransferResponse struct {
+	TransferID             uint       `json:"transferId"`
+	CustomerID             uint       `json:"customerId"`
+	Direction              string     `json:"direction"`
+	ISAType                string     `json:"isaType"`
+	Method                 string     `json:"method"`
+	Provider               string     `json:"provider"`
+	CurrentYearAmountGBP   float64    `json:"currentYearAmountGBP"`
+	PreviousYearsAmountGBP float64    `json:"previousYearsAmountGBP"`
+	Status                 string     `json:"status"`
+	RequestedAt            time.Time  `json:"requestedAt"`
+	UpdatedAt              time.Time  `json:"updatedAt"`
+	CompletedAt            *time.Time `json:"completedAt,omitempty"`
+}
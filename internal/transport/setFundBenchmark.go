@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+func (h *Handler) SetFundBenchmark(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("SetFundBenchmark request made")
+
+	vars := mux.Vars(r)
+	code, exists := vars["code"]
+	if !exists || code == "" {
+		h.Logger.Error("code is missing")
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	var request SetFundBenchmarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrSettingFundBenchmark).Error())
+		http.Error(w, errors.Wrap(err, ErrSettingFundBenchmark).Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.BenchmarkCode == "" {
+		h.Logger.Error("benchmarkCode is missing")
+		http.Error(w, "benchmarkCode is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.SetFundBenchmark(ctx, code, request.BenchmarkCode); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrSettingFundBenchmark).Error())
+		http.Error(w, errors.Wrap(err, ErrSettingFundBenchmark).Error(), statusFromError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(SetFundBenchmarkResponse{Code: code, BenchmarkCode: request.BenchmarkCode}); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrSettingFundBenchmark).Error())
+		http.Error(w, errors.Wrap(err, ErrSettingFundBenchmark).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("SetFundBenchmark returned successfully")
+}
+
+type SetFundBenchmarkRequest struct {
+	BenchmarkCode string `json:"benchmarkCode"`
+}
+
+type SetFundBenchmarkResponse struct {
+	Code          string `json:"code"`
+	BenchmarkCode string `json:"benchmarkCode"`
+}
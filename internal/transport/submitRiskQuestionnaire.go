@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SubmitRiskQuestionnaire scores the customer's answers into their risk profile, which their buy orders are checked
+// against.
+func (h *Handler) SubmitRiskQuestionnaire(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("SubmitRiskQuestionnaire request made")
+
+	vars := mux.Vars(r)
+	customerID, exists := vars["customer_id"]
+	if !exists || customerID == "" {
+		h.Logger.Error("customer_id is missing")
+		http.Error(w, "customer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	customerIDint, err := strconv.Atoi(customerID)
+	if err != nil || customerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s customer_id is invalid", customerID))
+		http.Error(w, fmt.Sprintf("%s customer_id is invalid", customerID), http.StatusBadRequest)
+		return
+	}
+
+	var request SubmitRiskQuestionnaireRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrSubmittingQuestionnaire).Error())
+		http.Error(w, errors.Wrap(err, ErrSubmittingQuestionnaire).Error(), http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.Service.SubmitRiskQuestionnaire(ctx, customerIDint, request.Answers)
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrSubmittingQuestionnaire).Error())
+		http.Error(w, errors.Wrap(err, ErrSubmittingQuestionnaire).Error(), statusFromError(err))
+		return
+	}
+
+	response := RiskProfileResponse{
+		CustomerID:  profile.CustomerID,
+		RiskProfile: string(profile.Profile),
+		ProfiledAt:  profile.ProfiledAt,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrSubmittingQuestionnaire).Error())
+		http.Error(w, errors.Wrap(err, ErrSubmittingQuestionnaire).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("SubmitRiskQuestionnaire returned successfully")
+}
+
+// SubmitRiskQuestionnaireRequest answers each question by its id with the number of the chosen option
+type SubmitRiskQuestionnaireRequest struct {
+	Answers map[string]int `json:"answers"`
+}
+
+type RiskProfileResponse struct {
+	CustomerID  uint      `json:"customerId"`
+	RiskProfile string    `json:"riskProfile"`
+	ProfiledAt  time.Time `json:"profiledAt"`
+}
@@ -0,0 +1,73 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/jautyw/isa-investment-funds/internal/schema"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) UpdateTransferStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Info("UpdateTransferStatus request made")
+
+	vars := mux.Vars(r)
+	customerID, exists := vars["customer_id"]
+	if !exists || customerID == "" {
+		h.Logger.Error("customer_id is missing")
+		http.Error(w, "customer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	customerIDint, err := strconv.Atoi(customerID)
+	if err != nil || customerIDint <= 0 {
+		h.Logger.Error(fmt.Sprintf("%s customer_id is invalid", customerID))
+		http.Error(w, fmt.Sprintf("%s customer_id is invalid", customerID), http.StatusBadRequest)
+		return
+	}
+
+	transferID, exists := vars["transfer_id"]
+	if !exists || transferID == "" {
+		h.Logger.Error("transfer_id is missing")
+		http.Error(w, "transfer_id is required", http.StatusBadRequest)
+		return
+	}
+
+	transferIDuint, err := strconv.ParseUint(transferID, 10, 64)
+	if err != nil || transferIDuint == 0 {
+		h.Logger.Error(fmt.Sprintf("%s transfer_id is invalid", transferID))
+		http.Error(w, fmt.Sprintf("%s transfer_id is invalid", transferID), http.StatusBadRequest)
+		return
+	}
+
+	var request UpdateTransferStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrUpdatingTransferStatus).Error())
+		http.Error(w, errors.Wrap(err, ErrUpdatingTransferStatus).Error(), http.StatusBadRequest)
+		return
+	}
+
+	transfer, err := h.Service.UpdateTransferStatus(ctx, customerIDint, uint(transferIDuint), schema.TransferStatus(request.Status))
+	if err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrUpdatingTransferStatus).Error())
+		http.Error(w, errors.Wrap(err, ErrUpdatingTransferStatus).Error(), statusFromError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(toTransferResponse(transfer)); err != nil {
+		h.Logger.Error(errors.Wrap(err, ErrUpdatingTransferStatus).Error())
+		http.Error(w, errors.Wrap(err, ErrUpdatingTransferStatus).Error(), http.StatusInternalServerError)
+	}
+
+	h.Logger.Info("UpdateTransferStatus returned successfully")
+}
+
+type UpdateTransferStatusRequest struct {
+	Status string `json:"status"`
+}
@@ -0,0 +1,66 @@
+//go:generate mockgen -destination=./mocks/worker_mock.go -package worker github.com/jautyw/isa-investment-funds/internal/worker Executor
+package worker
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Worker periodically settles queued orders in the background of the server process
+type Worker struct {
+	Executor Executor
+	Logger   *zap.Logger
+	interval time.Duration
+}
+
+// NewWorker will instantiate a new instance of the Worker
+func NewWorker(e Executor, l *zap.Logger, interval time.Duration) *Worker {
+	return &Worker{
+		Executor: e,
+		Logger:   l,
+		interval: interval,
+	}
+}
+
+// Executor represents a type that can settle pending orders
+type Executor interface {
+	ExecutePendingOrders(ctx context.Context) (int, error)
+}
+
+const (
+	ErrExecutingOrders = "order execution worker error"
+)
+
+// Run executes pending orders every interval until the context is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.Logger.Info(fmt.Sprintf("order execution worker started with interval %s", w.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.Logger.Info("order execution worker stopped")
+			return
+		case <-ticker.C:
+			w.executeOnce(ctx)
+		}
+	}
+}
+
+func (w *Worker) executeOnce(ctx context.Context) {
+	// A failed run is logged and retried on the next tick, the orders stay pending in the meantime.
+	executed, err := w.Executor.ExecutePendingOrders(ctx)
+	if err != nil {
+		w.Logger.Error(errors.Wrap(err, ErrExecutingOrders).Error())
+		return
+	}
+
+	if executed > 0 {
+		w.Logger.Info(fmt.Sprintf("order execution worker settled %d orders", executed))
+	}
+}
@@ -0,0 +1,51 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/jautyw/isa-investment-funds/internal/worker"
+	mocks "github.com/jautyw/isa-investment-funds/internal/worker/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestWorker_NewWorker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	me := mocks.NewMockExecutor(ctrl)
+	w := worker.NewWorker(me, zap.NewNop(), time.Second)
+	assert.NotNil(t, w)
+}
+
+func TestWorker_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	me := mocks.NewMockExecutor(ctrl)
+	w := worker.NewWorker(me, zap.NewNop(), time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The first run fails and the worker carries on to the next tick
+	gomock.InOrder(
+		me.EXPECT().ExecutePendingOrders(gomock.Any()).Return(0, errors.New("db unavailable")).Times(1),
+		me.EXPECT().ExecutePendingOrders(gomock.Any()).DoAndReturn(func(_ context.Context) (int, error) {
+			cancel()
+			return 3, nil
+		}).Times(1),
+	)
+
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop after the context was cancelled")
+	}
+}